@@ -1,34 +1,30 @@
 #!/bin/sh
+# Generates the example CAs and certificates, replacing any that were generated before. Keys are written unencrypted so that the examples and tests work out of the box; pass `--encrypt-key=<passphrase file>` for real deployments.
+# The example certificates are long-lived test fixtures; real deployments should issue short-lived certificates from their own CA.
+set -e
 
-# CA 1
-mkdir -p ca1
-openssl req -x509 -newkey rsa:4096 -days 365 -nodes -keyout ca1/key.pem -out ca1/cert.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=CA/CN=Trusted CA/emailAddress=mlaradji@pm.me"
+cd "$(dirname "$0")"
 
-# Server
-mkdir -p server
-openssl req -newkey rsa:4096 -nodes -keyout server/key.pem -out server/req.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=Server/CN=Server/emailAddress=mlaradji@pm.me"
-openssl x509 -CAcreateserial -req -in server/req.pem -CA ca1/cert.pem -CAkey ca1/key.pem -out server/cert.pem -extfile ext.cnf
+WORKER_SERVER="go run ../cmd/server/main.go"
+ORG="O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+DAYS=3650
 
-# Client 1
-mkdir -p client1
-openssl req -newkey rsa:4096 -nodes -keyout client1/key.pem -out client1/req.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=Client/CN=Client 1/emailAddress=mlaradji@pm.me"
-openssl x509 -CAcreateserial -req -in client1/req.pem -days 60 -CA ca1/cert.pem -CAkey ca1/key.pem -out client1/cert.pem -extfile ext.cnf
+# keys are never overwritten, so the previous certificates are removed first
+rm -rf ca1 ca2 server client1 client2 client3 client4
 
-# Client 2
-mkdir -p client2
-openssl req -newkey rsa:4096 -nodes -keyout client2/key.pem -out client2/req.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=Client/CN=Client 2/emailAddress=mlaradji@pm.me"
-openssl x509 -CAcreateserial -req -in client2/req.pem -days 60 -CA ca1/cert.pem -CAkey ca1/key.pem -out client2/cert.pem -extfile ext.cnf
+# CA 1
+$WORKER_SERVER certs ca --out=ca1 --subject="CN=CA,OU=CA,$ORG" --days=$DAYS
+
+# Server
+$WORKER_SERVER certs server --out=server --issuer=ca1 --subject="CN=Server,OU=Server,$ORG" --dns=localhost,bufnet --ip=0.0.0.0,127.0.0.1 --days=$DAYS
 
-# Client 3
-mkdir -p client3
-openssl req -newkey rsa:4096 -nodes -keyout client3/key.pem -out client3/req.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=Client/CN=Client 3/emailAddress=mlaradji@pm.me"
-openssl x509 -CAcreateserial -req -in client3/req.pem -days 60 -CA ca1/cert.pem -CAkey ca1/key.pem -out client3/cert.pem -extfile ext.cnf
+# Clients 1, 2 and 3
+$WORKER_SERVER certs client --out=client1 --issuer=ca1 --subject="CN=Client 1,OU=Client,$ORG" --days=$DAYS
+$WORKER_SERVER certs client --out=client2 --issuer=ca1 --subject="CN=Client 2,OU=Client,$ORG" --days=$DAYS
+$WORKER_SERVER certs client --out=client3 --issuer=ca1 --subject="CN=Client 3,OU=Client,$ORG" --days=$DAYS
 
 # CA 2
-mkdir -p ca2
-openssl req -x509 -newkey rsa:4096 -days 365 -nodes -keyout ca2/key.pem -out ca2/cert.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=CA/CN=Untrusted CA/emailAddress=mlaradji@pm.me"
+$WORKER_SERVER certs ca --out=ca2 --subject="CN=Untrusted CA,OU=CA,$ORG" --days=$DAYS
 
-# Client 4
-mkdir -p client4
-openssl req -newkey rsa:4096 -nodes -keyout client4/key.pem -out client4/req.pem -subj "/C=CA/ST=British Columbia/L=Vancouver/O=Mohamed, Inc./OU=Client/CN=Client 3/emailAddress=mlaradji@pm.me"
-openssl x509 -CAcreateserial -req -in client4/req.pem -days 60 -CA ca2/cert.pem -CAkey ca2/key.pem -out client4/cert.pem -extfile ext.cnf
+# Client 4, which reuses client 3's subject
+$WORKER_SERVER certs client --out=client4 --issuer=ca2 --subject="CN=Client 3,OU=Client,$ORG" --days=$DAYS
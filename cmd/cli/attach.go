@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	log "github.com/sirupsen/logrus"
+)
+
+// attach attaches the local terminal to the terminal of job `jobId`, until the job is done or `detachKeys` are typed. It returns true if the client detached.
+func attach(ctx context.Context, client pb.JobServiceClient, jobId string, detachKeys []byte) (bool, error) {
+	logger := log.WithFields(log.Fields{"func": "attach", "jobId": jobId})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.JobAttach(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// the stream is shared by the input and resize loops, and gRPC streams do not support concurrent sends
+	sendMu := &sync.Mutex{}
+	send := func(req *pb.JobAttachRequest) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		req.JobId = jobId
+		return stream.Send(req)
+	}
+
+	// the first request attaches to the job, and matches the job's terminal to the local one
+	req := &pb.JobAttachRequest{}
+	if isTerminal(os.Stdin) {
+		req.Resize, err = getTerminalSize(os.Stdin)
+		if err != nil {
+			return false, err
+		}
+
+		restore, err := makeRaw(os.Stdin)
+		if err != nil {
+			return false, err
+		}
+		defer restore()
+
+		resized := make(chan os.Signal, 1)
+		notifyResize(resized)
+		go func() {
+			for range resized {
+				if size, err := getTerminalSize(os.Stdin); err == nil {
+					send(&pb.JobAttachRequest{Resize: size})
+				}
+			}
+		}()
+	}
+	if err := send(req); err != nil {
+		return false, err
+	}
+
+	// forward input until it ends or the detach keys are typed
+	detached := make(chan struct{})
+	go func() {
+		detector := &detachDetector{keys: detachKeys}
+		buffer := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if n > 0 {
+				input, detach := detector.scan(buffer[:n])
+				if len(input) > 0 {
+					if err := send(&pb.JobAttachRequest{Stdin: input}); err != nil {
+						return
+					}
+				}
+				if detach {
+					close(detached)
+					cancel()
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					logger.WithError(err).Debug("unable to read input")
+				}
+				sendMu.Lock()
+				stream.CloseSend()
+				sendMu.Unlock()
+				return
+			}
+		}
+	}()
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			select {
+			case <-detached:
+				return true, nil
+			default:
+			}
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+
+		os.Stdout.Write(res.GetOutput())
+	}
+}
+
+// detachDetector finds a key sequence in a stream of input, which can be split across reads.
+type detachDetector struct {
+	keys    []byte
+	matched int // matched is the number of keys at the end of the input so far that match the start of the sequence, and were held back.
+}
+
+// scan returns the part of `data` that should be passed on, and true if the key sequence was completed. Keys that could start the sequence are held back until they are known not to.
+func (detector *detachDetector) scan(data []byte) ([]byte, bool) {
+	if len(detector.keys) == 0 {
+		return data, false
+	}
+
+	output := []byte{}
+	for _, b := range data {
+		if b == detector.keys[detector.matched] {
+			detector.matched++
+			if detector.matched == len(detector.keys) {
+				detector.matched = 0
+				return output, true
+			}
+			continue
+		}
+
+		// pass on the keys that were held back, and check whether this key starts the sequence again
+		output = append(output, detector.keys[:detector.matched]...)
+		detector.matched = 0
+		if b == detector.keys[0] {
+			detector.matched = 1
+			continue
+		}
+		output = append(output, b)
+	}
+
+	return output, false
+}
+
+// parseDetachKeys parses a comma-separated key sequence, e.g. "ctrl-p,ctrl-q". Keys are either a single character, or ctrl- followed by a letter or one of @[\]^_.
+func parseDetachKeys(keys string) ([]byte, error) {
+	sequence := []byte{}
+	if keys == "" {
+		return sequence, nil
+	}
+
+	for _, key := range strings.Split(keys, ",") {
+		switch {
+		case len(key) == 1:
+			sequence = append(sequence, key[0])
+		case len(key) == len("ctrl-x") && strings.HasPrefix(key, "ctrl-"):
+			c := key[len(key)-1]
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			if c < '@' || c > '_' {
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+			sequence = append(sequence, c-'@')
+		default:
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+
+	return sequence, nil
+}
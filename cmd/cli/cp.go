@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mlaradji/int-backend-mohamed/pb"
+)
+
+// parseJobPath splits a path of the cp command in <jobId>:<path> form. It returns an empty job id for local paths.
+func parseJobPath(arg string) (string, string) {
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 2 {
+		if _, err := uuid.Parse(parts[0]); err == nil {
+			return parts[0], parts[1]
+		}
+	}
+
+	return "", arg
+}
+
+// uploadFile uploads the local file `localPath` to `remotePath` in the workspace of job `jobId`. If `remotePath` is empty or ends with a slash, the file keeps its name.
+func uploadFile(ctx context.Context, client pb.JobServiceClient, localPath string, jobId string, remotePath string) (*pb.FileUploadResponse, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", localPath)
+	}
+
+	if remotePath == "" || strings.HasSuffix(remotePath, "/") {
+		remotePath += filepath.Base(localPath)
+	}
+
+	stream, err := client.FileUpload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// if a send fails, the server's error is returned by CloseAndRecv
+	hash := sha256.New()
+	req := &pb.FileUploadRequest{JobId: jobId, Path: remotePath, Mode: uint32(info.Mode().Perm())}
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buffer)
+		if n > 0 {
+			hash.Write(buffer[:n])
+			req.Data = buffer[:n]
+			if err := stream.Send(req); err != nil {
+				break
+			}
+			req = &pb.FileUploadRequest{JobId: jobId}
+		}
+		if err == io.EOF {
+			req.Data = nil
+			req.Sha256 = hex.EncodeToString(hash.Sum(nil))
+			stream.Send(req)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// fileReceiver is the receiving side of a FileDownload or ArtifactDownload stream.
+type fileReceiver interface {
+	Recv() (*pb.FileDownloadResponse, error)
+}
+
+// downloadFile receives the file `remotePath` from `stream`, and writes it to the local file `localPath`, or into it if it is a directory. The local file is only replaced once the size and checksum of the download were verified. It returns the size of the file.
+func downloadFile(stream fileReceiver, remotePath string, localPath string) (uint64, error) {
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	// the first response announces the size and mode of the file
+	res, err := stream.Recv()
+	if err != nil {
+		return 0, err
+	}
+	size, mode := res.GetSize(), os.FileMode(res.GetMode())
+
+	file, err := ioutil.TempFile(filepath.Dir(localPath), "."+filepath.Base(localPath)+".download-")
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		file.Close()
+		if !committed {
+			os.Remove(file.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	writer := io.MultiWriter(file, hash)
+	received := uint64(0)
+	for {
+		received += uint64(len(res.GetData()))
+		if received > size {
+			return 0, fmt.Errorf("received more than the announced %d bytes", size)
+		}
+		if _, err := writer.Write(res.GetData()); err != nil {
+			return 0, err
+		}
+
+		// the last response carries the checksum of the file
+		if checksum := res.GetSha256(); checksum != "" {
+			if received != size {
+				return 0, fmt.Errorf("received %d of the announced %d bytes", received, size)
+			}
+			if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(checksum, sum) {
+				return 0, fmt.Errorf("checksum %s of the downloaded file does not match %s", sum, checksum)
+			}
+			break
+		}
+
+		res, err = stream.Recv()
+		if err == io.EOF {
+			return 0, fmt.Errorf("the download ended without a checksum")
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := file.Chmod(mode); err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(file.Name(), localPath); err != nil {
+		return 0, err
+	}
+	committed = true
+
+	return size, nil
+}
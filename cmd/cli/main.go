@@ -1,39 +1,76 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	"github.com/mlaradji/int-backend-mohamed/pb"
 	"github.com/mlaradji/int-backend-mohamed/service"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
+// startPollInterval is how often the status of a job that waits to be started is checked.
+const startPollInterval = 500 * time.Millisecond
+
 // Usage is the help docs, which docopt can directly parse.
 const Usage = `Usage:
-	worker-cli [options] start -- <command> [<args>...]
-	worker-cli [options] (stop|status|logs) <jobId>
+	worker-cli [options] [--env=<var>]... [--secret-env=<var=name>]... [--secret-file=<file=name>]... [--artifact=<pattern>]... start -- <command> [<args>...]
+	worker-cli [options] (stop|status|logs|approve|attach|release) <jobId>
+	worker-cli [options] cp <src> <dst>
+	worker-cli [options] artifact <jobId> <name> <dst>
+	worker-cli [options] list
+	worker-cli [options] token [--ttl=<dur>]
 	worker-cli -h | --help
 	worker-cli --version
 
 Options:
-	-h --help             Show this screen.
-	--version             Show version.
-	--debug               Set log level to DEBUG.
-	--address=<addr>      Server address and port [default: 0.0.0.0:8000]
-	--cert=<cert>         Path to the client certificate for mTLS. [default: certs/client1/cert.pem]
-	--key=<key>           Path to the client key for mTLS. [default: certs/client1/key.pem]
-	--ca=<ca>             Path to the CA certificate for the server for mTLS. [default: certs/ca1/cert.pem]
+	-h --help                  Show this screen.
+	--version                  Show version.
+	--debug                    Set log level to DEBUG.
+	--address=<addr>           Server address and port, or the path to the server's Unix socket as unix:<path>. [default: 0.0.0.0:8000]
+	--cert=<cert>              Path to the client certificate for mTLS. [default: certs/client1/cert.pem]
+	--key=<key>                Path to the client key for mTLS. [default: certs/client1/key.pem]
+	--key-passphrase-file=<f>  Path to a file containing the passphrase of the client key, if it is encrypted.
+	--ca=<ca>                  Comma-separated paths to the trusted CA certificates for the server for mTLS. [default: certs/ca1/cert.pem]
+	--group=<group>            Share a started job with the members of a group.
+	--rootfs=<name>            Run a started job in one of the server's root filesystems.
+	--keep-workspace=<when>    When to keep a started job's workspace after it is done: one of never|always|on-failure. [default: never]
+	--tmpfs=<mib>              Use a tmpfs of this size in MiB as a started job's workspace, instead of a directory on the server's disk. [default: 0]
+	--env=<var>                Environment variable of a started job in name=value form, which can be repeated.
+	--env-base=<base>          Environment that a started job's variables are added to: one of inherit|empty|allowlist. [default: inherit]
+	--workdir=<dir>            Working directory of a started job. Relative paths are created in the job's workspace.
+	--secret-env=<var=name>    Set an environment variable of a started job to a secret of the server's secret store, which can be repeated.
+	--secret-file=<file=name>  Write a secret of the server's secret store to a file in a started job's secrets directory, which can be repeated.
+	--tty                      Run a started job in a pseudo-terminal of the local terminal's size, which can be attached to.
+	--stdin                    Pipe the local stdin to a started job's stdin once it runs, which is closed once the local one ends.
+	--hold                     Hold a started job until it is released, so that files can be copied to it first.
+	--artifact=<pattern>       Collect the files in a started job's workspace that match this pattern as artifacts when the job is done, which can be repeated.
+	--detach-keys=<keys>       Comma-separated key sequence that detaches from a job's terminal, or an empty string to disable detaching. [default: ctrl-p,ctrl-q]
+	--token-file=<f>           Path to a file containing a bearer token, which is used instead of the client certificate.
+	--ttl=<dur>                Requested lifetime of the issued token. Defaults to the server's maximum.
 
 Commands:
-	start     Start a new job for the input command. If successful, the new job id will be printed.
-	stop      Stop a job. No error is emitted if job is already done or stopped.
-	status    Query the status and other information of a job. The status of a job is one of created|running|succeeded|failed|stopped.
-	logs      Follow logs (STDOUT+STDERR) of a job.`
+	start     Start a new job for the input command. If successful, the new job id will be printed. Jobs beyond the server's concurrency limit are queued, and their position is shown in their status.
+	stop      Stop a job, or remove it from the queue. No error is emitted if job is already done or stopped.
+	status    Query the status and other information of a job. The status of a job is one of queued|running|succeeded|failed|stopped|pending_approval|held.
+	logs      Follow logs (STDOUT+STDERR) of a job.
+	list      List the status and other information of all jobs that the client is allowed to view.
+	approve   Approve and start a job that the command policy holds for approval. Only admins can approve jobs, and not their own.
+	attach    Attach the local terminal to the terminal of a job started with --tty, until the job is done or the detach keys are typed.
+	release   Run a job that was started with --hold.
+	cp        Copy a file to or from a job's workspace. The path in the job is given as <jobId>:<path>, relative to its workspace. Files can only be copied to jobs started with --hold.
+	artifact  Download an artifact that was collected from a job, to a local file or directory. Artifacts are listed in the job's status.
+	token     Issue a short-lived bearer token for the client, e.g. for CI runners. Only clients that authenticate with a certificate can issue tokens.`
 
 // Configuration contains all variables that were passed (implicity or explicitly) to the command.
 type Configuration struct {
@@ -44,13 +81,38 @@ type Configuration struct {
 	Cert    string `docopt:"--cert"`
 	Key     string `docopt:"--key"`
 	CA      string `docopt:"--ca"`
+	Group   string `docopt:"--group"`
+	Rootfs  string `docopt:"--rootfs"`
+
+	KeepWorkspace string   `docopt:"--keep-workspace"`
+	Tmpfs         int      `docopt:"--tmpfs"`
+	Env           []string `docopt:"--env"`
+	EnvBase       string   `docopt:"--env-base"`
+	Workdir       string   `docopt:"--workdir"`
+	SecretEnv     []string `docopt:"--secret-env"`
+	SecretFile    []string `docopt:"--secret-file"`
+	TTY           bool     `docopt:"--tty"`
+	Stdin         bool     `docopt:"--stdin"`
+	Hold          bool     `docopt:"--hold"`
+	Artifacts     []string `docopt:"--artifact"`
+	DetachKeys    string   `docopt:"--detach-keys"`
+
+	KeyPassphraseFile string `docopt:"--key-passphrase-file"`
+	TokenFile         string `docopt:"--token-file"`
 
 	// chosen sub-command
 
-	Start  bool `docopt:"start"`
-	Logs   bool `docopt:"logs"`
-	Status bool `docopt:"status"`
-	Stop   bool `docopt:"stop"`
+	Start    bool `docopt:"start"`
+	Logs     bool `docopt:"logs"`
+	Status   bool `docopt:"status"`
+	Stop     bool `docopt:"stop"`
+	List     bool `docopt:"list"`
+	Token    bool `docopt:"token"`
+	Approve  bool `docopt:"approve"`
+	Attach   bool `docopt:"attach"`
+	Release  bool `docopt:"release"`
+	Cp       bool `docopt:"cp"`
+	Artifact bool `docopt:"artifact"`
 
 	// start job
 
@@ -58,14 +120,38 @@ type Configuration struct {
 	Command  string   `docopt:"<command>"`
 	Args     []string `docopt:"<args>"`
 
+	// issue token
+
+	TTL string `docopt:"--ttl"`
+
+	// copy files and artifacts
+
+	Src  string `docopt:"<src>"`
+	Dst  string `docopt:"<dst>"`
+	Name string `docopt:"<name>"`
+
 	// other commands
 
 	JobId string `docopt:"<jobId>"`
 }
 
 var (
-	Config         = &Configuration{}
-	TLSCredentials credentials.TransportCredentials
+	Config      = &Configuration{}
+	DialOptions = []grpc.DialOption{}
+
+	// workspaceRetentions maps the values of --keep-workspace to workspace retentions.
+	workspaceRetentions = map[string]pb.WorkspaceRetention{
+		"never":      pb.WorkspaceRetention_WORKSPACE_DELETE,
+		"always":     pb.WorkspaceRetention_WORKSPACE_RETAIN,
+		"on-failure": pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE,
+	}
+
+	// envBases maps the values of --env-base to base environments.
+	envBases = map[string]pb.EnvBase{
+		"inherit":   pb.EnvBase_ENV_INHERIT,
+		"empty":     pb.EnvBase_ENV_EMPTY,
+		"allowlist": pb.EnvBase_ENV_ALLOWLIST,
+	}
 )
 
 func init() {
@@ -89,13 +175,39 @@ func init() {
 
 	logger.WithField("Config", Config).Debug("successfully parsed configuration")
 
-	// load certificates
-	cert, certPool, err := service.LoadTLSCertificate(Config.CA, Config.Cert, Config.Key)
+	// local clients on the Unix socket are authenticated by the uid of their process, so they don't need certificates
+	if strings.HasPrefix(Config.Address, "unix:") {
+		DialOptions = append(DialOptions, grpc.WithTransportCredentials(service.PeerCredentials{}))
+		return
+	}
+
+	// load certificates, which are reloaded from disk when they change so that long-lived streams can reconnect after a renewal
+	var passphrase []byte
+	if Config.KeyPassphraseFile != "" {
+		passphrase, err = ioutil.ReadFile(Config.KeyPassphraseFile)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to read key passphrase")
+		}
+	}
+
+	// clients with a token don't present a certificate, but still verify the server's
+	certPath, keyPath := Config.Cert, Config.Key
+	if Config.TokenFile != "" {
+		token, err := ioutil.ReadFile(Config.TokenFile)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to read token")
+		}
+
+		DialOptions = append(DialOptions, grpc.WithPerRPCCredentials(service.TokenCredentials{Token: strings.TrimSpace(string(token))}))
+		certPath, keyPath = "", ""
+	}
+
+	certReloader, err := service.NewCertificateReloader(strings.Split(Config.CA, ","), certPath, keyPath, service.WithKeyPassphrase(bytes.TrimRight(passphrase, "\r\n")))
 	if err != nil {
 		logger.WithError(err).Fatal("unable to load TLS certificate")
 	}
 
-	TLSCredentials = service.MakeClientTLSCredentials(cert, certPool)
+	DialOptions = append(DialOptions, grpc.WithTransportCredentials(service.MakeReloadingClientTLSCredentials(certReloader)))
 
 	logger.Debug("successfully loaded certificates")
 }
@@ -103,7 +215,7 @@ func init() {
 func main() {
 	logger := log.WithField("func", "main")
 
-	conn, err := grpc.Dial(Config.Address, grpc.WithTransportCredentials(TLSCredentials))
+	conn, err := grpc.Dial(Config.Address, DialOptions...)
 	if err != nil {
 		logger.WithError(err).Fatal("cannot dial server")
 	}
@@ -120,14 +232,178 @@ func main() {
 	logger.Debug("successfully initialized client")
 
 	if Config.Start {
+		retention, ok := workspaceRetentions[Config.KeepWorkspace]
+		if !ok {
+			logger.WithField("keepWorkspace", Config.KeepWorkspace).Fatal("--keep-workspace must be one of never|always|on-failure")
+		}
+		if Config.Stdin && Config.Hold {
+			logger.Fatal("--stdin can not be combined with --hold, since stdin is sent once the job runs")
+		}
+		if Config.Tmpfs < 0 {
+			logger.WithField("tmpfs", Config.Tmpfs).Fatal("--tmpfs can not be negative")
+		}
+		envBase, ok := envBases[Config.EnvBase]
+		if !ok {
+			logger.WithField("envBase", Config.EnvBase).Fatal("--env-base must be one of inherit|empty|allowlist")
+		}
+		env, err := service.ParseEnv(Config.Env)
+		if err != nil {
+			logger.WithError(err).Fatal("invalid --env")
+		}
+		secrets, err := secretRefs(Config.SecretEnv, Config.SecretFile)
+		if err != nil {
+			logger.WithError(err).Fatal("invalid secret reference")
+		}
+
 		// start a new job
-		res, err := client.JobStart(ctx, &pb.JobStartRequest{Command: Config.Command, Args: Config.Args})
+		header := metadata.MD{}
+		req := &pb.JobStartRequest{
+			Command:            Config.Command,
+			Args:               Config.Args,
+			Group:              Config.Group,
+			Rootfs:             Config.Rootfs,
+			WorkspaceRetention: retention,
+			WorkspaceTmpfsMib:  uint64(Config.Tmpfs),
+			Env:                env,
+			EnvBase:            envBase,
+			WorkingDir:         Config.Workdir,
+			Secrets:            secrets,
+			Tty:                Config.TTY,
+			Stdin:              Config.Stdin,
+			Hold:               Config.Hold,
+			Artifacts:          Config.Artifacts,
+		}
+		if Config.TTY && isTerminal(os.Stdin) {
+			req.TtySize, err = getTerminalSize(os.Stdin)
+			if err != nil {
+				logger.WithError(err).Fatal("unable to get terminal size")
+			}
+		}
+		res, err := client.JobStart(ctx, req, grpc.Header(&header))
 		if err != nil {
+			// quota errors tell us when to try again
+			if retryAfter := header.Get("retry-after"); len(retryAfter) > 0 {
+				logger = logger.WithField("retryAfterSeconds", retryAfter[0])
+			}
 			logger.WithError(err).Fatal("received an error response")
 		}
 
-		logger.WithField("jobId", res.GetJobId()).Info("job was started successfully")
+		switch res.GetJobStatus() {
+		case pb.JobStatus_QUEUED:
+			logger.WithField("jobId", res.GetJobId()).Info("job is queued until a slot is free")
+		case pb.JobStatus_HELD:
+			logger.WithField("jobId", res.GetJobId()).Info("job is held until it is released")
+		case pb.JobStatus_PENDING_APPROVAL:
+			logger.WithField("jobId", res.GetJobId()).Info("job is pending approval by an admin")
+		default:
+			logger.WithField("jobId", res.GetJobId()).Info("job was started successfully")
+		}
 		fmt.Printf("JobId: %s", res.GetJobId())
+
+		if Config.Stdin {
+			// stdin can only be written once the job runs, which may be after it waited in the queue or for approval
+			if err := waitUntilStarted(ctx, client, res.GetJobId()); err != nil {
+				logger.WithError(err).Fatal("failed while waiting for the job to start")
+			}
+			written, err := sendStdin(ctx, client, res.GetJobId(), os.Stdin)
+			if err != nil {
+				logger.WithError(err).Fatal("failed while sending stdin")
+			}
+			logger.WithField("bytesWritten", written).Info("stdin was sent and closed")
+		}
+		return
+	}
+
+	if Config.Approve {
+		// approve a job that is pending approval
+		_, err := client.JobApprove(ctx, &pb.JobApproveRequest{JobId: Config.JobId})
+		if err != nil {
+			logger.WithError(err).Fatal("received an error response")
+		}
+
+		logger.Info("job was approved, and is started once a slot is free")
+		return
+	}
+
+	if Config.Release {
+		// run a held job
+		res, err := client.JobRelease(ctx, &pb.JobReleaseRequest{JobId: Config.JobId})
+		if err != nil {
+			logger.WithError(err).Fatal("received an error response")
+		}
+
+		switch res.GetJobStatus() {
+		case pb.JobStatus_PENDING_APPROVAL:
+			logger.Info("job was released, and is pending approval by an admin")
+		case pb.JobStatus_QUEUED:
+			logger.Info("job was released, and is queued until a slot is free")
+		default:
+			logger.Info("job was released and started")
+		}
+		return
+	}
+
+	if Config.Cp {
+		// copy a file to or from a job's workspace
+		srcJobId, srcPath := parseJobPath(Config.Src)
+		dstJobId, dstPath := parseJobPath(Config.Dst)
+
+		switch {
+		case srcJobId == "" && dstJobId != "":
+			res, err := uploadFile(ctx, client, srcPath, dstJobId, dstPath)
+			if err != nil {
+				logger.WithError(err).Fatal("failed to upload file")
+			}
+			logger.WithFields(log.Fields{"size": res.GetSize(), "sha256": res.GetSha256()}).Info("file was uploaded")
+		case srcJobId != "" && dstJobId == "":
+			stream, err := client.FileDownload(ctx, &pb.FileDownloadRequest{JobId: srcJobId, Path: srcPath})
+			if err != nil {
+				logger.WithError(err).Fatal("received an error response")
+			}
+			size, err := downloadFile(stream, srcPath, dstPath)
+			if err != nil {
+				logger.WithError(err).Fatal("failed to download file")
+			}
+			logger.WithField("size", size).Info("file was downloaded")
+		default:
+			logger.Fatal("exactly one of <src> and <dst> must be a path in a job, in <jobId>:<path> form")
+		}
+		return
+	}
+
+	if Config.Artifact {
+		// download an artifact of a job
+		stream, err := client.ArtifactDownload(ctx, &pb.ArtifactDownloadRequest{JobId: Config.JobId, Name: Config.Name})
+		if err != nil {
+			logger.WithError(err).Fatal("received an error response")
+		}
+
+		size, err := downloadFile(stream, Config.Name, Config.Dst)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to download artifact")
+		}
+
+		logger.WithField("size", size).Info("artifact was downloaded")
+		return
+	}
+
+	if Config.Attach {
+		// attach to a job's terminal
+		detachKeys, err := parseDetachKeys(Config.DetachKeys)
+		if err != nil {
+			logger.WithError(err).Fatal("invalid --detach-keys")
+		}
+
+		detached, err := attach(ctx, client, Config.JobId, detachKeys)
+		if err != nil {
+			logger.WithError(err).Fatal("failed while attached to job")
+		}
+
+		if detached {
+			logger.Info("detached from job")
+		} else {
+			logger.Info("done attaching - job is not running")
+		}
 		return
 	}
 
@@ -154,6 +430,41 @@ func main() {
 		return
 	}
 
+	if Config.List {
+		// list all visible jobs
+		res, err := client.JobList(ctx, &pb.JobListRequest{})
+		if err != nil {
+			logger.WithError(err).Fatal("received an error response")
+		}
+
+		logger.Debug("jobs successfully listed")
+		for _, jobInfo := range res.GetJobInfos() {
+			fmt.Printf("%s\n", jobInfo)
+		}
+		return
+	}
+
+	if Config.Token {
+		// issue a bearer token
+		req := &pb.TokenIssueRequest{}
+		if Config.TTL != "" {
+			ttl, err := time.ParseDuration(Config.TTL)
+			if err != nil {
+				logger.WithError(err).Fatal("unable to parse token lifetime")
+			}
+			req.Ttl = durationpb.New(ttl)
+		}
+
+		res, err := client.TokenIssue(ctx, req)
+		if err != nil {
+			logger.WithError(err).Fatal("received an error response")
+		}
+
+		logger.WithField("expiresAt", res.GetExpiresAt().AsTime()).Info("token was issued successfully")
+		fmt.Println(res.GetToken())
+		return
+	}
+
 	if Config.Logs {
 		// follow a job's logs
 		logStream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: Config.JobId})
@@ -177,3 +488,82 @@ func main() {
 		return
 	}
 }
+
+// secretRefs parses the secret references of --secret-env and --secret-file.
+func secretRefs(envRefs []string, fileRefs []string) ([]*pb.SecretRef, error) {
+	refs := []*pb.SecretRef{}
+	for _, ref := range envRefs {
+		env, name, err := parseSecretRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, &pb.SecretRef{Name: name, Env: env})
+	}
+	for _, ref := range fileRefs {
+		file, name, err := parseSecretRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, &pb.SecretRef{Name: name, File: file})
+	}
+
+	return refs, nil
+}
+
+// parseSecretRef splits a secret reference in target=name form.
+func parseSecretRef(ref string) (string, string, error) {
+	parts := strings.SplitN(ref, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("secret reference %q must be in target=name form", ref)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// waitUntilStarted polls the status of job `jobId` until it is no longer queued or pending approval.
+func waitUntilStarted(ctx context.Context, client pb.JobServiceClient, jobId string) error {
+	for {
+		res, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+		if err != nil {
+			return err
+		}
+		if jobStatus := res.GetJobInfo().GetJobStatus(); jobStatus != pb.JobStatus_QUEUED && jobStatus != pb.JobStatus_PENDING_APPROVAL {
+			return nil
+		}
+
+		time.Sleep(startPollInterval)
+	}
+}
+
+// sendStdin writes everything read from `input` to the stdin of job `jobId`, and then closes it. It returns the number of bytes written.
+func sendStdin(ctx context.Context, client pb.JobServiceClient, jobId string, input io.Reader) (uint64, error) {
+	stream, err := client.JobStdin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// if a send fails, the server's error is returned by CloseAndRecv
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := input.Read(buffer)
+		if n > 0 {
+			if err := stream.Send(&pb.JobStdinRequest{JobId: jobId, Data: buffer[:n]}); err != nil {
+				break
+			}
+		}
+		if err == io.EOF {
+			stream.Send(&pb.JobStdinRequest{JobId: jobId, Close: true})
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.GetBytesWritten(), nil
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal returns true if `file` is a terminal.
+func isTerminal(file *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(file.Fd()), unix.TCGETS)
+	return err == nil
+}
+
+// makeRaw puts the terminal `file` into raw mode, so that input is passed on as it is typed and without being echoed, and returns a function that restores its previous mode.
+func makeRaw(file *os.File) (func() error, error) {
+	fd := int(file.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	previous := *termios
+
+	// as in cfmakeraw(3)
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(fd, unix.TCSETS, &previous)
+	}, nil
+}
+
+// getTerminalSize returns the size of the terminal `file`.
+func getTerminalSize(file *os.File) (*pb.TerminalSize, error) {
+	winsize, err := unix.IoctlGetWinsize(int(file.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.TerminalSize{Rows: uint32(winsize.Row), Cols: uint32(winsize.Col)}, nil
+}
+
+// notifyResize relays the signals that are sent when the size of the terminal changes to `signals`.
+func notifyResize(signals chan<- os.Signal) {
+	signal.Notify(signals, syscall.SIGWINCH)
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+)
+
+var (
+	errTerminalUnsupported = errors.New("terminals are only supported on Linux")
+)
+
+// isTerminal always returns false, since terminals are not supported on this platform.
+func isTerminal(file *os.File) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform.
+func makeRaw(file *os.File) (func() error, error) {
+	return nil, errTerminalUnsupported
+}
+
+// getTerminalSize is not supported on this platform.
+func getTerminalSize(file *os.File) (*pb.TerminalSize, error) {
+	return nil, errTerminalUnsupported
+}
+
+// notifyResize does nothing, since terminals are not supported on this platform.
+func notifyResize(signals chan<- os.Signal) {}
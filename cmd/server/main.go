@@ -1,7 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	"github.com/mlaradji/int-backend-mohamed/pb"
@@ -10,19 +23,82 @@ import (
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
 )
 
+// artifactPruneInterval is how often artifacts that outlived their TTL are removed.
+const artifactPruneInterval = time.Hour
+
 // Usage is the help docs, which docopt can directly parse.
 const Usage = `Usage:
 	worker-server [options]
+	worker-server certs (ca|server|client) --out=<dir> [options]
+	worker-server audit verify <file> [options]
+	worker-server policy check (--user=<id>|--role=<role>) [--env=<var>]... [options] [--] <command> [<args>...]
+	worker-server secrets (set|rm) <name> [options]
+	worker-server secrets list [options]
 
 Options:
-	-h --help             Show this screen.
-	--debug               Set log level to DEBUG.
-	--address=<addr>      Server address and port [default: 0.0.0.0:8000]
-	--cert=<cert>         Path to the server certificate for mTLS. [default: certs/server/cert.pem]
-	--key=<key>           Path to the server key for mTLS. [default: certs/server/key.pem]
-	--ca=<ca>             Path to the CA certificate for mTLS. [default: certs/ca1/cert.pem]`
+	-h --help                    Show this screen.
+	--debug                      Set log level to DEBUG.
+	--address=<addr>             Server address and port [default: 0.0.0.0:8000]
+	--socket=<path>              Also listen on a Unix socket at this path, authenticating local clients by the uid of their process.
+	--cert=<cert>                Path to the server certificate for mTLS. [default: certs/server/cert.pem]
+	--key=<key>                  Path to the server key for mTLS. [default: certs/server/key.pem]
+	--key-passphrase-file=<f>    Path to a file containing the passphrase of the server key, if it is encrypted.
+	--ca=<ca>                    Comma-separated paths to the trusted CA certificates for mTLS. [default: certs/ca1/cert.pem]
+	--auth=<auth>                Path to the authorization config (YAML or JSON). [default: config/auth.yaml]
+	--crl=<crl>                  Path to a CRL signed by the CA. Client certificates it revokes are rejected.
+	--crl-interval=<dur>         How often the CRL is reloaded from disk. [default: 1m]
+	--token-key=<f>              Path to the key that signs bearer tokens: an Ed25519 private key in PEM format, or an HMAC secret of at least 32 bytes. Enables token authentication as an alternative to client certificates.
+	--token-max-ttl=<dur>        Maximum lifetime of issued bearer tokens. [default: 1h]
+	--audit-log=<path>           Append a hash-chained record of every API call to this file. Requires --audit-key.
+	--audit-key=<f>              Path to the key that the records of the audit log are authenticated with: 32 random bytes, raw or base64-encoded. Keep it outside the log's directory.
+	--log-dir=<dir>              Directory that the logs of jobs are kept in, which only the server can access. [default: tmp/jobs]
+	--workspace-dir=<dir>        Directory that the workspaces of jobs are created in. Users that jobs run as must be able to search the directories above it. [default: tmp/workspaces]
+	--rootfs-dir=<dir>           Let jobs run in the root filesystems in this directory: directories, OCI image layouts and OCI image tarballs.
+	--rootfs-cache=<dir>         Directory that images from --rootfs-dir are unpacked into. [default: tmp/rootfs]
+	--max-workspace-tmpfs=<mib>  Largest tmpfs in MiB that jobs can request as their workspace. 0 disables tmpfs workspaces. [default: 1024]
+	--max-file-size=<mib>        Largest file in MiB that can be uploaded to or downloaded from a job's workspace, or collected as an artifact. 0 disables file transfers and artifacts. [default: 1024]
+	--artifact-dir=<dir>         Directory that artifacts collected from jobs are kept in. [default: tmp/artifacts]
+	--max-job-artifacts=<mib>    Largest total size in MiB of the artifacts collected from one job. 0 means no limit. [default: 4096]
+	--artifact-ttl=<dur>         How long artifacts are kept after they were last collected. 0 keeps them forever. [default: 168h]
+	--max-upload=<mib>           Largest total size in MiB of the files uploaded to one held job, including replaced files and failed uploads. 0 means no limit. [default: 4096]
+	--max-upload-files=<n>       Largest number of files uploaded to one held job. 0 means no limit. [default: 1000]
+	--hold-timeout=<dur>         How long a held job waits to be released before it is stopped. 0 means no limit. [default: 1h]
+	--max-concurrency=<n>        Largest number of jobs that run at the same time. Further jobs are queued, and started in the order they were submitted. 0 means one job per CPU. [default: 0]
+	--env-allowlist=<names>      Comma-separated variables of the server's environment that jobs with an allowlisted base environment inherit. [default: PATH,LANG,LC_ALL,TZ]
+	--env-shown=<names>          Comma-separated variables whose values job info shows. The values of all other variables that jobs set are redacted. [default: LANG,LC_ALL,TZ]
+	--secrets=<file>             Path to the encrypted secret store that jobs can reference secrets from. Requires --secrets-key.
+	--secrets-key=<f>            Path to the master key of the secret store: 32 random bytes, raw or base64-encoded.
+
+Certificate options:
+	--out=<dir>                  Directory to write the new cert.pem and key.pem to. Existing keys are never overwritten.
+	--subject=<dn>               Subject of the certificate, in the same format as the authorization config, e.g. "CN=Client 1,O=Example".
+	--dns=<names>                Comma-separated DNS Subject Alternative Names.
+	--ip=<ips>                   Comma-separated IP Subject Alternative Names.
+	--uri=<uris>                 Comma-separated URI Subject Alternative Names, e.g. a SPIFFE ID.
+	--email=<emails>             Comma-separated email Subject Alternative Names.
+	--key-type=<type>            One of ecdsa|ed25519|rsa. [default: ecdsa]
+	--days=<days>                Number of days that the certificate is valid for. [default: 365]
+	--encrypt-key=<f>            Encrypt the new key with the passphrase in this file.
+	--issuer=<dir>               Directory containing the cert.pem and key.pem of the CA that signs server and client certificates. [default: certs/ca1]
+	--issuer-passphrase-file=<f> Path to a file containing the passphrase of the CA key, if it is encrypted.
+
+Policy check options:
+	--user=<id>                  Id of the user to check the command for.
+	--role=<role>                Role of a hypothetical user to check the command for.
+	--env=<var>                  Environment variable of the job in name=value form, which can be repeated.
+
+Commands:
+	certs ca      Create a self-signed CA.
+	certs server  Issue a server certificate. At least one DNS or IP name is required.
+	certs client  Issue a client certificate, and print the entries that identify it in the authorization config.
+	audit verify  Check that an audit log was not tampered with.
+	policy check  Print whether the command policy in --auth allows a user, or any user with a role, to run a command, without running it. Exits with status 1 if the command is denied.
+	secrets set   Add a secret to the secret store in --secrets, or replace it, with the value read from STDIN.
+	secrets rm    Remove a secret from the secret store in --secrets.
+	secrets list  Print the names of the secrets in the secret store in --secrets.`
 
 // Configuration contains all variables that were passed (implicity or explicitly) to the command.
 type Configuration struct {
@@ -30,17 +106,102 @@ type Configuration struct {
 
 	Debug   bool   `docopt:"--debug"`
 	Address string `docopt:"--address"`
+	Socket  string `docopt:"--socket"`
 	Cert    string `docopt:"--cert"`
 	Key     string `docopt:"--key"`
 	CA      string `docopt:"--ca"`
+	Auth    string `docopt:"--auth"`
+
+	KeyPassphraseFile string `docopt:"--key-passphrase-file"`
+
+	CRL         string `docopt:"--crl"`
+	CRLInterval string `docopt:"--crl-interval"`
+
+	TokenKey    string `docopt:"--token-key"`
+	TokenMaxTTL string `docopt:"--token-max-ttl"`
+
+	AuditLog     string `docopt:"--audit-log"`
+	AuditKey     string `docopt:"--audit-key"`
+	LogDir       string `docopt:"--log-dir"`
+	WorkspaceDir string `docopt:"--workspace-dir"`
+	RootfsDir    string `docopt:"--rootfs-dir"`
+	RootfsCache  string `docopt:"--rootfs-cache"`
+
+	MaxWorkspaceTmpfs int    `docopt:"--max-workspace-tmpfs"`
+	MaxFileSize       int    `docopt:"--max-file-size"`
+	ArtifactDir       string `docopt:"--artifact-dir"`
+	MaxJobArtifacts   int    `docopt:"--max-job-artifacts"`
+	ArtifactTTL       string `docopt:"--artifact-ttl"`
+	MaxUpload         int    `docopt:"--max-upload"`
+	MaxUploadFiles    int    `docopt:"--max-upload-files"`
+	HoldTimeout       string `docopt:"--hold-timeout"`
+	MaxConcurrency    int    `docopt:"--max-concurrency"`
+	EnvAllowlist      string `docopt:"--env-allowlist"`
+	EnvShown          string `docopt:"--env-shown"`
+	SecretsFile       string `docopt:"--secrets"`
+	SecretsKey        string `docopt:"--secrets-key"`
+
+	// certs sub-command
+
+	Certs       bool `docopt:"certs"`
+	CertsCA     bool `docopt:"ca"`
+	CertsServer bool `docopt:"server"`
+	CertsClient bool `docopt:"client"`
+
+	Out                  string `docopt:"--out"`
+	Subject              string `docopt:"--subject"`
+	DNS                  string `docopt:"--dns"`
+	IP                   string `docopt:"--ip"`
+	URI                  string `docopt:"--uri"`
+	Email                string `docopt:"--email"`
+	KeyType              string `docopt:"--key-type"`
+	Days                 int    `docopt:"--days"`
+	EncryptKey           string `docopt:"--encrypt-key"`
+	Issuer               string `docopt:"--issuer"`
+	IssuerPassphraseFile string `docopt:"--issuer-passphrase-file"`
+
+	// audit sub-command
+
+	Audit       bool   `docopt:"audit"`
+	AuditVerify bool   `docopt:"verify"`
+	AuditFile   string `docopt:"<file>"`
+
+	// secrets sub-command
+
+	Secrets     bool   `docopt:"secrets"`
+	SecretsSet  bool   `docopt:"set"`
+	SecretsRm   bool   `docopt:"rm"`
+	SecretsList bool   `docopt:"list"`
+	SecretName  string `docopt:"<name>"`
+
+	// policy sub-command
+
+	Policy      bool     `docopt:"policy"`
+	PolicyCheck bool     `docopt:"check"`
+	User        string   `docopt:"--user"`
+	Role        string   `docopt:"--role"`
+	Env         []string `docopt:"--env"`
+	DashDash    bool     `docopt:"--"`
+	Command     string   `docopt:"<command>"`
+	Args        []string `docopt:"<args>"`
 }
 
 var (
 	Config         = &Configuration{}
 	TLSCredentials credentials.TransportCredentials
+	Policy         *service.Policy
+	CRL            *service.RevocationList
+	CRLInterval    time.Duration
+	HoldTimeout    time.Duration
+	ArtifactTTL    time.Duration
+	Tokens         *service.TokenAuthority
+	Secrets        *service.SecretStore
 )
 
 func init() {
+	// jobs with a security profile are started through this binary, which applies the profile and executes the job's command
+	worker.RunShimIfRequested()
+
 	logger := log.WithField("func", "init")
 
 	opts, err := docopt.ParseDoc(Usage)
@@ -61,28 +222,280 @@ func init() {
 
 	logger.WithField("Config", Config).Debug("successfully parsed configuration")
 
-	// load certificates
-	cert, certPool, err := service.LoadTLSCertificate(Config.CA, Config.Cert, Config.Key)
+	// the sub-commands do not serve, so they don't need the server's certificates or authorization config
+	if Config.Certs || Config.Audit || Config.Policy {
+		return
+	}
+
+	// load the secret store, which the secrets sub-command manages
+	if (Config.SecretsFile == "") != (Config.SecretsKey == "") {
+		logger.Fatal("--secrets and --secrets-key must be set together")
+	}
+	if Config.SecretsFile != "" {
+		Secrets, err = service.LoadSecretStore(Config.SecretsFile, Config.SecretsKey)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to load secret store key")
+		}
+	}
+	if Config.Secrets {
+		if Secrets == nil {
+			logger.Fatal("the secrets sub-command requires --secrets and --secrets-key")
+		}
+		return
+	}
+
+	if Config.MaxWorkspaceTmpfs < 0 {
+		logger.WithField("maxWorkspaceTmpfs", Config.MaxWorkspaceTmpfs).Fatal("tmpfs workspace size cap can not be negative")
+	}
+	if Config.MaxFileSize < 0 {
+		logger.WithField("maxFileSize", Config.MaxFileSize).Fatal("file size cap can not be negative")
+	}
+	if Config.MaxJobArtifacts < 0 {
+		logger.WithField("maxJobArtifacts", Config.MaxJobArtifacts).Fatal("artifact size cap can not be negative")
+	}
+	ArtifactTTL, err = time.ParseDuration(Config.ArtifactTTL)
+	if err != nil || ArtifactTTL < 0 {
+		logger.WithField("artifactTTL", Config.ArtifactTTL).Fatal("artifact TTL must be a non-negative duration")
+	}
+	if Config.MaxUpload < 0 || Config.MaxUploadFiles < 0 {
+		logger.WithFields(log.Fields{"maxUpload": Config.MaxUpload, "maxUploadFiles": Config.MaxUploadFiles}).Fatal("upload limits can not be negative")
+	}
+	HoldTimeout, err = time.ParseDuration(Config.HoldTimeout)
+	if err != nil || HoldTimeout < 0 {
+		logger.WithField("holdTimeout", Config.HoldTimeout).Fatal("hold timeout must be a non-negative duration")
+	}
+	if Config.MaxConcurrency < 0 {
+		logger.WithField("maxConcurrency", Config.MaxConcurrency).Fatal("concurrency limit can not be negative")
+	}
+	if Config.MaxConcurrency == 0 {
+		Config.MaxConcurrency = runtime.NumCPU()
+	}
+
+	// load certificates, which are reloaded from disk when they change
+	passphrase, err := readPassphrase(Config.KeyPassphraseFile)
+	if err != nil {
+		logger.WithError(err).Fatal("unable to read key passphrase")
+	}
+
+	caCertPaths := strings.Split(Config.CA, ",")
+	certReloader, err := service.NewCertificateReloader(caCertPaths, Config.Cert, Config.Key, service.WithKeyPassphrase(passphrase))
 	if err != nil {
 		logger.WithError(err).Fatal("unable to load TLS certificate")
 	}
 
-	TLSCredentials = service.MakeServerTLSCredentials(cert, certPool)
+	// load certificate revocation list
+	if Config.CRL != "" {
+		CRLInterval, err = time.ParseDuration(Config.CRLInterval)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to parse CRL reload interval")
+		}
+
+		CRL, err = service.LoadRevocationList(Config.CRL, caCertPaths...)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to load CRL")
+		}
+	}
+
+	// load token signing key. Client certificates become optional, since clients can authenticate with a token instead
+	clientAuth := tls.RequireAndVerifyClientCert
+	if Config.TokenKey != "" {
+		tokenMaxTTL, err := time.ParseDuration(Config.TokenMaxTTL)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to parse maximum token lifetime")
+		}
+
+		Tokens, err = service.LoadTokenAuthority(Config.TokenKey, tokenMaxTTL)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to load token key")
+		}
+
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	TLSCredentials = service.MakeReloadingServerTLSCredentials(certReloader, clientAuth)
 
 	logger.Debug("successfully loaded certificates")
+
+	// load authorization config
+	Policy, err = service.LoadPolicy(Config.Auth)
+	if err != nil {
+		logger.WithError(err).Fatal("unable to load authorization config")
+	}
+
+	logger.Debug("successfully loaded authorization config")
 }
 
 func main() {
+	if Config.Certs {
+		err := certs()
+		if err != nil {
+			log.WithField("func", "main").WithError(err).Fatal("unable to create certificate")
+		}
+		return
+	}
+
+	if Config.Policy {
+		allowed, err := policyCheck()
+		if err != nil {
+			log.WithFields(log.Fields{"func": "main", "auth": Config.Auth}).WithError(err).Fatal("unable to check command")
+		}
+		if !allowed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if Config.Secrets {
+		err := secrets()
+		if err != nil {
+			log.WithFields(log.Fields{"func": "main", "secrets": Config.SecretsFile}).WithError(err).Fatal("unable to manage secrets")
+		}
+		return
+	}
+
+	if Config.Audit {
+		logger := log.WithFields(log.Fields{"func": "main", "file": Config.AuditFile})
+		if Config.AuditKey == "" {
+			logger.Fatal("audit verify requires --audit-key")
+		}
+		key, err := service.LoadAuditKey(Config.AuditKey)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to load audit log key")
+		}
+
+		last, err := service.VerifyAuditLog(Config.AuditFile, key)
+		if err != nil {
+			logger.WithError(err).Fatal("audit log verification failed")
+		}
+		if last == nil {
+			fmt.Printf("%s: 0 records verified\n", Config.AuditFile)
+			return
+		}
+		fmt.Printf("%s: %d records verified, the last hash is %s\n", Config.AuditFile, last.Seq, last.Hash)
+		return
+	}
+
 	logger := log.WithFields(log.Fields{"func": "main", "address": Config.Address})
 
 	// initialize job service
 	jobStore := worker.NewJobStore()
+	logDir, err := filepath.Abs(Config.LogDir)
+	if err != nil {
+		logger.WithError(err).Fatal("unable to resolve log directory")
+	}
+	workspaceDir, err := filepath.Abs(Config.WorkspaceDir)
+	if err != nil {
+		logger.WithError(err).Fatal("unable to resolve workspace directory")
+	}
+	jobStore.LogDir, jobStore.WorkspaceDir = logDir, workspaceDir
 	jobServer := service.NewJobServer(jobStore)
+	authorizer := service.NewAuthorizer(Policy, jobStore)
+	jobServer.Policy = authorizer // admit new jobs by the command policy of the current authorization config
+	jobServer.Scheduler = worker.NewScheduler(Config.MaxConcurrency)
+	if Config.RootfsDir != "" {
+		rootfsCache, err := filepath.Abs(Config.RootfsCache)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to resolve root filesystem cache directory")
+		}
+		jobServer.Rootfs = worker.NewRootfsStore(Config.RootfsDir, rootfsCache)
+	}
+	jobServer.MaxWorkspaceTmpfsMiB = uint64(Config.MaxWorkspaceTmpfs)
+	jobServer.MaxFileSizeMiB = uint64(Config.MaxFileSize)
+	jobServer.UploadLimit = worker.UploadLimit{Bytes: uint64(Config.MaxUpload) << 20, Files: Config.MaxUploadFiles}
+	jobServer.HoldTimeout = HoldTimeout
+	if Config.MaxFileSize > 0 {
+		artifactDir, err := filepath.Abs(Config.ArtifactDir)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to resolve artifact directory")
+		}
+		jobServer.Artifacts = worker.NewArtifactStore(artifactDir, int64(Config.MaxFileSize)<<20)
+		jobServer.Artifacts.MaxJobSize = int64(Config.MaxJobArtifacts) << 20
+		jobServer.Artifacts.TTL = ArtifactTTL
+		if ArtifactTTL > 0 {
+			jobServer.Artifacts.PruneEvery(make(chan struct{}), artifactPruneInterval)
+		}
+	}
+	jobServer.EnvAllowlist = strings.Split(Config.EnvAllowlist, ",")
+	jobServer.EnvShown = strings.Split(Config.EnvShown, ",")
+
+	// accept bearer tokens, and allow clients with a certificate to issue them
+	jobServer.Secrets = Secrets
+
+	if Tokens != nil {
+		jobServer.Tokens = Tokens
+		authorizer.SetTokenAuthority(Tokens)
+	}
+
+	// reload the CRL periodically, and check it on every request, so that revoked clients get an Unauthenticated error, also on connections that outlive a revocation
+	if CRL != nil {
+		CRL.Watch(make(chan struct{}), CRLInterval)
+		authorizer.SetRevocationList(CRL)
+	}
+
+	// reload the authorization config when it changes on disk, or when SIGHUP is received
+	err = authorizer.WatchPolicy(make(chan struct{}), Config.Auth)
+	if err != nil {
+		logger.WithError(err).Fatal("unable to watch authorization config")
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			logger.Info("received SIGHUP, reloading authorization config")
+			_ = authorizer.ReloadPolicy(Config.Auth) // errors are logged, and the previous policy is kept
+		}
+	}()
+
+	// initialize gRPC server with authentication, authorization and quota interceptors. Quotas are checked after authorization, since they depend on the user
+	quotas := service.NewQuotaLimiter(jobStore, authorizer)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{authorizer.UnaryAuth, quotas.UnaryQuota}
+	streamInterceptors := []grpc.StreamServerInterceptor{authorizer.StreamAuth, quotas.StreamQuota}
+
+	// audit every call, including the ones that are denied, so the audit interceptors run first
+	if Config.AuditLog != "" {
+		if Config.AuditKey == "" {
+			logger.Fatal("--audit-log requires --audit-key")
+		}
+		auditKey, err := service.LoadAuditKey(Config.AuditKey)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to load audit log key")
+		}
+
+		auditLog, err := service.OpenAuditLog(Config.AuditLog, auditKey)
+		if err != nil {
+			logger.WithError(err).Fatal("unable to open audit log")
+		}
+		defer auditLog.Close()
 
-	// initialize gRPC server with authentication and authorization interceptors
-	grpcServer := grpc.NewServer(grpc.Creds(TLSCredentials), grpc.UnaryInterceptor(service.UnaryAuth), grpc.StreamInterceptor(service.StreamAuth))
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{auditLog.UnaryAudit}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{auditLog.StreamAudit}, streamInterceptors...)
+	}
+
+	interceptors := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unaryInterceptors...), grpc.ChainStreamInterceptor(streamInterceptors...)}
+
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{grpc.Creds(TLSCredentials)}, interceptors...)...)
 	pb.RegisterJobServiceServer(grpcServer, jobServer)
 
+	// serve local clients on the Unix socket, with the same interceptors
+	if Config.Socket != "" {
+		unixServer := grpc.NewServer(append([]grpc.ServerOption{grpc.Creds(service.PeerCredentials{})}, interceptors...)...)
+		pb.RegisterJobServiceServer(unixServer, jobServer)
+
+		unixListener, err := listenUnix(Config.Socket)
+		if err != nil {
+			logger.WithError(err).WithField("socket", Config.Socket).Fatal("unable to listen on Unix socket")
+		}
+		logger.WithField("socket", Config.Socket).Info("started listening on Unix socket")
+
+		go func() {
+			err := unixServer.Serve(unixListener)
+			if err != nil {
+				logger.WithError(err).Fatal("unable to serve job server on Unix socket")
+			}
+		}()
+	}
+
 	// start listening
 	listener, err := net.Listen("tcp", Config.Address)
 	if err != nil {
@@ -96,3 +509,226 @@ func main() {
 		logger.WithError(err).Fatal("unable to serve job server on listener")
 	}
 }
+
+// secrets adds, replaces or removes a secret of the secret store, or lists the names of its secrets.
+func secrets() error {
+	switch {
+	case Config.SecretsSet:
+		value, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return Secrets.Set(Config.SecretName, value)
+	case Config.SecretsRm:
+		return Secrets.Delete(Config.SecretName)
+	}
+
+	names, err := Secrets.Names()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return nil
+}
+
+// certs creates a CA, or issues a server or client certificate signed by the CA in --issuer.
+func certs() error {
+	logger := log.WithFields(log.Fields{"func": "certs", "out": Config.Out})
+
+	template := service.CertificateTemplate{KeyType: service.KeyType(Config.KeyType), Validity: time.Duration(Config.Days) * 24 * time.Hour}
+
+	// default to a subject that describes the certificate's usage
+	subject := Config.Subject
+	switch {
+	case Config.CertsCA:
+		template.Usage = service.UsageCA
+		if subject == "" {
+			subject = "CN=Worker CA"
+		}
+	case Config.CertsServer:
+		template.Usage = service.UsageServer
+		if subject == "" {
+			subject = "CN=Server"
+		}
+	case Config.CertsClient:
+		template.Usage = service.UsageClient
+		if subject == "" {
+			return errors.New("--subject is required for client certificates, since it identifies the client")
+		}
+	}
+
+	var err error
+	template.Subject, err = service.ParseDistinguishedName(subject)
+	if err != nil {
+		return fmt.Errorf("invalid subject: %w", err)
+	}
+
+	// subject alternative names
+	template.DNSNames = splitList(Config.DNS)
+	template.EmailAddresses = splitList(Config.Email)
+	for _, ip := range splitList(Config.IP) {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return fmt.Errorf("invalid IP address %q", ip)
+		}
+		template.IPAddresses = append(template.IPAddresses, parsed)
+	}
+	for _, uri := range splitList(Config.URI) {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return fmt.Errorf("invalid URI %q: %w", uri, err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+
+	// CAs are self-signed, and all other certificates are signed by the issuer
+	var issuer *service.Issuer
+	if !Config.CertsCA {
+		issuerPassphrase, err := readPassphrase(Config.IssuerPassphraseFile)
+		if err != nil {
+			return err
+		}
+
+		issuer, err = service.LoadIssuer(filepath.Join(Config.Issuer, "cert.pem"), filepath.Join(Config.Issuer, "key.pem"), issuerPassphrase)
+		if err != nil {
+			return fmt.Errorf("unable to load issuer: %w", err)
+		}
+	}
+
+	cert, key, err := service.IssueCertificate(template, issuer)
+	if err != nil {
+		return err
+	}
+
+	// write the key first, since it is never overwritten
+	passphrase, err := readPassphrase(Config.EncryptKey)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(Config.Out, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = service.WriteKey(filepath.Join(Config.Out, "key.pem"), key, passphrase)
+	if err != nil {
+		return err
+	}
+
+	err = service.WriteCertificate(filepath.Join(Config.Out, "cert.pem"), cert)
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(log.Fields{"subject": cert.Subject.String(), "notAfter": cert.NotAfter}).Info("created certificate")
+
+	// print the authorization config entries for the new client
+	if Config.CertsClient {
+		configs := service.ClientConfigs(cert)
+		for _, source := range []service.IdentitySource{service.IdentitySubject, service.IdentityURI, service.IdentityDNS, service.IdentityEmail, service.IdentityFingerprint} {
+			if len(configs[source]) == 0 {
+				continue
+			}
+
+			out, err := yaml.Marshal(configs[source])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# identity: %s\n%s", source, out)
+		}
+	}
+
+	return nil
+}
+
+// policyCheck evaluates the command policy in the authorization config for the command passed to `policy check`, and prints the outcome. It returns false if the command is denied.
+func policyCheck() (bool, error) {
+	policy, err := service.LoadPolicy(Config.Auth)
+	if err != nil {
+		return false, err
+	}
+
+	user := &service.User{Id: "<" + Config.Role + ">", Roles: []service.Role{service.Role(Config.Role)}}
+	if Config.User == "" && !service.Role(Config.Role).IsKnown() {
+		return false, fmt.Errorf("unknown role %q", Config.Role)
+	}
+	if Config.User != "" {
+		var ok bool
+		user, ok = policy.LookupUser(Config.User)
+		if !ok {
+			return false, fmt.Errorf("unknown user %q", Config.User)
+		}
+	}
+
+	env, err := service.ParseEnv(Config.Env)
+	if err != nil {
+		return false, err
+	}
+
+	admission, err := policy.Admit(user, Config.Command, Config.Args, env, "")
+	if err != nil {
+		return false, err
+	}
+
+	rule := "no rule matched"
+	if admission.Rule >= 0 {
+		rule = fmt.Sprintf("commands[%d]", admission.Rule)
+	}
+	fmt.Printf("%s: %s (%s)\n", admission.Action, admission.Path, rule)
+
+	return admission.Action != service.ActionDeny, nil
+}
+
+// listenUnix listens on a Unix socket at `path`, replacing a socket left behind by a previous run. The socket can be connected to by any local user, since clients are authorized by their uid.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, 0666)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// readPassphrase returns the contents of the file at `path` without a trailing newline, or nil if `path` is empty.
+func readPassphrase(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	passphrase, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(passphrase, "\r\n"), nil
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -24,28 +24,36 @@ const (
 type JobStatus int32
 
 const (
-	JobStatus_CREATED   JobStatus = 0 // The job was created but not run yet.
+	JobStatus_QUEUED    JobStatus = 0 // The job is waiting for the scheduler to run it.
 	JobStatus_RUNNING   JobStatus = 1 // The job is currently running.
 	JobStatus_STOPPED   JobStatus = 2 // The job was stopped by a user.
 	JobStatus_SUCCEEDED JobStatus = 3 // The job finished with a zero exit code.
 	JobStatus_FAILED    JobStatus = 4 // The job finished with a non-zero exit code, or there was a
+	// server error in processing the job.
+	JobStatus_PENDING_APPROVAL JobStatus = 5 // The job's command requires approval by an admin
+	// before it is run.
+	JobStatus_HELD JobStatus = 6 // The job was started with hold, and is not run until it is
 )
 
 // Enum value maps for JobStatus.
 var (
 	JobStatus_name = map[int32]string{
-		0: "CREATED",
+		0: "QUEUED",
 		1: "RUNNING",
 		2: "STOPPED",
 		3: "SUCCEEDED",
 		4: "FAILED",
+		5: "PENDING_APPROVAL",
+		6: "HELD",
 	}
 	JobStatus_value = map[string]int32{
-		"CREATED":   0,
-		"RUNNING":   1,
-		"STOPPED":   2,
-		"SUCCEEDED": 3,
-		"FAILED":    4,
+		"QUEUED":           0,
+		"RUNNING":          1,
+		"STOPPED":          2,
+		"SUCCEEDED":        3,
+		"FAILED":           4,
+		"PENDING_APPROVAL": 5,
+		"HELD":             6,
 	}
 )
 
@@ -76,6 +84,104 @@ func (JobStatus) EnumDescriptor() ([]byte, []int) {
 	return file_job_message_proto_rawDescGZIP(), []int{0}
 }
 
+type WorkspaceRetention int32
+
+const (
+	WorkspaceRetention_WORKSPACE_DELETE            WorkspaceRetention = 0 // The workspace is deleted after the job is done.
+	WorkspaceRetention_WORKSPACE_RETAIN            WorkspaceRetention = 1 // The workspace is kept after the job is done.
+	WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE WorkspaceRetention = 2 // The workspace is only kept if the job did
+)
+
+// Enum value maps for WorkspaceRetention.
+var (
+	WorkspaceRetention_name = map[int32]string{
+		0: "WORKSPACE_DELETE",
+		1: "WORKSPACE_RETAIN",
+		2: "WORKSPACE_RETAIN_ON_FAILURE",
+	}
+	WorkspaceRetention_value = map[string]int32{
+		"WORKSPACE_DELETE":            0,
+		"WORKSPACE_RETAIN":            1,
+		"WORKSPACE_RETAIN_ON_FAILURE": 2,
+	}
+)
+
+func (x WorkspaceRetention) Enum() *WorkspaceRetention {
+	p := new(WorkspaceRetention)
+	*p = x
+	return p
+}
+
+func (x WorkspaceRetention) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WorkspaceRetention) Descriptor() protoreflect.EnumDescriptor {
+	return file_job_message_proto_enumTypes[1].Descriptor()
+}
+
+func (WorkspaceRetention) Type() protoreflect.EnumType {
+	return &file_job_message_proto_enumTypes[1]
+}
+
+func (x WorkspaceRetention) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WorkspaceRetention.Descriptor instead.
+func (WorkspaceRetention) EnumDescriptor() ([]byte, []int) {
+	return file_job_message_proto_rawDescGZIP(), []int{1}
+}
+
+type EnvBase int32
+
+const (
+	EnvBase_ENV_INHERIT   EnvBase = 0 // The job inherits the server's environment.
+	EnvBase_ENV_EMPTY     EnvBase = 1 // The job starts from an empty environment.
+	EnvBase_ENV_ALLOWLIST EnvBase = 2 // The job inherits the variables of the server's
+)
+
+// Enum value maps for EnvBase.
+var (
+	EnvBase_name = map[int32]string{
+		0: "ENV_INHERIT",
+		1: "ENV_EMPTY",
+		2: "ENV_ALLOWLIST",
+	}
+	EnvBase_value = map[string]int32{
+		"ENV_INHERIT":   0,
+		"ENV_EMPTY":     1,
+		"ENV_ALLOWLIST": 2,
+	}
+)
+
+func (x EnvBase) Enum() *EnvBase {
+	p := new(EnvBase)
+	*p = x
+	return p
+}
+
+func (x EnvBase) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EnvBase) Descriptor() protoreflect.EnumDescriptor {
+	return file_job_message_proto_enumTypes[2].Descriptor()
+}
+
+func (EnvBase) Type() protoreflect.EnumType {
+	return &file_job_message_proto_enumTypes[2]
+}
+
+func (x EnvBase) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EnvBase.Descriptor instead.
+func (EnvBase) EnumDescriptor() ([]byte, []int) {
+	return file_job_message_proto_rawDescGZIP(), []int{2}
+}
+
 type JobInfo struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -89,6 +195,34 @@ type JobInfo struct {
 	ExitCode   int32                  `protobuf:"varint,6,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
 	CreatedAt  *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	FinishedAt *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	Group      string                 `protobuf:"bytes,9,opt,name=group,proto3" json:"group,omitempty"`    // group that the job is shared with, if any
+	Rootfs     string                 `protobuf:"bytes,10,opt,name=rootfs,proto3" json:"rootfs,omitempty"` // root filesystem that the job runs in, if any
+	// digest of the OCI image manifest that the root filesystem was unpacked
+	// from, if it is an image
+	ImageDigest string `protobuf:"bytes,11,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	// what happens to the job's workspace directory after the job is done
+	WorkspaceRetention WorkspaceRetention `protobuf:"varint,12,opt,name=workspace_retention,json=workspaceRetention,proto3,enum=int.backend.mohamed.WorkspaceRetention" json:"workspace_retention,omitempty"`
+	// environment variables that were set for the job on top of its base
+	// environment, with the values of all but the variables that the server
+	// shows redacted
+	Env     map[string]string `protobuf:"bytes,13,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	EnvBase EnvBase           `protobuf:"varint,14,opt,name=env_base,json=envBase,proto3,enum=int.backend.mohamed.EnvBase" json:"env_base,omitempty"`
+	// working directory of the job, as the job sees it
+	WorkingDir string `protobuf:"bytes,15,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	// secrets that the job references, without their values
+	Secrets []*SecretRef `protobuf:"bytes,16,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	// whether the job runs in a pseudo-terminal, which clients can attach to
+	Tty bool `protobuf:"varint,17,opt,name=tty,proto3" json:"tty,omitempty"`
+	// whether the job's stdin is a pipe that clients can write to with JobStdin
+	Stdin bool `protobuf:"varint,18,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	// patterns of the files in the job's workspace that are collected as
+	// artifacts when the job is done
+	ArtifactPatterns []string `protobuf:"bytes,19,rep,name=artifact_patterns,json=artifactPatterns,proto3" json:"artifact_patterns,omitempty"`
+	// artifacts that were collected from the job, once it is done
+	Artifacts []*Artifact `protobuf:"bytes,20,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	// position of a QUEUED job in the queue, starting at 1 for the next job to
+	// run, or 0 if the job is not waiting in it
+	QueuePosition uint32 `protobuf:"varint,21,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
 }
 
 func (x *JobInfo) Reset() {
@@ -155,7 +289,7 @@ func (x *JobInfo) GetJobStatus() JobStatus {
 	if x != nil {
 		return x.JobStatus
 	}
-	return JobStatus_CREATED
+	return JobStatus_QUEUED
 }
 
 func (x *JobInfo) GetExitCode() int32 {
@@ -179,6 +313,291 @@ func (x *JobInfo) GetFinishedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *JobInfo) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *JobInfo) GetRootfs() string {
+	if x != nil {
+		return x.Rootfs
+	}
+	return ""
+}
+
+func (x *JobInfo) GetImageDigest() string {
+	if x != nil {
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *JobInfo) GetWorkspaceRetention() WorkspaceRetention {
+	if x != nil {
+		return x.WorkspaceRetention
+	}
+	return WorkspaceRetention_WORKSPACE_DELETE
+}
+
+func (x *JobInfo) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *JobInfo) GetEnvBase() EnvBase {
+	if x != nil {
+		return x.EnvBase
+	}
+	return EnvBase_ENV_INHERIT
+}
+
+func (x *JobInfo) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *JobInfo) GetSecrets() []*SecretRef {
+	if x != nil {
+		return x.Secrets
+	}
+	return nil
+}
+
+func (x *JobInfo) GetTty() bool {
+	if x != nil {
+		return x.Tty
+	}
+	return false
+}
+
+func (x *JobInfo) GetStdin() bool {
+	if x != nil {
+		return x.Stdin
+	}
+	return false
+}
+
+func (x *JobInfo) GetArtifactPatterns() []string {
+	if x != nil {
+		return x.ArtifactPatterns
+	}
+	return nil
+}
+
+func (x *JobInfo) GetArtifacts() []*Artifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *JobInfo) GetQueuePosition() uint32 {
+	if x != nil {
+		return x.QueuePosition
+	}
+	return 0
+}
+
+// A file that was collected from a job's workspace when the job was done,
+// which can be downloaded with ArtifactDownload.
+type Artifact struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name   string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`     // path of the file relative to the job's workspace
+	Size   uint64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`    // size of the file in bytes
+	Sha256 string `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"` // hex-encoded SHA-256 checksum of the file
+	Mode   uint32 `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"`    // permission bits of the file
+}
+
+func (x *Artifact) Reset() {
+	*x = Artifact{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_job_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Artifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Artifact) ProtoMessage() {}
+
+func (x *Artifact) ProtoReflect() protoreflect.Message {
+	mi := &file_job_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Artifact.ProtoReflect.Descriptor instead.
+func (*Artifact) Descriptor() ([]byte, []int) {
+	return file_job_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Artifact) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Artifact) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Artifact) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *Artifact) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+// A reference to a secret in the server's secret store, which the job can
+// read from either an environment variable or a file.
+type SecretRef struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // name of the secret in the secret store
+	Env  string `protobuf:"bytes,2,opt,name=env,proto3" json:"env,omitempty"`   // environment variable that is set to the secret
+	File string `protobuf:"bytes,3,opt,name=file,proto3" json:"file,omitempty"` // name of the file in the job's secrets directory
+}
+
+func (x *SecretRef) Reset() {
+	*x = SecretRef{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_job_message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SecretRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecretRef) ProtoMessage() {}
+
+func (x *SecretRef) ProtoReflect() protoreflect.Message {
+	mi := &file_job_message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecretRef.ProtoReflect.Descriptor instead.
+func (*SecretRef) Descriptor() ([]byte, []int) {
+	return file_job_message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SecretRef) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SecretRef) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *SecretRef) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+// The size of a terminal, in characters.
+type TerminalSize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rows uint32 `protobuf:"varint,1,opt,name=rows,proto3" json:"rows,omitempty"`
+	Cols uint32 `protobuf:"varint,2,opt,name=cols,proto3" json:"cols,omitempty"`
+}
+
+func (x *TerminalSize) Reset() {
+	*x = TerminalSize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_job_message_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TerminalSize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TerminalSize) ProtoMessage() {}
+
+func (x *TerminalSize) ProtoReflect() protoreflect.Message {
+	mi := &file_job_message_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TerminalSize.ProtoReflect.Descriptor instead.
+func (*TerminalSize) Descriptor() ([]byte, []int) {
+	return file_job_message_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TerminalSize) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *TerminalSize) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
 var File_job_message_proto protoreflect.FileDescriptor
 
 var file_job_message_proto_rawDesc = []byte{
@@ -186,7 +605,7 @@ var file_job_message_proto_rawDesc = []byte{
 	0x6f, 0x74, 0x6f, 0x12, 0x13, 0x69, 0x6e, 0x74, 0x2e, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64,
 	0x2e, 0x6d, 0x6f, 0x68, 0x61, 0x6d, 0x65, 0x64, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
 	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
-	0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb4, 0x02, 0x0a, 0x07, 0x4a, 0x6f,
+	0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x9d, 0x07, 0x0a, 0x07, 0x4a, 0x6f,
 	0x62, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64,
 	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x18,
@@ -206,15 +625,79 @@ var file_job_message_proto_rawDesc = []byte{
 	0x61, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
 	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
 	0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x66, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x41, 0x74,
-	0x2a, 0x4d, 0x0a, 0x09, 0x4a, 0x6f, 0x62, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0b, 0x0a,
-	0x07, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x55,
-	0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x54, 0x4f, 0x50, 0x50,
-	0x45, 0x44, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x55, 0x43, 0x43, 0x45, 0x45, 0x44, 0x45,
-	0x44, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x04, 0x42,
-	0x2c, 0x5a, 0x2a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x6c,
-	0x61, 0x72, 0x61, 0x64, 0x6a, 0x69, 0x2f, 0x69, 0x6e, 0x74, 0x2d, 0x62, 0x61, 0x63, 0x6b, 0x65,
-	0x6e, 0x64, 0x2d, 0x6d, 0x6f, 0x68, 0x61, 0x6d, 0x65, 0x64, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73,
+	0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x12, 0x21,
+	0x0a, 0x0c, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x0b,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x44, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x12, 0x58, 0x0a, 0x13, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x70, 0x61, 0x63, 0x65, 0x5f, 0x72,
+	0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x27,
+	0x2e, 0x69, 0x6e, 0x74, 0x2e, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2e, 0x6d, 0x6f, 0x68,
+	0x61, 0x6d, 0x65, 0x64, 0x2e, 0x57, 0x6f, 0x72, 0x6b, 0x73, 0x70, 0x61, 0x63, 0x65, 0x52, 0x65,
+	0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x12, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x70, 0x61,
+	0x63, 0x65, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x37, 0x0a, 0x03, 0x65,
+	0x6e, 0x76, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x69, 0x6e, 0x74, 0x2e, 0x62,
+	0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2e, 0x6d, 0x6f, 0x68, 0x61, 0x6d, 0x65, 0x64, 0x2e, 0x4a,
+	0x6f, 0x62, 0x49, 0x6e, 0x66, 0x6f, 0x2e, 0x45, 0x6e, 0x76, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x03, 0x65, 0x6e, 0x76, 0x12, 0x37, 0x0a, 0x08, 0x65, 0x6e, 0x76, 0x5f, 0x62, 0x61, 0x73, 0x65,
+	0x18, 0x0e, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x69, 0x6e, 0x74, 0x2e, 0x62, 0x61, 0x63,
+	0x6b, 0x65, 0x6e, 0x64, 0x2e, 0x6d, 0x6f, 0x68, 0x61, 0x6d, 0x65, 0x64, 0x2e, 0x45, 0x6e, 0x76,
+	0x42, 0x61, 0x73, 0x65, 0x52, 0x07, 0x65, 0x6e, 0x76, 0x42, 0x61, 0x73, 0x65, 0x12, 0x1f, 0x0a,
+	0x0b, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x5f, 0x64, 0x69, 0x72, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x44, 0x69, 0x72, 0x12, 0x38,
+	0x0a, 0x07, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x18, 0x10, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1e, 0x2e, 0x69, 0x6e, 0x74, 0x2e, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2e, 0x6d, 0x6f,
+	0x68, 0x61, 0x6d, 0x65, 0x64, 0x2e, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x52, 0x65, 0x66, 0x52,
+	0x07, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x79, 0x18,
+	0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03, 0x74, 0x74, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74,
+	0x64, 0x69, 0x6e, 0x18, 0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e,
+	0x12, 0x2b, 0x0a, 0x11, 0x61, 0x72, 0x74, 0x69, 0x66, 0x61, 0x63, 0x74, 0x5f, 0x70, 0x61, 0x74,
+	0x74, 0x65, 0x72, 0x6e, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x09, 0x52, 0x10, 0x61, 0x72, 0x74,
+	0x69, 0x66, 0x61, 0x63, 0x74, 0x50, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x73, 0x12, 0x3b, 0x0a,
+	0x09, 0x61, 0x72, 0x74, 0x69, 0x66, 0x61, 0x63, 0x74, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1d, 0x2e, 0x69, 0x6e, 0x74, 0x2e, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2e, 0x6d,
+	0x6f, 0x68, 0x61, 0x6d, 0x65, 0x64, 0x2e, 0x41, 0x72, 0x74, 0x69, 0x66, 0x61, 0x63, 0x74, 0x52,
+	0x09, 0x61, 0x72, 0x74, 0x69, 0x66, 0x61, 0x63, 0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x71, 0x75,
+	0x65, 0x75, 0x65, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x15, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0d, 0x71, 0x75, 0x65, 0x75, 0x65, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x1a, 0x36, 0x0a, 0x08, 0x45, 0x6e, 0x76, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x5e, 0x0a, 0x08, 0x41, 0x72, 0x74,
+	0x69, 0x66, 0x61, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x22, 0x45, 0x0a, 0x09, 0x53, 0x65, 0x63,
+	0x72, 0x65, 0x74, 0x52, 0x65, 0x66, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e,
+	0x76, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x65, 0x6e, 0x76, 0x12, 0x12, 0x0a, 0x04,
+	0x66, 0x69, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x65,
+	0x22, 0x36, 0x0a, 0x0c, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04,
+	0x72, 0x6f, 0x77, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x2a, 0x6c, 0x0a, 0x09, 0x4a, 0x6f, 0x62, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0a, 0x0a, 0x06, 0x51, 0x55, 0x45, 0x55, 0x45, 0x44, 0x10,
+	0x00, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0b,
+	0x0a, 0x07, 0x53, 0x54, 0x4f, 0x50, 0x50, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x53,
+	0x55, 0x43, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x46, 0x41,
+	0x49, 0x4c, 0x45, 0x44, 0x10, 0x04, 0x12, 0x14, 0x0a, 0x10, 0x50, 0x45, 0x4e, 0x44, 0x49, 0x4e,
+	0x47, 0x5f, 0x41, 0x50, 0x50, 0x52, 0x4f, 0x56, 0x41, 0x4c, 0x10, 0x05, 0x12, 0x08, 0x0a, 0x04,
+	0x48, 0x45, 0x4c, 0x44, 0x10, 0x06, 0x2a, 0x61, 0x0a, 0x12, 0x57, 0x6f, 0x72, 0x6b, 0x73, 0x70,
+	0x61, 0x63, 0x65, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x10,
+	0x57, 0x4f, 0x52, 0x4b, 0x53, 0x50, 0x41, 0x43, 0x45, 0x5f, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45,
+	0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x57, 0x4f, 0x52, 0x4b, 0x53, 0x50, 0x41, 0x43, 0x45, 0x5f,
+	0x52, 0x45, 0x54, 0x41, 0x49, 0x4e, 0x10, 0x01, 0x12, 0x1f, 0x0a, 0x1b, 0x57, 0x4f, 0x52, 0x4b,
+	0x53, 0x50, 0x41, 0x43, 0x45, 0x5f, 0x52, 0x45, 0x54, 0x41, 0x49, 0x4e, 0x5f, 0x4f, 0x4e, 0x5f,
+	0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x10, 0x02, 0x2a, 0x3c, 0x0a, 0x07, 0x45, 0x6e, 0x76,
+	0x42, 0x61, 0x73, 0x65, 0x12, 0x0f, 0x0a, 0x0b, 0x45, 0x4e, 0x56, 0x5f, 0x49, 0x4e, 0x48, 0x45,
+	0x52, 0x49, 0x54, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x45, 0x4e, 0x56, 0x5f, 0x45, 0x4d, 0x50,
+	0x54, 0x59, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x45, 0x4e, 0x56, 0x5f, 0x41, 0x4c, 0x4c, 0x4f,
+	0x57, 0x4c, 0x49, 0x53, 0x54, 0x10, 0x02, 0x42, 0x2c, 0x5a, 0x2a, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x6c, 0x61, 0x72, 0x61, 0x64, 0x6a, 0x69, 0x2f, 0x69,
+	0x6e, 0x74, 0x2d, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2d, 0x6d, 0x6f, 0x68, 0x61, 0x6d,
+	0x65, 0x64, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -229,22 +712,33 @@ func file_job_message_proto_rawDescGZIP() []byte {
 	return file_job_message_proto_rawDescData
 }
 
-var file_job_message_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_job_message_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_job_message_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_job_message_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_job_message_proto_goTypes = []interface{}{
 	(JobStatus)(0),                // 0: int.backend.mohamed.JobStatus
-	(*JobInfo)(nil),               // 1: int.backend.mohamed.JobInfo
-	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+	(WorkspaceRetention)(0),       // 1: int.backend.mohamed.WorkspaceRetention
+	(EnvBase)(0),                  // 2: int.backend.mohamed.EnvBase
+	(*JobInfo)(nil),               // 3: int.backend.mohamed.JobInfo
+	(*Artifact)(nil),              // 4: int.backend.mohamed.Artifact
+	(*SecretRef)(nil),             // 5: int.backend.mohamed.SecretRef
+	(*TerminalSize)(nil),          // 6: int.backend.mohamed.TerminalSize
+	nil,                           // 7: int.backend.mohamed.JobInfo.EnvEntry
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
 }
 var file_job_message_proto_depIdxs = []int32{
 	0, // 0: int.backend.mohamed.JobInfo.job_status:type_name -> int.backend.mohamed.JobStatus
-	2, // 1: int.backend.mohamed.JobInfo.created_at:type_name -> google.protobuf.Timestamp
-	2, // 2: int.backend.mohamed.JobInfo.finished_at:type_name -> google.protobuf.Timestamp
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	8, // 1: int.backend.mohamed.JobInfo.created_at:type_name -> google.protobuf.Timestamp
+	8, // 2: int.backend.mohamed.JobInfo.finished_at:type_name -> google.protobuf.Timestamp
+	1, // 3: int.backend.mohamed.JobInfo.workspace_retention:type_name -> int.backend.mohamed.WorkspaceRetention
+	7, // 4: int.backend.mohamed.JobInfo.env:type_name -> int.backend.mohamed.JobInfo.EnvEntry
+	2, // 5: int.backend.mohamed.JobInfo.env_base:type_name -> int.backend.mohamed.EnvBase
+	5, // 6: int.backend.mohamed.JobInfo.secrets:type_name -> int.backend.mohamed.SecretRef
+	4, // 7: int.backend.mohamed.JobInfo.artifacts:type_name -> int.backend.mohamed.Artifact
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_job_message_proto_init() }
@@ -265,14 +759,50 @@ func file_job_message_proto_init() {
 				return nil
 			}
 		}
+		file_job_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Artifact); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_job_message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SecretRef); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_job_message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TerminalSize); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_job_message_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   1,
+			NumEnums:      3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
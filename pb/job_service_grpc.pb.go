@@ -22,6 +22,26 @@ type JobServiceClient interface {
 	JobStop(ctx context.Context, in *JobStopRequest, opts ...grpc.CallOption) (*JobStopResponse, error)
 	JobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatusResponse, error)
 	JobLogsStream(ctx context.Context, in *JobLogsRequest, opts ...grpc.CallOption) (JobService_JobLogsStreamClient, error)
+	JobList(ctx context.Context, in *JobListRequest, opts ...grpc.CallOption) (*JobListResponse, error)
+	TokenIssue(ctx context.Context, in *TokenIssueRequest, opts ...grpc.CallOption) (*TokenIssueResponse, error)
+	JobApprove(ctx context.Context, in *JobApproveRequest, opts ...grpc.CallOption) (*JobApproveResponse, error)
+	// Attaches to the terminal of a job started with tty. The output of the
+	// job is streamed from the start of its log, and the stream ends when the
+	// job is done and all of its output was sent.
+	JobAttach(ctx context.Context, opts ...grpc.CallOption) (JobService_JobAttachClient, error)
+	// Writes to the stdin of a job started with stdin. Only one stream can
+	// write to a job's stdin at a time.
+	JobStdin(ctx context.Context, opts ...grpc.CallOption) (JobService_JobStdinClient, error)
+	// Runs a job that was started with hold.
+	JobRelease(ctx context.Context, in *JobReleaseRequest, opts ...grpc.CallOption) (*JobReleaseResponse, error)
+	// Uploads a file to the workspace of a held job. The file only appears in
+	// the workspace once all of it was received and its checksum matches.
+	FileUpload(ctx context.Context, opts ...grpc.CallOption) (JobService_FileUploadClient, error)
+	// Downloads a file from the workspace of a job, while the workspace exists.
+	FileDownload(ctx context.Context, in *FileDownloadRequest, opts ...grpc.CallOption) (JobService_FileDownloadClient, error)
+	// Downloads an artifact that was collected from a job when it was done.
+	// Artifacts can still be downloaded after the job's workspace was deleted.
+	ArtifactDownload(ctx context.Context, in *ArtifactDownloadRequest, opts ...grpc.CallOption) (JobService_ArtifactDownloadClient, error)
 }
 
 type jobServiceClient struct {
@@ -91,6 +111,205 @@ func (x *jobServiceJobLogsStreamClient) Recv() (*JobLogsResponse, error) {
 	return m, nil
 }
 
+func (c *jobServiceClient) JobList(ctx context.Context, in *JobListRequest, opts ...grpc.CallOption) (*JobListResponse, error) {
+	out := new(JobListResponse)
+	err := c.cc.Invoke(ctx, "/int.backend.mohamed.JobService/JobList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobServiceClient) TokenIssue(ctx context.Context, in *TokenIssueRequest, opts ...grpc.CallOption) (*TokenIssueResponse, error) {
+	out := new(TokenIssueResponse)
+	err := c.cc.Invoke(ctx, "/int.backend.mohamed.JobService/TokenIssue", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobServiceClient) JobApprove(ctx context.Context, in *JobApproveRequest, opts ...grpc.CallOption) (*JobApproveResponse, error) {
+	out := new(JobApproveResponse)
+	err := c.cc.Invoke(ctx, "/int.backend.mohamed.JobService/JobApprove", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobServiceClient) JobAttach(ctx context.Context, opts ...grpc.CallOption) (JobService_JobAttachClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobService_ServiceDesc.Streams[1], "/int.backend.mohamed.JobService/JobAttach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobServiceJobAttachClient{stream}
+	return x, nil
+}
+
+type JobService_JobAttachClient interface {
+	Send(*JobAttachRequest) error
+	Recv() (*JobAttachResponse, error)
+	grpc.ClientStream
+}
+
+type jobServiceJobAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobServiceJobAttachClient) Send(m *JobAttachRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jobServiceJobAttachClient) Recv() (*JobAttachResponse, error) {
+	m := new(JobAttachResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobServiceClient) JobStdin(ctx context.Context, opts ...grpc.CallOption) (JobService_JobStdinClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobService_ServiceDesc.Streams[2], "/int.backend.mohamed.JobService/JobStdin", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobServiceJobStdinClient{stream}
+	return x, nil
+}
+
+type JobService_JobStdinClient interface {
+	Send(*JobStdinRequest) error
+	CloseAndRecv() (*JobStdinResponse, error)
+	grpc.ClientStream
+}
+
+type jobServiceJobStdinClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobServiceJobStdinClient) Send(m *JobStdinRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jobServiceJobStdinClient) CloseAndRecv() (*JobStdinResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(JobStdinResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobServiceClient) JobRelease(ctx context.Context, in *JobReleaseRequest, opts ...grpc.CallOption) (*JobReleaseResponse, error) {
+	out := new(JobReleaseResponse)
+	err := c.cc.Invoke(ctx, "/int.backend.mohamed.JobService/JobRelease", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobServiceClient) FileUpload(ctx context.Context, opts ...grpc.CallOption) (JobService_FileUploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobService_ServiceDesc.Streams[3], "/int.backend.mohamed.JobService/FileUpload", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobServiceFileUploadClient{stream}
+	return x, nil
+}
+
+type JobService_FileUploadClient interface {
+	Send(*FileUploadRequest) error
+	CloseAndRecv() (*FileUploadResponse, error)
+	grpc.ClientStream
+}
+
+type jobServiceFileUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobServiceFileUploadClient) Send(m *FileUploadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jobServiceFileUploadClient) CloseAndRecv() (*FileUploadResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(FileUploadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobServiceClient) FileDownload(ctx context.Context, in *FileDownloadRequest, opts ...grpc.CallOption) (JobService_FileDownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobService_ServiceDesc.Streams[4], "/int.backend.mohamed.JobService/FileDownload", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobServiceFileDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JobService_FileDownloadClient interface {
+	Recv() (*FileDownloadResponse, error)
+	grpc.ClientStream
+}
+
+type jobServiceFileDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobServiceFileDownloadClient) Recv() (*FileDownloadResponse, error) {
+	m := new(FileDownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobServiceClient) ArtifactDownload(ctx context.Context, in *ArtifactDownloadRequest, opts ...grpc.CallOption) (JobService_ArtifactDownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobService_ServiceDesc.Streams[5], "/int.backend.mohamed.JobService/ArtifactDownload", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobServiceArtifactDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JobService_ArtifactDownloadClient interface {
+	Recv() (*FileDownloadResponse, error)
+	grpc.ClientStream
+}
+
+type jobServiceArtifactDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobServiceArtifactDownloadClient) Recv() (*FileDownloadResponse, error) {
+	m := new(FileDownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // JobServiceServer is the server API for JobService service.
 // All implementations must embed UnimplementedJobServiceServer
 // for forward compatibility
@@ -99,6 +318,26 @@ type JobServiceServer interface {
 	JobStop(context.Context, *JobStopRequest) (*JobStopResponse, error)
 	JobStatus(context.Context, *JobStatusRequest) (*JobStatusResponse, error)
 	JobLogsStream(*JobLogsRequest, JobService_JobLogsStreamServer) error
+	JobList(context.Context, *JobListRequest) (*JobListResponse, error)
+	TokenIssue(context.Context, *TokenIssueRequest) (*TokenIssueResponse, error)
+	JobApprove(context.Context, *JobApproveRequest) (*JobApproveResponse, error)
+	// Attaches to the terminal of a job started with tty. The output of the
+	// job is streamed from the start of its log, and the stream ends when the
+	// job is done and all of its output was sent.
+	JobAttach(JobService_JobAttachServer) error
+	// Writes to the stdin of a job started with stdin. Only one stream can
+	// write to a job's stdin at a time.
+	JobStdin(JobService_JobStdinServer) error
+	// Runs a job that was started with hold.
+	JobRelease(context.Context, *JobReleaseRequest) (*JobReleaseResponse, error)
+	// Uploads a file to the workspace of a held job. The file only appears in
+	// the workspace once all of it was received and its checksum matches.
+	FileUpload(JobService_FileUploadServer) error
+	// Downloads a file from the workspace of a job, while the workspace exists.
+	FileDownload(*FileDownloadRequest, JobService_FileDownloadServer) error
+	// Downloads an artifact that was collected from a job when it was done.
+	// Artifacts can still be downloaded after the job's workspace was deleted.
+	ArtifactDownload(*ArtifactDownloadRequest, JobService_ArtifactDownloadServer) error
 	mustEmbedUnimplementedJobServiceServer()
 }
 
@@ -118,6 +357,33 @@ func (UnimplementedJobServiceServer) JobStatus(context.Context, *JobStatusReques
 func (UnimplementedJobServiceServer) JobLogsStream(*JobLogsRequest, JobService_JobLogsStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method JobLogsStream not implemented")
 }
+func (UnimplementedJobServiceServer) JobList(context.Context, *JobListRequest) (*JobListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JobList not implemented")
+}
+func (UnimplementedJobServiceServer) TokenIssue(context.Context, *TokenIssueRequest) (*TokenIssueResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TokenIssue not implemented")
+}
+func (UnimplementedJobServiceServer) JobApprove(context.Context, *JobApproveRequest) (*JobApproveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JobApprove not implemented")
+}
+func (UnimplementedJobServiceServer) JobAttach(JobService_JobAttachServer) error {
+	return status.Errorf(codes.Unimplemented, "method JobAttach not implemented")
+}
+func (UnimplementedJobServiceServer) JobStdin(JobService_JobStdinServer) error {
+	return status.Errorf(codes.Unimplemented, "method JobStdin not implemented")
+}
+func (UnimplementedJobServiceServer) JobRelease(context.Context, *JobReleaseRequest) (*JobReleaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JobRelease not implemented")
+}
+func (UnimplementedJobServiceServer) FileUpload(JobService_FileUploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method FileUpload not implemented")
+}
+func (UnimplementedJobServiceServer) FileDownload(*FileDownloadRequest, JobService_FileDownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method FileDownload not implemented")
+}
+func (UnimplementedJobServiceServer) ArtifactDownload(*ArtifactDownloadRequest, JobService_ArtifactDownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method ArtifactDownload not implemented")
+}
 func (UnimplementedJobServiceServer) mustEmbedUnimplementedJobServiceServer() {}
 
 // UnsafeJobServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -206,6 +472,198 @@ func (x *jobServiceJobLogsStreamServer) Send(m *JobLogsResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _JobService_JobList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobServiceServer).JobList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/int.backend.mohamed.JobService/JobList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobServiceServer).JobList(ctx, req.(*JobListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobService_TokenIssue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenIssueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobServiceServer).TokenIssue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/int.backend.mohamed.JobService/TokenIssue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobServiceServer).TokenIssue(ctx, req.(*TokenIssueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobService_JobApprove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobApproveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobServiceServer).JobApprove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/int.backend.mohamed.JobService/JobApprove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobServiceServer).JobApprove(ctx, req.(*JobApproveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobService_JobAttach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobServiceServer).JobAttach(&jobServiceJobAttachServer{stream})
+}
+
+type JobService_JobAttachServer interface {
+	Send(*JobAttachResponse) error
+	Recv() (*JobAttachRequest, error)
+	grpc.ServerStream
+}
+
+type jobServiceJobAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobServiceJobAttachServer) Send(m *JobAttachResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jobServiceJobAttachServer) Recv() (*JobAttachRequest, error) {
+	m := new(JobAttachRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _JobService_JobStdin_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobServiceServer).JobStdin(&jobServiceJobStdinServer{stream})
+}
+
+type JobService_JobStdinServer interface {
+	SendAndClose(*JobStdinResponse) error
+	Recv() (*JobStdinRequest, error)
+	grpc.ServerStream
+}
+
+type jobServiceJobStdinServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobServiceJobStdinServer) SendAndClose(m *JobStdinResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jobServiceJobStdinServer) Recv() (*JobStdinRequest, error) {
+	m := new(JobStdinRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _JobService_JobRelease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobServiceServer).JobRelease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/int.backend.mohamed.JobService/JobRelease",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobServiceServer).JobRelease(ctx, req.(*JobReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobService_FileUpload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobServiceServer).FileUpload(&jobServiceFileUploadServer{stream})
+}
+
+type JobService_FileUploadServer interface {
+	SendAndClose(*FileUploadResponse) error
+	Recv() (*FileUploadRequest, error)
+	grpc.ServerStream
+}
+
+type jobServiceFileUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobServiceFileUploadServer) SendAndClose(m *FileUploadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jobServiceFileUploadServer) Recv() (*FileUploadRequest, error) {
+	m := new(FileUploadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _JobService_FileDownload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FileDownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobServiceServer).FileDownload(m, &jobServiceFileDownloadServer{stream})
+}
+
+type JobService_FileDownloadServer interface {
+	Send(*FileDownloadResponse) error
+	grpc.ServerStream
+}
+
+type jobServiceFileDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobServiceFileDownloadServer) Send(m *FileDownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _JobService_ArtifactDownload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ArtifactDownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobServiceServer).ArtifactDownload(m, &jobServiceArtifactDownloadServer{stream})
+}
+
+type JobService_ArtifactDownloadServer interface {
+	Send(*FileDownloadResponse) error
+	grpc.ServerStream
+}
+
+type jobServiceArtifactDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobServiceArtifactDownloadServer) Send(m *FileDownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // JobService_ServiceDesc is the grpc.ServiceDesc for JobService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -225,6 +683,22 @@ var JobService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "JobStatus",
 			Handler:    _JobService_JobStatus_Handler,
 		},
+		{
+			MethodName: "JobList",
+			Handler:    _JobService_JobList_Handler,
+		},
+		{
+			MethodName: "TokenIssue",
+			Handler:    _JobService_TokenIssue_Handler,
+		},
+		{
+			MethodName: "JobApprove",
+			Handler:    _JobService_JobApprove_Handler,
+		},
+		{
+			MethodName: "JobRelease",
+			Handler:    _JobService_JobRelease_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -232,6 +706,32 @@ var JobService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _JobService_JobLogsStream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "JobAttach",
+			Handler:       _JobService_JobAttach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "JobStdin",
+			Handler:       _JobService_JobStdin_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "FileUpload",
+			Handler:       _JobService_FileUpload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "FileDownload",
+			Handler:       _JobService_FileDownload_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ArtifactDownload",
+			Handler:       _JobService_ArtifactDownload_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "job_service.proto",
 }
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mlaradji/int-backend-mohamed/worker"
+)
+
+// CommandAction is the outcome of evaluating the command policy for a job.
+type CommandAction string
+
+const (
+	ActionAllow           CommandAction = "allow"            // ActionAllow starts the job right away.
+	ActionDeny            CommandAction = "deny"             // ActionDeny rejects the job.
+	ActionRequireApproval CommandAction = "require_approval" // ActionRequireApproval holds the job until an admin approves it.
+)
+
+var (
+	rootfsPath   = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}  // rootfsPath are the directories that commands are searched in when a job runs in a root filesystem.
+	knownActions = map[CommandAction]bool{ActionAllow: true, ActionDeny: true, ActionRequireApproval: true} // knownActions contains all actions that can be set in command rules.
+)
+
+// CommandRuleConfig is a rule of the command policy, in the authorization config. A rule matches a job if all of its conditions match, and conditions that are not set match any job.
+type CommandRuleConfig struct {
+	Roles  []Role            `yaml:"roles"` // Roles are the roles that the rule applies to. The rule applies to a user if they have any of these roles.
+	Path   string            `yaml:"path"`  // Path is a glob pattern (as in filepath.Match) matched against the command's path, after it is resolved through PATH.
+	Args   string            `yaml:"args"`  // Args is a regular expression matched against the job's arguments joined by single spaces.
+	Env    map[string]string `yaml:"env"`   // Env maps environment variable names to regular expressions that the job's value of the variable must match. Variables that are not set do not match.
+	Action CommandAction     `yaml:"action"`
+}
+
+// commandRule is a validated CommandRuleConfig.
+type commandRule struct {
+	roles  []Role
+	path   string
+	args   *regexp.Regexp
+	env    map[string]*regexp.Regexp
+	action CommandAction
+}
+
+// newCommandRule validates `config` and compiles its patterns.
+func newCommandRule(config CommandRuleConfig) (commandRule, error) {
+	rule := commandRule{roles: config.Roles, path: config.Path, env: map[string]*regexp.Regexp{}, action: config.Action}
+
+	if !knownActions[rule.action] {
+		return commandRule{}, fmt.Errorf("unknown action %q", rule.action)
+	}
+	for _, role := range rule.roles {
+		if !knownRoles[role] {
+			return commandRule{}, fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	if rule.path != "" {
+		if !filepath.IsAbs(rule.path) {
+			return commandRule{}, fmt.Errorf("path %q must be absolute, since commands are matched after they are resolved", rule.path)
+		}
+		if _, err := filepath.Match(rule.path, ""); err != nil {
+			return commandRule{}, fmt.Errorf("path %q: %w", rule.path, err)
+		}
+	}
+
+	if config.Args != "" {
+		args, err := regexp.Compile(config.Args)
+		if err != nil {
+			return commandRule{}, fmt.Errorf("args: %w", err)
+		}
+		rule.args = args
+	}
+
+	for name, pattern := range config.Env {
+		value, err := regexp.Compile(pattern)
+		if err != nil {
+			return commandRule{}, fmt.Errorf("env (%s): %w", name, err)
+		}
+		rule.env[name] = value
+	}
+
+	return rule, nil
+}
+
+// matches returns true if the rule applies to `user` running the command at `path` with `args` and `env`.
+func (rule commandRule) matches(user *User, path string, args []string, env map[string]string) bool {
+	if len(rule.roles) > 0 && !user.hasAnyRole(rule.roles) {
+		return false
+	}
+
+	if rule.path != "" {
+		if ok, _ := filepath.Match(rule.path, path); !ok {
+			return false
+		}
+	}
+
+	if rule.args != nil && !rule.args.MatchString(strings.Join(args, " ")) {
+		return false
+	}
+
+	for name, pattern := range rule.env {
+		value, ok := env[name]
+		if !ok || !pattern.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasAnyRole returns true if the user has at least one of `roles`.
+func (user *User) hasAnyRole(roles []Role) bool {
+	for _, role := range roles {
+		for _, userRole := range user.Roles {
+			if role == userRole {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Admission is the outcome of evaluating the command policy for a job.
+type Admission struct {
+	Action CommandAction
+	Path   string // Path is the resolved path of the command, which the job should run. It is the command itself if the policy has no rules.
+	Rule   int    // Rule is the index of the rule that matched, or -1 if no rule matched.
+}
+
+// Admit evaluates the command policy for `user` starting a job that runs `command` with `args` and `env`, in the root filesystem at `root` or in the server's if it is empty. The first rule that matches decides the action. If the policy has no rules every job is allowed, and otherwise jobs that no rule matches are denied.
+func (policy *Policy) Admit(user *User, command string, args []string, env map[string]string, root string) (Admission, error) {
+	if len(policy.commands) == 0 {
+		return Admission{Action: ActionAllow, Path: command, Rule: -1}, nil
+	}
+
+	path, err := lookPath(command, root)
+	if err != nil {
+		return Admission{Action: ActionDeny, Rule: -1}, fmt.Errorf("unable to resolve command: %w", err)
+	}
+
+	for i, rule := range policy.commands {
+		if rule.matches(user, path, args, env) {
+			return Admission{Action: rule.action, Path: path, Rule: i}, nil
+		}
+	}
+
+	return Admission{Action: ActionDeny, Path: path, Rule: -1}, nil
+}
+
+// lookPath returns the absolute path of `command`, searching the server's PATH. If `root` is not empty, the command is instead searched in rootfsPath of the root filesystem at `root`, and the path is relative to it.
+func lookPath(command string, root string) (string, error) {
+	if root == "" {
+		path, err := exec.LookPath(command)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(path)
+	}
+
+	if strings.Contains(command, "/") {
+		if !filepath.IsAbs(command) {
+			return "", fmt.Errorf("%q must be absolute or a command name to run in a root filesystem", command)
+		}
+		return filepath.Clean(command), nil
+	}
+
+	// symlinks are resolved within the root filesystem, since absolute ones would otherwise point into the server's own
+	for _, dir := range rootfsPath {
+		path := filepath.Join(dir, command)
+		resolved, err := worker.ResolveInRoot(root, path)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(resolved); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%q was not found in the root filesystem", command)
+}
@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestCommandPolicy checks that the first matching rule decides whether a command is allowed, and that commands no rule matches are denied.
+func TestCommandPolicy(t *testing.T) {
+	t.Parallel()
+
+	echoPath, err := exec.LookPath("echo")
+	require.NoError(t, err)
+
+	config := `
+commands:
+  - path: ` + echoPath + `
+    args: "secret"
+    action: deny
+  - roles: [user]
+    path: ` + echoPath + `
+    action: allow
+  - roles: [user]
+    env: {DEPLOY: "^prod$"}
+    action: require_approval
+  - roles: [admin]
+    action: allow
+users:
+  - id: alice
+    clients: [{uid: 1000}]
+  - id: root
+    roles: [admin]
+    clients: [{uid: 0}]
+`
+	policy, err := service.ParsePolicy([]byte(config))
+	require.NoError(t, err)
+	alice, _ := policy.LookupUser("alice")
+	root, _ := policy.LookupUser("root")
+
+	testCases := []struct {
+		name   string
+		user   *service.User
+		args   []string
+		env    map[string]string
+		action service.CommandAction
+		rule   int
+	}{
+		{"denied args", alice, []string{"a", "secret"}, nil, service.ActionDeny, 0},
+		{"denied args for any role", root, []string{"secret"}, nil, service.ActionDeny, 0},
+		{"allowed path", alice, []string{"hello"}, nil, service.ActionAllow, 1},
+		{"admins can run anything", root, []string{"hello"}, nil, service.ActionAllow, 3},
+	}
+	for _, testCase := range testCases {
+		admission, err := policy.Admit(testCase.user, "echo", testCase.args, testCase.env, "")
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, testCase.action, admission.Action, testCase.name)
+		require.Equal(t, testCase.rule, admission.Rule, testCase.name)
+		require.Equal(t, echoPath, admission.Path, testCase.name)
+	}
+
+	// env rules
+	admission, err := policy.Admit(alice, "sh", nil, map[string]string{"DEPLOY": "prod"}, "")
+	require.NoError(t, err)
+	require.Equal(t, service.ActionRequireApproval, admission.Action)
+	admission, err = policy.Admit(alice, "sh", nil, map[string]string{"DEPLOY": "production"}, "")
+	require.NoError(t, err)
+	require.Equal(t, service.ActionDeny, admission.Action, "commands that no rule matches are denied")
+	require.Equal(t, -1, admission.Rule)
+
+	_, err = policy.Admit(alice, "no-such-command", nil, nil, "")
+	require.Error(t, err)
+
+	// commands of jobs in a root filesystem are resolved in it
+	rootfsDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfsDir, "bin"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfsDir, "bin", "echo"), nil, 0755))
+	require.NoError(t, os.Symlink("/usr/bin", filepath.Join(rootfsDir, "sbin")))
+	admission, err = policy.Admit(alice, "echo", nil, nil, rootfsDir)
+	require.NoError(t, err)
+	require.Equal(t, "/bin/echo", admission.Path)
+	_, err = policy.Admit(alice, "sh", nil, nil, rootfsDir)
+	require.Error(t, err, "sh is not in the root filesystem, even though /sbin links to the server's /usr/bin")
+
+	// without rules, everything is allowed as is
+	policy, err = service.ParsePolicy([]byte("users:\n  - id: alice\n    clients: [{uid: 1000}]\n"))
+	require.NoError(t, err)
+	admission, err = policy.Admit(alice, "no-such-command", nil, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, service.Admission{Action: service.ActionAllow, Path: "no-such-command", Rule: -1}, admission)
+
+	// invalid rules
+	invalidRules := map[string]string{
+		"unknown action": "commands:\n  - action: maybe\n",
+		"relative path":  "commands:\n  - path: bin/*\n    action: allow\n",
+		"invalid args":   "commands:\n  - args: \"(\"\n    action: allow\n",
+		"unknown role":   "commands:\n  - roles: [root]\n    action: allow\n",
+	}
+	for name, config := range invalidRules {
+		_, err := service.ParsePolicy([]byte(config))
+		require.Error(t, err, name)
+	}
+}
+
+// TestJobApproval checks that jobs held by the command policy only run once an admin other than their owner approves them, and that approved jobs count towards their owner's quota.
+func TestJobApproval(t *testing.T) {
+	t.Parallel()
+
+	config := service.PolicyConfig{
+		Users: []service.UserConfig{
+			{Id: "admin", Roles: []service.Role{service.RoleAdmin}, Clients: []service.ClientConfig{clientConfig("Client 1")}},
+			{Id: "user", Roles: []service.Role{service.RoleUser}, Quota: &service.Quota{MaxRunningJobs: 1}, Clients: []service.ClientConfig{clientConfig("Client 2")}},
+		},
+		Commands: []service.CommandRuleConfig{{Env: map[string]string{"DEPLOY": "^prod$"}, Action: service.ActionDeny}, {Action: service.ActionRequireApproval}},
+	}
+	policy, err := service.NewPolicy(config)
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	auth := service.NewAuthorizer(policy, jobStore)
+	jobServer := service.NewJobServer(jobStore)
+	jobServer.Policy = auth
+	quotas := service.NewQuotaLimiter(jobStore, auth)
+
+	call := func(certPath string, method string, req interface{}) (interface{}, error) {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.JobService_ServiceDesc.ServiceName + "/" + method}
+		return auth.UnaryAuth(peerContext(t, certPath), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return quotas.UnaryQuota(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				switch req := req.(type) {
+				case *pb.JobStartRequest:
+					return jobServer.JobStart(ctx, req)
+				default:
+					return jobServer.JobApprove(ctx, req.(*pb.JobApproveRequest))
+				}
+			})
+		})
+	}
+
+	// rules on the environment apply to the environment that the job is started with
+	_, err = call("../certs/client2/cert.pem", "JobStart", &pb.JobStartRequest{Command: "echo", Env: map[string]string{"DEPLOY": "prod"}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	res, err := call("../certs/client2/cert.pem", "JobStart", &pb.JobStartRequest{Command: "echo", Args: []string{"approved"}})
+	require.NoError(t, err)
+	startRes := res.(*pb.JobStartResponse)
+	require.Equal(t, pb.JobStatus_PENDING_APPROVAL, startRes.GetJobStatus())
+
+	job, err := jobStore.LoadJobById(startRes.GetJobId())
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_PENDING_APPROVAL, job.GetJobStatus())
+
+	_, err = call("../certs/client2/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: job.Key.JobId})
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "users can not approve jobs")
+
+	// admins can not approve their own jobs
+	res, err = call("../certs/client1/cert.pem", "JobStart", &pb.JobStartRequest{Command: "echo"})
+	require.NoError(t, err)
+	_, err = call("../certs/client1/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: res.(*pb.JobStartResponse).GetJobId()})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// an approved job counts towards its owner's running jobs, not the admin's
+	res, err = call("../certs/client2/cert.pem", "JobStart", &pb.JobStartRequest{Command: "sleep", Args: []string{"10"}})
+	require.NoError(t, err)
+	sleepJob, err := jobStore.LoadJobById(res.(*pb.JobStartResponse).GetJobId())
+	require.NoError(t, err)
+	_, err = call("../certs/client1/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: sleepJob.Key.JobId})
+	require.NoError(t, err)
+
+	_, err = call("../certs/client1/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: job.Key.JobId})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, pb.JobStatus_PENDING_APPROVAL, job.GetJobStatus())
+
+	sleepJob.Stop()
+	<-sleepJob.Done
+	require.Eventually(t, func() bool {
+		_, err = call("../certs/client1/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: job.Key.JobId})
+		return status.Code(err) != codes.ResourceExhausted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+
+	_, err = call("../certs/client1/cert.pem", "JobApprove", &pb.JobApproveRequest{JobId: job.Key.JobId})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err), "jobs can only be approved once")
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	auditKey     = ContextKey("audit")
+	auditKeySize = 32 // auditKeySize is the size of the key that the records of the audit log are authenticated with.
+
+	AuditAuthCertificate = "certificate" // AuditAuthCertificate is recorded for clients that authenticated with a client certificate.
+	AuditAuthToken       = "token"       // AuditAuthToken is recorded for clients that authenticated with a bearer token.
+	AuditAuthUnix        = "unix"        // AuditAuthUnix is recorded for local clients that were authenticated by their uid.
+)
+
+var (
+	ErrAuditChainBroken = errors.New("audit log hash chain is broken")
+	ErrAuditLogFailed   = errors.New("audit log can not be written")
+)
+
+// AuditClient describes how a client authenticated. Only the fields of its authentication method are set.
+type AuditClient struct {
+	Auth        string  `json:"auth"`
+	Subject     string  `json:"subject,omitempty"`     // Subject is the subject of the client certificate.
+	Fingerprint string  `json:"fingerprint,omitempty"` // Fingerprint is the SHA-256 fingerprint of the client certificate.
+	Uid         *uint32 `json:"uid,omitempty"`         // Uid is the uid of a local client's process.
+	Pid         int32   `json:"pid,omitempty"`         // Pid is the pid of a local client's process.
+}
+
+// AuditRecord is a single entry of the audit log, which records one API call. Every record includes the hash of the record before it, and the hashes are keyed with a key that is kept outside the log, so that records can not be modified, removed or reordered without breaking the chain.
+type AuditRecord struct {
+	Seq        uint64       `json:"seq"`
+	Time       time.Time    `json:"time"` // Time is when the call was received.
+	Method     string       `json:"method"`
+	Client     *AuditClient `json:"client,omitempty"`  // Client is nil if the client could not be authenticated at all.
+	UserId     string       `json:"user_id,omitempty"` // UserId is the user that the client was mapped to, if any.
+	JobId      string       `json:"job_id,omitempty"`
+	Command    string       `json:"command,omitempty"`
+	Args       []string     `json:"args,omitempty"`
+	Group      string       `json:"group,omitempty"`
+	Code       string       `json:"code"`            // Code is the gRPC status code that the call ended with.
+	Error      string       `json:"error,omitempty"` // Error is the status message, if the call failed.
+	DurationMs int64        `json:"duration_ms"`
+	PrevHash   string       `json:"prev_hash"`
+	Hash       string       `json:"hash,omitempty"` // Hash is the HMAC-SHA256 of the record's JSON encoding without the hash itself.
+
+	mu *sync.Mutex // mu controls access to a record of a call that is being handled, which the goroutines that send and receive messages on a stream can both update.
+}
+
+// hash returns the hash of the record, computed with `key` over its JSON encoding with an empty Hash.
+func (record AuditRecord) hash(key []byte) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// AuditLog appends a record of every API call to a hash-chained JSON lines file. Once a record can not be written, the log fails closed and every further call is rejected.
+type AuditLog struct {
+	file     *os.File
+	key      []byte      // key authenticates the hashes of the records.
+	mu       *sync.Mutex // mu controls access to `file`, `seq`, `lastHash` and `err`.
+	seq      uint64      // seq is the sequence number of the last record written.
+	lastHash string      // lastHash is the hash of the last record written, which the next record is chained to.
+	err      error       // err is the error that the last record could not be written with, if any.
+}
+
+// LoadAuditKey reads the key of an audit log at `path`, which holds 32 random bytes either raw or base64-encoded (e.g. generated by `openssl rand -base64 32`).
+func LoadAuditKey(path string) ([]byte, error) {
+	key, err := readKeyFile(path, auditKeySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != auditKeySize {
+		return nil, fmt.Errorf("%s: audit log key must be %d bytes", path, auditKeySize)
+	}
+
+	return key, nil
+}
+
+// OpenAuditLog opens the audit log at `path` for appending, creating it if it doesn't exist. The records already in the file are verified with `key` first, and the new records are chained to the last one, so the server refuses to start if the log was tampered with. A last line that was only partly written, e.g. because the server crashed, is removed.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	logger := log.WithFields(log.Fields{"func": "OpenAuditLog", "path": path})
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	last, size, err := verifyAuditChain(file, key)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if info, err := file.Stat(); err != nil || info.Size() != size {
+		logger.WithField("size", size).Warn("removing the incomplete last line of the audit log")
+		if err := file.Truncate(size); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	auditLog := &AuditLog{file: file, key: key, mu: &sync.Mutex{}}
+	if last != nil {
+		auditLog.seq, auditLog.lastHash = last.Seq, last.Hash
+	}
+
+	// the head of the chain goes to the server log too, so that records removed from the end of the audit log can be noticed
+	logger.WithFields(log.Fields{"seq": auditLog.seq, "hash": auditLog.lastHash}).Info("opened audit log")
+
+	return auditLog, nil
+}
+
+// Close closes the audit log file.
+func (auditLog *AuditLog) Close() error {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	log.WithFields(log.Fields{"func": "AuditLog.Close", "seq": auditLog.seq, "hash": auditLog.lastHash}).Info("closing audit log")
+	return auditLog.file.Close()
+}
+
+// failed returns ErrAuditLogFailed as a gRPC error if a record could not be written before, so that calls are rejected before they are handled.
+func (auditLog *AuditLog) failed() error {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	if auditLog.err != nil {
+		return status.Error(codes.Unavailable, ErrAuditLogFailed.Error())
+	}
+	return nil
+}
+
+// write chains `record` to the previous record and appends it to the log. If it fails, the log stays failed, since the chain can not be continued safely after a partial write.
+func (auditLog *AuditLog) write(record *AuditRecord) error {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	if auditLog.err != nil {
+		return auditLog.err
+	}
+
+	record.Seq = auditLog.seq + 1
+	record.PrevHash = auditLog.lastHash
+
+	hash, err := record.hash(auditLog.key)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = auditLog.file.Write(append(data, '\n'))
+	if err != nil {
+		auditLog.err = err
+		return err
+	}
+
+	auditLog.seq, auditLog.lastHash = record.Seq, record.Hash
+	return nil
+}
+
+// UnaryAudit is a unary gRPC interceptor that records every call in the audit log. It must run before the authorization interceptors, so that denied calls are recorded too.
+func (auditLog *AuditLog) UnaryAudit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := auditLog.failed(); err != nil {
+		return nil, err
+	}
+
+	record := newAuditRecord(info.FullMethod)
+	record.setRequest(req)
+
+	res, err := handler(context.WithValue(ctx, auditKey, record), req)
+	if startRes, ok := res.(*pb.JobStartResponse); ok {
+		record.mu.Lock()
+		record.JobId = startRes.GetJobId()
+		record.mu.Unlock()
+	}
+
+	if err := auditLog.finish(record, err); err != nil {
+		return nil, err
+	}
+	return res, err
+}
+
+// StreamAudit is a server stream gRPC interceptor that records every call in the audit log when the stream ends. It must run before the authorization interceptors, so that denied calls are recorded too.
+func (auditLog *AuditLog) StreamAudit(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := auditLog.failed(); err != nil {
+		return err
+	}
+
+	record := newAuditRecord(info.FullMethod)
+
+	sswc := NewServerStreamWithContext(context.WithValue(ss.Context(), auditKey, record), ss)
+	err := handler(srv, &auditedServerStream{ServerStreamWithContext: sswc, record: record})
+
+	if err := auditLog.finish(record, err); err != nil {
+		return err
+	}
+	return err
+}
+
+// finish records the outcome of the call and writes the record. If it can not be written, the call is failed even though it was handled, since it would go unrecorded otherwise.
+func (auditLog *AuditLog) finish(record *AuditRecord, err error) error {
+	record.mu.Lock()
+	defer record.mu.Unlock()
+
+	record.DurationMs = time.Since(record.Time).Milliseconds()
+	record.Code = status.Code(err).String()
+	if err != nil {
+		record.Error = status.Convert(err).Message()
+	}
+
+	if err := auditLog.write(record); err != nil {
+		log.WithFields(log.Fields{"func": "AuditLog.finish", "method": record.Method}).WithError(err).Error("unable to write audit record, rejecting further calls")
+		return status.Error(codes.Unavailable, ErrAuditLogFailed.Error())
+	}
+
+	return nil
+}
+
+// newAuditRecord returns a record for a call to `method` that was received now.
+func newAuditRecord(method string) *AuditRecord {
+	return &AuditRecord{Time: time.Now().UTC(), Method: method, mu: &sync.Mutex{}}
+}
+
+// setRequest records the job and command that `req` refers to.
+func (record *AuditRecord) setRequest(req interface{}) {
+	record.mu.Lock()
+	defer record.mu.Unlock()
+
+	if jobReq, ok := req.(jobRequest); ok {
+		record.JobId = jobReq.GetJobId()
+	}
+	if startReq, ok := req.(*pb.JobStartRequest); ok {
+		record.Command, record.Args, record.Group = startReq.GetCommand(), startReq.GetArgs(), startReq.GetGroup()
+	}
+}
+
+// auditedServerStream records the first request received on a stream.
+type auditedServerStream struct {
+	*ServerStreamWithContext
+	record   *AuditRecord
+	received bool // received is set once the first request was recorded.
+}
+
+// RecvMsg receives a message, and records it if it is the first one.
+func (stream *auditedServerStream) RecvMsg(m interface{}) error {
+	if err := stream.ServerStreamWithContext.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if !stream.received {
+		stream.record.setRequest(m)
+		stream.received = true
+	}
+
+	return nil
+}
+
+// setAuditIdentity records how the client of the call with context `ctx` authenticated and the user it was mapped to, if the call is being audited.
+func setAuditIdentity(ctx context.Context, client *AuditClient, userId string) {
+	record, ok := ctx.Value(auditKey).(*AuditRecord)
+	if !ok {
+		return
+	}
+
+	record.mu.Lock()
+	defer record.mu.Unlock()
+
+	record.Client, record.UserId = client, userId
+}
+
+// VerifyAuditLog checks the hash chain of the audit log at `path` with `key`, and returns its last record, which is nil if the log is empty. A last line that was only partly written is ignored.
+func VerifyAuditLog(path string, key []byte) (*AuditRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	last, _, err := verifyAuditChain(file, key)
+	return last, err
+}
+
+// verifyAuditChain reads records from `r` and checks with `key` that each one is chained to the one before it. It returns the last record, and the size of the lines that were read. A last line without a newline is incomplete, and not part of the returned size.
+func verifyAuditChain(r io.Reader, key []byte) (*AuditRecord, int64, error) {
+	var last *AuditRecord
+	size := int64(0)
+
+	// records have no size limit, so lines are read whole
+	reader := bufio.NewReader(r)
+	for line := uint64(1); ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				log.WithFields(log.Fields{"func": "verifyAuditChain", "line": line}).Warn("ignoring the incomplete last line of the audit log")
+			}
+			return last, size, nil
+		}
+		if err != nil {
+			return last, size, err
+		}
+
+		record := &AuditRecord{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(record); err != nil {
+			return last, size, fmt.Errorf("%w: line %d is malformed: %v", ErrAuditChainBroken, line, err)
+		}
+
+		hash, err := record.hash(key)
+		if err != nil {
+			return last, size, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return last, size, fmt.Errorf("%w: line %d (record %d) was modified, or the key is wrong", ErrAuditChainBroken, line, record.Seq)
+		}
+
+		if last != nil && (record.PrevHash != last.Hash || record.Seq != last.Seq+1) {
+			return last, size, fmt.Errorf("%w: line %d (record %d) does not follow record %d", ErrAuditChainBroken, line, record.Seq, last.Seq)
+		}
+		if last == nil && (record.Seq != 1 || record.PrevHash != "") {
+			return last, size, fmt.Errorf("%w: the log starts at record %d", ErrAuditChainBroken, record.Seq)
+		}
+
+		last = record
+		size += int64(len(data))
+	}
+}
@@ -0,0 +1,175 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readAuditLog returns the records and the raw lines of the audit log at `path`.
+func readAuditLog(t *testing.T, path string) ([]service.AuditRecord, [][]byte) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	records := []service.AuditRecord{}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	for _, line := range lines {
+		record := service.AuditRecord{}
+		require.NoError(t, json.Unmarshal(line, &record))
+		records = append(records, record)
+	}
+
+	return records, lines
+}
+
+// TestAuditLog checks that allowed and denied calls are recorded with the client's identity, and that modifying, removing or reordering records, or rewriting them without the key, is detected.
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(os.Getuid())
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Roles: []service.Role{service.RoleViewer}, Clients: []service.ClientConfig{{UID: &uid}}},
+	}})
+	require.NoError(t, err)
+
+	key := bytes.Repeat([]byte{1}, 32)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := service.OpenAuditLog(path, key)
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+	client := unixClient(t, jobStore, grpc.ChainUnaryInterceptor(auditLog.UnaryAudit, authorizer.UnaryAuth), grpc.ChainStreamInterceptor(auditLog.StreamAudit, authorizer.StreamAuth))
+	ctx := context.Background()
+
+	_, err = client.JobList(ctx, &pb.JobListRequest{})
+	require.NoError(t, err)
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "echo", Args: []string{"hello"}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	stream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: "missing"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.NoError(t, auditLog.Close())
+
+	records, lines := readAuditLog(t, path)
+	require.Len(t, records, 3)
+	for i, record := range records {
+		require.Equal(t, uint64(i+1), record.Seq)
+		require.Equal(t, "local", record.UserId)
+		require.Equal(t, service.AuditAuthUnix, record.Client.Auth)
+		require.Equal(t, uid, *record.Client.Uid)
+	}
+	require.Equal(t, codes.OK.String(), records[0].Code)
+	require.Equal(t, codes.PermissionDenied.String(), records[1].Code)
+	require.Equal(t, "echo", records[1].Command)
+	require.Equal(t, []string{"hello"}, records[1].Args)
+	require.Equal(t, codes.NotFound.String(), records[2].Code)
+	require.Equal(t, "missing", records[2].JobId)
+
+	last, err := service.VerifyAuditLog(path, key)
+	require.NoError(t, err)
+	require.Equal(t, records[2], *last)
+
+	// records written after a restart continue the chain
+	auditLog, err = service.OpenAuditLog(path, key)
+	require.NoError(t, err)
+	client = unixClient(t, jobStore, grpc.UnaryInterceptor(auditLog.UnaryAudit))
+	_, err = client.JobList(ctx, &pb.JobListRequest{})
+	require.Error(t, err, "there is no authorizer to attach the user")
+	require.NoError(t, auditLog.Close())
+
+	last, err = service.VerifyAuditLog(path, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), last.Seq)
+
+	// a log that was written with another key does not verify
+	_, err = service.VerifyAuditLog(path, bytes.Repeat([]byte{2}, 32))
+	require.ErrorIs(t, err, service.ErrAuditChainBroken)
+
+	// tampering is detected
+	tampered := map[string][][]byte{
+		"modified":  {lines[0], bytes.Replace(lines[1], []byte(`"user_id":"local"`), []byte(`"user_id":"other"`), 1), lines[2]},
+		"removed":   {lines[0], lines[2]},
+		"reordered": {lines[1], lines[0], lines[2]},
+		"truncated": {lines[1], lines[2]},
+	}
+	for name, tamperedLines := range tampered {
+		tamperedPath := filepath.Join(t.TempDir(), "audit.log")
+		require.NoError(t, ioutil.WriteFile(tamperedPath, append(bytes.Join(tamperedLines, []byte("\n")), '\n'), 0600))
+
+		_, err := service.VerifyAuditLog(tamperedPath, key)
+		require.ErrorIs(t, err, service.ErrAuditChainBroken, name)
+		_, err = service.OpenAuditLog(tamperedPath, key)
+		require.ErrorIs(t, err, service.ErrAuditChainBroken, name)
+	}
+}
+
+// TestAuditLogRecovery checks that a partly written last line is removed when the log is opened, that records larger than a scanner's buffer are read back, and that the log fails closed once it can not be written.
+func TestAuditLogRecovery(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(os.Getuid())
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Roles: []service.Role{service.RoleViewer}, Clients: []service.ClientConfig{{UID: &uid}}},
+	}})
+	require.NoError(t, err)
+
+	key := bytes.Repeat([]byte{1}, 32)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := service.OpenAuditLog(path, key)
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+	client := unixClient(t, jobStore, grpc.ChainUnaryInterceptor(auditLog.UnaryAudit, authorizer.UnaryAuth), grpc.ChainStreamInterceptor(auditLog.StreamAudit, authorizer.StreamAuth))
+	ctx := context.Background()
+
+	longArg := string(bytes.Repeat([]byte{'a'}, 2*1024*1024))
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "echo", Args: []string{longArg}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.NoError(t, auditLog.Close())
+
+	// a crash while writing leaves an incomplete line at the end of the log
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, append(append([]byte{}, data...), data[:100]...), 0600))
+
+	last, err := service.VerifyAuditLog(path, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), last.Seq)
+	require.Equal(t, longArg, last.Args[0])
+
+	auditLog, err = service.OpenAuditLog(path, key)
+	require.NoError(t, err)
+	repaired, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, repaired)
+
+	// once a record can not be written, the call fails, and so does every call after it
+	client = unixClient(t, jobStore, grpc.ChainUnaryInterceptor(auditLog.UnaryAudit, authorizer.UnaryAuth))
+	_, err = client.JobList(ctx, &pb.JobListRequest{})
+	require.NoError(t, err)
+	require.NoError(t, auditLog.Close())
+
+	_, err = client.JobList(ctx, &pb.JobListRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = client.JobList(ctx, &pb.JobListRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	last, err = service.VerifyAuditLog(path, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), last.Seq)
+}
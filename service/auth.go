@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
+	"sync"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -10,112 +12,303 @@ import (
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"github.com/mlaradji/int-backend-mohamed/worker"
 	log "github.com/sirupsen/logrus"
 )
 
-const userIdKey = ContextKey("userId")
-
-var (
-	clientUserMap = map[ClientId]string{
-		{Issuer: "CN=CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA", Subject: "CN=Client 1,OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"}: "client1",
-		{Issuer: "CN=CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA", Subject: "CN=Client 2,OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"}: "client2",
-	} // clientUserMap maps certificate Issuer+Subject to userId. Clients not in this map will not be allowed access.
+const (
+	userKey  = ContextKey("user")
+	scopeKey = ContextKey("scope")
 )
 
-// ClientID uniquely identifies a client, and is composed of the client certificate's issue and subject.
-type ClientId struct {
-	Issuer  string
-	Subject string
-}
-
 // ContextKey simply wraps around a string value, allowing us to avoid setting built-in type context keys.
 type ContextKey string
 
-// clientIdFromContext extracts the client ID from a request context.
-func clientIdFromContext(ctx context.Context) (ClientId, error) {
+// certificateFromContext extracts the verified client certificate from a request context.
+func certificateFromContext(ctx context.Context) (*x509.Certificate, error) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
-		return ClientId{}, errors.New("unable to determine peer")
+		return nil, errors.New("unable to determine peer")
 	}
 
 	tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo)
 	if !ok {
-		return ClientId{}, errors.New("invalid TLS credentials")
+		return nil, errors.New("invalid TLS credentials")
 	}
 
 	// check that VerifiedChains has the certificate
 	if len(tlsAuth.State.VerifiedChains) == 0 {
-		return ClientId{}, errors.New("no certificate chains in TLS state")
+		return nil, errors.New("no certificate chains in TLS state")
 	}
 	if len(tlsAuth.State.VerifiedChains[0]) == 0 {
-		return ClientId{}, errors.New("no certificate found in first chain in TLS state")
+		return nil, errors.New("no certificate found in first chain in TLS state")
 	}
 
-	cert := tlsAuth.State.VerifiedChains[0][0]
-	clientId := ClientId{Issuer: cert.Issuer.ToRDNSequence().String(), Subject: cert.Subject.ToRDNSequence().String()}
+	return tlsAuth.State.VerifiedChains[0][0], nil
+}
 
-	return clientId, nil
+// setUserInContext creates a new context with the passed user and the scope of the permission they were granted attached.
+func setUserInContext(ctx context.Context, user *User, scope Scope) context.Context {
+	return context.WithValue(context.WithValue(ctx, userKey, user), scopeKey, scope)
 }
 
-// setUserIdInContext creates a new context with the passed user ID attached.
-func setUserIdInContext(ctx context.Context, userId string) context.Context {
-	return context.WithValue(ctx, userIdKey, userId)
+// GetUserFromContext retrieves the user from the context, returning an error if it is missing or invalid.
+func GetUserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok || user == nil {
+		return nil, errors.New("user is missing or invalid")
+	}
+
+	return user, nil
 }
 
-//GetUserIdFromContext retrieves the user id value from the context, returning an error if it is missing or invalid.
+// GetUserIdFromContext retrieves the user id value from the context, returning an error if it is missing or invalid.
 func GetUserIdFromContext(ctx context.Context) (string, error) {
-	userId, ok := ctx.Value(userIdKey).(string)
-	if !ok {
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
 		return "", errors.New("client id is missing or invalid")
 	}
 
-	return userId, nil
+	return user.Id, nil
+}
+
+// GetScopeFromContext retrieves the scope at which the client was granted the permission required by the current RPC. ScopeNone is returned if it is missing.
+func GetScopeFromContext(ctx context.Context) Scope {
+	scope, _ := ctx.Value(scopeKey).(Scope)
+	return scope
+}
+
+// Authorizer authenticates and authorizes clients against an authorization policy. Every RPC must declare the permission it requires in `methodPermissions`, and requests for a specific job are only let through if the job is within the scope that the permission is granted at. The policy can be swapped at any time, and requests that arrive afterwards are checked against the new policy.
+type Authorizer struct {
+	policy *Policy
+	crl    *RevocationList  // crl is used to reject revoked client certificates, if it is not nil.
+	tokens *TokenAuthority  // tokens is used to verify bearer tokens, if it is not nil. Otherwise, only client certificates are accepted.
+	mu     *sync.RWMutex    // mu controls access to `policy`, `crl` and `tokens`.
+	store  *worker.JobStore // store is used to look up the jobs that requests refer to.
+}
+
+// NewAuthorizer returns a new Authorizer that uses `policy` to authorize clients to access jobs in `store`.
+func NewAuthorizer(policy *Policy, store *worker.JobStore) *Authorizer {
+	return &Authorizer{policy: policy, mu: &sync.RWMutex{}, store: store}
+}
+
+// GetPolicy returns the policy currently used to authorize clients in a thread-safe way.
+func (auth *Authorizer) GetPolicy() *Policy {
+	auth.mu.RLock()
+	defer auth.mu.RUnlock()
+	return auth.policy
+}
+
+// SetPolicy atomically replaces the policy used to authorize clients.
+func (auth *Authorizer) SetPolicy(policy *Policy) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	auth.policy = policy
 }
 
-// UnaryAuth is a unary gRPC interceptor that authenticates and authorizes clients, and attaches their user ID to the request context.
-func UnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	userId, err := authorize(ctx)
+// SetRevocationList makes the authorizer reject client certificates revoked by `crl`. Since TLS connections can be long-lived, this catches certificates that were revoked after the connection was established.
+func (auth *Authorizer) SetRevocationList(crl *RevocationList) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	auth.crl = crl
+}
+
+// getRevocationList returns the revocation list in a thread-safe way.
+func (auth *Authorizer) getRevocationList() *RevocationList {
+	auth.mu.RLock()
+	defer auth.mu.RUnlock()
+	return auth.crl
+}
+
+// SetTokenAuthority makes the authorizer accept bearer tokens verified by `tokens` as an alternative to client certificates.
+func (auth *Authorizer) SetTokenAuthority(tokens *TokenAuthority) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	auth.tokens = tokens
+}
+
+// getTokenAuthority returns the token authority in a thread-safe way.
+func (auth *Authorizer) getTokenAuthority() *TokenAuthority {
+	auth.mu.RLock()
+	defer auth.mu.RUnlock()
+	return auth.tokens
+}
+
+// UnaryAuth is a unary gRPC interceptor that authenticates and authorizes clients, and attaches their user to the request context.
+func (auth *Authorizer) UnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, scope, err := auth.authorize(ctx, info.FullMethod, req)
 	if err != nil {
 		return nil, err
 	}
 
-	// attach userId to context
-	ctxUser := setUserIdInContext(ctx, userId)
+	// attach user to context
+	ctxUser := setUserInContext(ctx, user, scope)
 
 	return handler(ctxUser, req)
 }
 
-// StreamAuth is a server stream gRPC interceptor that authenticates and authorizes clients, and attaches their user ID to the request context.
-func StreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	userId, err := authorize(ss.Context())
+// StreamAuth is a server stream gRPC interceptor that authenticates and authorizes clients, and attaches their user to the request context. The client is authorized again before every message sent or received, so that policy changes also apply to in-flight streams.
+func (auth *Authorizer) StreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, scope, err := auth.authorize(ss.Context(), info.FullMethod, nil)
 	if err != nil {
 		return err
 	}
 
-	// attach userId to context
-	ctxUser := setUserIdInContext(ss.Context(), userId)
+	// attach user to context
+	ctxUser := setUserInContext(ss.Context(), user, scope)
 	sswc := NewServerStreamWithContext(ctxUser, ss)
 
-	return handler(srv, sswc)
+	return handler(srv, &authorizedServerStream{ServerStreamWithContext: sswc, auth: auth, method: info.FullMethod, userId: user.Id, mu: &sync.Mutex{}})
 }
 
-// authorize gets the client ID from the context, checks it against the global user map, and returns the userId.
-func authorize(ctx context.Context) (string, error) {
-	logger := log.WithFields(log.Fields{"func": "authenticateAndAuthorize"})
+// authorize authenticates the client, checks that it holds the permission required by `method`, and that the job `req` refers to (if any) is within scope. It returns the client's user and the scope the permission is held at.
+func (auth *Authorizer) authorize(ctx context.Context, method string, req interface{}) (*User, Scope, error) {
+	logger := log.WithFields(log.Fields{"func": "Authorizer.authorize", "method": method})
 
-	// client authentication
-	clientId, err := clientIdFromContext(ctx)
+	// client authentication and authorization
+	user, viaToken, err := auth.authenticate(ctx, logger)
 	if err != nil {
-		logger.WithError(err).Error("unable to get client id from context")
-		return "", status.Error(codes.Unauthenticated, "unable to determine client id")
+		return nil, ScopeNone, err
 	}
 
-	// client authorization
-	userId, ok := clientUserMap[clientId]
+	logger = logger.WithFields(log.Fields{"userId": user.Id, "viaToken": viaToken})
+
+	// permission check
+	permission, ok := methodPermissions[method]
+	if !ok {
+		logger.Error("method does not declare a permission")
+		return nil, ScopeNone, status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+	}
+
+	// local clients have no certificate to bind a token to, so a token issued to them could not be revoked and would be accepted over TCP
+	if _, local := peerCredFromContext(ctx); certificateOnlyMethods[method] && (viaToken || local) {
+		logger.Debug("method requires a client certificate")
+		return nil, ScopeNone, status.Error(codes.PermissionDenied, "method requires a client certificate")
+	}
+
+	scope := user.Scope(permission)
+	if scope == ScopeNone {
+		logger.WithField("permission", permission).Debug("user does not hold the required permission")
+		return nil, ScopeNone, status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+	}
+
+	// job access check
+	jobReq, ok := req.(jobRequest)
+	if !ok {
+		return user, scope, nil
+	}
+
+	job, err := auth.store.LoadJobById(jobReq.GetJobId())
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return nil, ScopeNone, status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return nil, ScopeNone, status.Error(codes.Internal, "job is invalid")
+	}
+
+	// jobs out of scope are reported as not found, so that clients can not probe for job ids of other users
+	if !user.CanAccess(scope, job) {
+		logger.WithFields(log.Fields{"permission": permission, "jobKey": job.Key}).Debug("job is not within the scope of the user's permission")
+		return nil, ScopeNone, status.Error(codes.NotFound, "job was not found")
+	}
+
+	return user, scope, nil
+}
+
+// authenticate returns the user of the client, which is identified by a bearer token in the request metadata if one was passed, and otherwise by the uid of its process for Unix socket connections or by its client certificate. It also returns whether a token was used.
+func (auth *Authorizer) authenticate(ctx context.Context, logger *log.Entry) (*User, bool, error) {
+	token, ok, err := tokenFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Debug("malformed authorization metadata")
+		return nil, false, status.Error(codes.Unauthenticated, "malformed authorization metadata")
+	}
+
+	if ok {
+		tokens := auth.getTokenAuthority()
+		if tokens == nil {
+			return nil, false, status.Error(codes.Unauthenticated, "token authentication is not enabled")
+		}
+
+		verified, err := tokens.Verify(token)
+		if err != nil {
+			logger.WithError(err).Debug("rejected a bearer token")
+			return nil, false, status.Error(codes.Unauthenticated, err.Error())
+		}
+		userId := verified.UserId
+
+		// tokens stop working when the certificate that they were issued with is revoked
+		if crl := auth.getRevocationList(); crl != nil && verified.CertSerial != nil {
+			if err := crl.CheckSerial(verified.CertIssuer, verified.CertSerial); err != nil {
+				setAuditIdentity(ctx, &AuditClient{Auth: AuditAuthToken}, userId)
+				return nil, false, status.Errorf(codes.Unauthenticated, "the token was issued with a certificate that is no longer valid: %s", err)
+			}
+		}
+
+		// users removed from the policy lose access even if their token has not expired yet
+		setAuditIdentity(ctx, &AuditClient{Auth: AuditAuthToken}, userId)
+		user, ok := auth.GetPolicy().LookupUser(userId)
+		if !ok {
+			logger.WithField("userId", userId).Debug("token was issued to a user that is no longer authorized")
+			return nil, false, status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+		}
+
+		return user, true, nil
+	}
+
+	// local clients are identified by the uid of their process
+	if peerCred, ok := peerCredFromContext(ctx); ok {
+		uid := peerCred.Uid
+		user, ok := auth.GetPolicy().IdentifyUid(uid)
+		setAuditIdentity(ctx, &AuditClient{Auth: AuditAuthUnix, Uid: &uid, Pid: peerCred.Pid}, userIdOf(user))
+		if !ok {
+			logger.WithFields(log.Fields{"uid": peerCred.Uid, "pid": peerCred.Pid}).Debug("local client is not authorized to access resource")
+			return nil, false, status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+		}
+
+		return user, false, nil
+	}
+
+	cert, err := certificateFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to get client certificate from context")
+		return nil, false, status.Error(codes.Unauthenticated, "unable to determine client id")
+	}
+
+	if crl := auth.getRevocationList(); crl != nil {
+		if err := crl.Check(cert); err != nil {
+			setAuditIdentity(ctx, &AuditClient{Auth: AuditAuthCertificate, Subject: cert.Subject.String(), Fingerprint: Fingerprint(cert)}, "")
+			return nil, false, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	user, clientId, ok := auth.GetPolicy().Identify(cert)
+	setAuditIdentity(ctx, &AuditClient{Auth: AuditAuthCertificate, Subject: cert.Subject.String(), Fingerprint: Fingerprint(cert)}, userIdOf(user))
 	if !ok {
 		logger.WithField("clientId", clientId).Debug("client is not authorized to access resource")
-		return "", status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+		return nil, false, status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+	}
+
+	return user, false, nil
+}
+
+// userIdOf returns the id of `user`, or an empty string if it is nil.
+func userIdOf(user *User) string {
+	if user == nil {
+		return ""
 	}
+	return user.Id
+}
+
+// jobRequest is implemented by requests that refer to an existing job.
+type jobRequest interface {
+	GetJobId() string
+}
 
-	return userId, nil
+// IsAuthError returns true if `err` is a gRPC error emitted because the client is not (or is no longer) authenticated or authorized. Jobs that are out of the client's scope are reported as not found, so NotFound is included.
+func IsAuthError(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unauthenticated || code == codes.PermissionDenied || code == codes.NotFound
 }
@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// peerContext returns a context that looks like it belongs to a request from a client that authenticated with the certificate at `certPath`.
+func peerContext(t *testing.T, certPath string) context.Context {
+	data, err := ioutil.ReadFile(certPath)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	tlsInfo := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tlsInfo})
+}
+
+// clientConfig returns the config entry matching the example client certificate with common name `commonName`. Client 4 was signed by the untrusted CA 2, but it is only used to build contexts that look like the client was already authenticated.
+func clientConfig(commonName string) service.ClientConfig {
+	issuer := testIssuer
+	if commonName == "Client 4" {
+		issuer = "CN=Untrusted CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+		commonName = "Client 3" // client 4's certificate reuses client 3's subject
+	}
+	return service.ClientConfig{Issuer: issuer, Subject: "CN=" + commonName + ",OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"}
+}
+
+// TestRoleBasedAccess checks that the interceptor enforces the permission declared by each RPC, and the scope that each role grants it at.
+func TestRoleBasedAccess(t *testing.T) {
+	t.Parallel()
+
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "admin", Roles: []service.Role{service.RoleAdmin}, Clients: []service.ClientConfig{clientConfig("Client 1")}},
+		{Id: "user", Roles: []service.Role{service.RoleUser}, Clients: []service.ClientConfig{clientConfig("Client 2")}},
+		{Id: "viewer", Roles: []service.Role{service.RoleViewer}, Clients: []service.ClientConfig{clientConfig("Client 3")}},
+		{Id: "teammate", Roles: []service.Role{service.RoleUser}, Clients: []service.ClientConfig{clientConfig("Client 4")}},
+	}, Groups: []service.GroupConfig{
+		{Name: "team", Members: []string{"user", "teammate"}},
+	}})
+	require.NoError(t, err)
+
+	store := worker.NewJobStore()
+	job, err := store.AddJob("user", "echo", []string{"testing"})
+	require.NoError(t, err)
+	sharedJob, err := store.AddJob("user", "echo", []string{"testing"}, worker.WithGroup("team"))
+	require.NoError(t, err)
+
+	auth := service.NewAuthorizer(policy, store)
+
+	testCases := []struct {
+		name     string
+		certPath string
+		method   string
+		req      interface{}
+		code     codes.Code
+		scope    service.Scope
+	}{
+		{"user can start", "../certs/client2/cert.pem", "JobStart", &pb.JobStartRequest{}, codes.OK, service.ScopeOwn},
+		{"user can view own job", "../certs/client2/cert.pem", "JobStatus", &pb.JobStatusRequest{JobId: job.Key.JobId}, codes.OK, service.ScopeShared},
+		{"admin can stop any job", "../certs/client1/cert.pem", "JobStop", &pb.JobStopRequest{JobId: job.Key.JobId}, codes.OK, service.ScopeAny},
+		{"admin can list any job", "../certs/client1/cert.pem", "JobList", &pb.JobListRequest{}, codes.OK, service.ScopeAny},
+		{"viewer can not start", "../certs/client3/cert.pem", "JobStart", &pb.JobStartRequest{}, codes.PermissionDenied, service.ScopeNone},
+		{"viewer can not stop", "../certs/client3/cert.pem", "JobStop", &pb.JobStopRequest{JobId: job.Key.JobId}, codes.PermissionDenied, service.ScopeNone},
+		{"viewer can not view unshared job", "../certs/client3/cert.pem", "JobStatus", &pb.JobStatusRequest{JobId: job.Key.JobId}, codes.NotFound, service.ScopeNone},
+		{"group member can stop shared job", "../certs/client4/cert.pem", "JobStop", &pb.JobStopRequest{JobId: sharedJob.Key.JobId}, codes.OK, service.ScopeShared},
+		{"admin can not attach to another user's job", "../certs/client1/cert.pem", "JobAttach", &pb.JobAttachRequest{JobId: job.Key.JobId}, codes.NotFound, service.ScopeNone},
+		{"group member can not attach to shared job", "../certs/client4/cert.pem", "JobAttach", &pb.JobAttachRequest{JobId: sharedJob.Key.JobId}, codes.NotFound, service.ScopeNone},
+		{"group member can not view unshared job", "../certs/client4/cert.pem", "JobLogsStream", &pb.JobLogsRequest{JobId: job.Key.JobId}, codes.NotFound, service.ScopeNone},
+		{"viewer can not view job shared with another group", "../certs/client3/cert.pem", "JobStatus", &pb.JobStatusRequest{JobId: sharedJob.Key.JobId}, codes.NotFound, service.ScopeNone},
+		{"missing job", "../certs/client1/cert.pem", "JobStatus", &pb.JobStatusRequest{JobId: "dummy"}, codes.NotFound, service.ScopeNone},
+		{"undeclared method", "../certs/client1/cert.pem", "JobDelete", &pb.JobStatusRequest{JobId: job.Key.JobId}, codes.PermissionDenied, service.ScopeNone},
+	}
+
+	for _, testCase := range testCases {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.JobService_ServiceDesc.ServiceName + "/" + testCase.method}
+
+		var scope service.Scope
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			scope = service.GetScopeFromContext(ctx)
+			return nil, nil
+		}
+
+		_, err := auth.UnaryAuth(peerContext(t, testCase.certPath), testCase.req, info, handler)
+		require.Equal(t, testCase.code, status.Code(err), testCase.name)
+		require.Equal(t, testCase.scope, scope, testCase.name)
+	}
+}
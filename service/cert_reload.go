@@ -0,0 +1,218 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+// CertificateReloader holds a key pair and a pool of trusted CAs, and reloads them from disk whenever one of the files changes. This allows certificates to be renewed, and CAs to be added or removed, without restarting the process.
+type CertificateReloader struct {
+	caCertPaths []string
+	certPath    string
+	keyPath     string
+	passphrase  []byte // passphrase decrypts the key, if it is encrypted.
+
+	mu       *sync.RWMutex        // mu controls access to the fields below.
+	cert     *tls.Certificate     // cert is the currently loaded key pair.
+	certPool *x509.CertPool       // certPool contains the currently loaded CA certificates.
+	modTimes map[string]time.Time // modTimes contains the modification time of each file when it was last loaded.
+}
+
+// CertificateReloaderOption sets an optional field of a CertificateReloader.
+type CertificateReloaderOption func(reloader *CertificateReloader)
+
+// WithKeyPassphrase decrypts the key with `passphrase`, for keys that were encrypted by `worker-server certs`.
+func WithKeyPassphrase(passphrase []byte) CertificateReloaderOption {
+	return func(reloader *CertificateReloader) {
+		reloader.passphrase = passphrase
+	}
+}
+
+// NewCertificateReloader loads the key pair at `certPath` and `keyPath`, and the CA certificates in `caCertPaths`. Multiple CA files can be passed to trust both the old and new CA during a CA rollover. Clients that authenticate with a bearer token can leave `certPath` and `keyPath` empty.
+func NewCertificateReloader(caCertPaths []string, certPath string, keyPath string, opts ...CertificateReloaderOption) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{caCertPaths: caCertPaths, certPath: certPath, keyPath: keyPath, mu: &sync.RWMutex{}}
+	for _, opt := range opts {
+		opt(reloader)
+	}
+
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Reload reads all files again. If any of them can not be loaded, the previously loaded certificates are kept and an error is returned.
+func (reloader *CertificateReloader) Reload() error {
+	// record modification times before reading, so that a change during reading triggers another reload
+	modTimes, err := reloader.statFiles()
+	if err != nil {
+		return err
+	}
+
+	certPool, err := loadCertPool(reloader.caCertPaths)
+	if err != nil {
+		return err
+	}
+
+	cert := tls.Certificate{}
+	if reloader.certPath != "" {
+		cert, err = loadKeyPair(reloader.certPath, reloader.keyPath, reloader.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+	reloader.cert = &cert
+	reloader.certPool = certPool
+	reloader.modTimes = modTimes
+
+	return nil
+}
+
+// statFiles returns the modification time of every file.
+func (reloader *CertificateReloader) statFiles() (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, path := range append([]string{reloader.certPath, reloader.keyPath}, reloader.caCertPaths...) {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// reloadIfChanged reloads the files if any of them changed since they were last loaded. Errors are logged, and the previously loaded certificates are kept.
+func (reloader *CertificateReloader) reloadIfChanged() {
+	logger := log.WithFields(log.Fields{"func": "CertificateReloader.reloadIfChanged", "certPath": reloader.certPath})
+
+	modTimes, err := reloader.statFiles()
+	if err != nil {
+		logger.WithError(err).Error("unable to check certificate files for changes")
+		return
+	}
+
+	reloader.mu.RLock()
+	changed := false
+	for path, modTime := range modTimes {
+		if !modTime.Equal(reloader.modTimes[path]) {
+			changed = true
+		}
+	}
+	reloader.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	if err := reloader.Reload(); err != nil {
+		logger.WithError(err).Error("unable to reload certificates, keeping the previous ones")
+		return
+	}
+	logger.Info("reloaded certificates")
+}
+
+// get returns the current key pair and CA pool, reloading them first if they changed on disk.
+func (reloader *CertificateReloader) get() (*tls.Certificate, *x509.CertPool) {
+	reloader.reloadIfChanged()
+
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, reloader.certPool
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate to serve the current server certificate.
+func (reloader *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _ := reloader.get()
+	return cert, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate to present the current client certificate. If no certificate was loaded, none is presented.
+func (reloader *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _ := reloader.get()
+	return cert, nil
+}
+
+// MakeReloadingServerTLSCredentials generates server-side TLS configuration that picks up changes to the server certificate and the trusted CAs on every handshake. `clientAuth` is tls.RequireAndVerifyClientCert, unless clients can also authenticate with a bearer token. Revoked client certificates are not rejected here, but by the Authorizer, so that clients get an Unauthenticated error instead of a failed handshake.
+func MakeReloadingServerTLSCredentials(reloader *CertificateReloader, clientAuth tls.ClientAuthType) credentials.TransportCredentials {
+	base := &tls.Config{GetCertificate: reloader.GetCertificate, ClientAuth: clientAuth, MinVersion: tls.VersionTLS13, NextProtos: []string{"h2"}}
+
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		_, certPool := reloader.get()
+
+		clientConfig := base.Clone()
+		clientConfig.ClientCAs = certPool
+		return clientConfig, nil
+	}
+
+	return credentials.NewTLS(config)
+}
+
+// MakeReloadingClientTLSCredentials generates client-side TLS configuration that picks up changes to the client certificate and the trusted CAs on every handshake, e.g. when a long-lived stream reconnects.
+func MakeReloadingClientTLSCredentials(reloader *CertificateReloader) credentials.TransportCredentials {
+	config := &tls.Config{
+		GetClientCertificate: reloader.GetClientCertificate,
+		// the server certificate is verified in VerifyConnection instead, since RootCAs can not be changed after the config is created
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			_, certPool := reloader.get()
+			return verifyServerCertificate(state, certPool)
+		},
+	}
+
+	return credentials.NewTLS(config)
+}
+
+// verifyServerCertificate performs the same verification of the server's certificate chain and name that crypto/tls does by default, against `certPool`.
+func verifyServerCertificate(state tls.ConnectionState, certPool *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server did not present a certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: certPool, Intermediates: intermediates, DNSName: state.ServerName})
+	return err
+}
+
+// loadCertPool loads all CA certificates in the files at `caCertPaths` into a new pool.
+func loadCertPool(caCertPaths []string) (*x509.CertPool, error) {
+	if len(caCertPaths) == 0 {
+		return nil, errors.New("at least one CA certificate is required")
+	}
+
+	certPool := x509.NewCertPool()
+	for _, caCertPath := range caCertPaths {
+		caCert, err := ioutil.ReadFile(caCertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok := certPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("%s: failed to append CA to certificate pool", caCertPath)
+		}
+	}
+
+	return certPool, nil
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+)
+
+// writeFile writes `data` to `path`, and moves the modification time forward so that the change is detected even on filesystems with a coarse timestamp granularity.
+func writeFile(t *testing.T, path string, data []byte) {
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil && !modTime.After(info.ModTime()) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// writeCert writes the CA certificate to `path`.
+func (ca *testCA) writeCert(t *testing.T, path string) {
+	writeFile(t, path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+}
+
+// writeKeyPair issues a certificate for `localhost` with common name `commonName`, which can be used both as a server and a client certificate, and writes it to `certPath` and `keyPath`.
+func (ca *testCA) writeKeyPair(t *testing.T, certPath string, keyPath string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writeFile(t, certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+// handshake performs a TLS handshake over a loopback connection, and returns the common name of the certificate that the server presented and the server's handshake error.
+func handshake(t *testing.T, serverCreds credentials.TransportCredentials, clientCreds credentials.TransportCredentials) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+
+		_, _, err = serverCreds.ServerHandshake(conn)
+		serverErr <- err
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, authInfo, err := clientCreds.ClientHandshake(context.Background(), "localhost", conn)
+	require.NoError(t, err)
+
+	return authInfo.(credentials.TLSInfo).State.PeerCertificates[0].Subject.CommonName, <-serverErr
+}
+
+// TestCertificateReloader checks that renewed certificates and CA rollovers are picked up by new connections without restarting.
+func TestCertificateReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	oldCA, newCA := newTestCA(t, "Old CA"), newTestCA(t, "New CA")
+	oldCA.writeCert(t, path("ca.pem"))
+	newCA.writeCert(t, path("new-ca.pem"))
+	oldCA.writeKeyPair(t, path("server.pem"), path("server-key.pem"), "Server 1")
+	oldCA.writeKeyPair(t, path("client.pem"), path("client-key.pem"), "Client")
+
+	serverReloader, err := service.NewCertificateReloader([]string{path("ca.pem")}, path("server.pem"), path("server-key.pem"))
+	require.NoError(t, err)
+	clientReloader, err := service.NewCertificateReloader([]string{path("ca.pem"), path("new-ca.pem")}, path("client.pem"), path("client-key.pem"))
+	require.NoError(t, err)
+
+	serverCreds := service.MakeReloadingServerTLSCredentials(serverReloader, tls.RequireAndVerifyClientCert)
+	clientCreds := service.MakeReloadingClientTLSCredentials(clientReloader)
+
+	serverName, err := handshake(t, serverCreds, clientCreds)
+	require.NoError(t, err)
+	require.Equal(t, "Server 1", serverName)
+
+	// a renewed server certificate is served to new connections
+	oldCA.writeKeyPair(t, path("server.pem"), path("server-key.pem"), "Server 2")
+	serverName, err = handshake(t, serverCreds, clientCreds)
+	require.NoError(t, err)
+	require.Equal(t, "Server 2", serverName)
+
+	// a client certificate issued by a CA that the server does not trust yet is rejected
+	newCA.writeKeyPair(t, path("client.pem"), path("client-key.pem"), "Client")
+	_, err = handshake(t, serverCreds, clientCreds)
+	require.Error(t, err)
+
+	// during a CA rollover, the server trusts both CAs once the new one is added to its bundle
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: oldCA.cert.Raw}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newCA.cert.Raw})...)
+	writeFile(t, path("ca.pem"), bundle)
+	_, err = handshake(t, serverCreds, clientCreds)
+	require.NoError(t, err)
+
+	// a malformed file does not replace the loaded certificates
+	writeFile(t, path("server.pem"), []byte("malformed"))
+	serverName, err = handshake(t, serverCreds, clientCreds)
+	require.NoError(t, err)
+	require.Equal(t, "Server 2", serverName)
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// KeyType is the type of key generated for a new certificate.
+type KeyType string
+
+const (
+	KeyTypeECDSA   KeyType = "ecdsa"   // KeyTypeECDSA generates ECDSA P-256 keys.
+	KeyTypeEd25519 KeyType = "ed25519" // KeyTypeEd25519 generates Ed25519 keys.
+	KeyTypeRSA     KeyType = "rsa"     // KeyTypeRSA generates 4096-bit RSA keys, for tools that do not support the other key types.
+)
+
+// CertificateUsage determines the key usages and basic constraints of a new certificate.
+type CertificateUsage string
+
+const (
+	UsageCA     CertificateUsage = "ca"     // UsageCA certificates can only sign certificates and CRLs.
+	UsageServer CertificateUsage = "server" // UsageServer certificates can only be used for TLS server authentication.
+	UsageClient CertificateUsage = "client" // UsageClient certificates can only be used for TLS client authentication.
+)
+
+var (
+	// distinguishedNameAttributes maps the attribute types accepted by ParseDistinguishedName to the pkix.Name field they set.
+	distinguishedNameAttributes = map[string]func(name *pkix.Name, value string){
+		"CN":           func(name *pkix.Name, value string) { name.CommonName = value },
+		"SERIALNUMBER": func(name *pkix.Name, value string) { name.SerialNumber = value },
+		"C":            func(name *pkix.Name, value string) { name.Country = append(name.Country, value) },
+		"O":            func(name *pkix.Name, value string) { name.Organization = append(name.Organization, value) },
+		"OU":           func(name *pkix.Name, value string) { name.OrganizationalUnit = append(name.OrganizationalUnit, value) },
+		"L":            func(name *pkix.Name, value string) { name.Locality = append(name.Locality, value) },
+		"ST":           func(name *pkix.Name, value string) { name.Province = append(name.Province, value) },
+		"STREET":       func(name *pkix.Name, value string) { name.StreetAddress = append(name.StreetAddress, value) },
+		"POSTALCODE":   func(name *pkix.Name, value string) { name.PostalCode = append(name.PostalCode, value) },
+	}
+)
+
+// CertificateTemplate describes a certificate to be issued by IssueCertificate.
+type CertificateTemplate struct {
+	Usage          CertificateUsage
+	Subject        pkix.Name
+	DNSNames       []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	EmailAddresses []string
+	KeyType        KeyType
+	Validity       time.Duration
+}
+
+// Issuer is a CA certificate and its key, which can issue new certificates.
+type Issuer struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// GenerateKey generates a new private key of type `keyType`.
+func GenerateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+}
+
+// IssueCertificate generates a new key and a certificate for it, signed by `issuer`. The certificate's validity is capped to that of its issuer. If `issuer` is nil, the certificate is self-signed, which is only allowed for CA certificates.
+func IssueCertificate(template CertificateTemplate, issuer *Issuer) (*x509.Certificate, crypto.Signer, error) {
+	if template.Subject.CommonName == "" {
+		return nil, nil, errors.New("the subject must have a common name")
+	}
+	if template.Validity <= 0 {
+		return nil, nil, errors.New("the validity must be positive")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	cert := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               template.Subject,
+		DNSNames:              template.DNSNames,
+		IPAddresses:           template.IPAddresses,
+		URIs:                  template.URIs,
+		EmailAddresses:        template.EmailAddresses,
+		NotBefore:             now.Add(-5 * time.Minute), // allow for some clock skew between hosts
+		NotAfter:              now.Add(template.Validity),
+		BasicConstraintsValid: true,
+	}
+
+	switch template.Usage {
+	case UsageCA:
+		cert.IsCA = true
+		cert.MaxPathLenZero = true // intermediate CAs are not supported
+		cert.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	case UsageServer:
+		if len(template.DNSNames) == 0 && len(template.IPAddresses) == 0 {
+			return nil, nil, errors.New("server certificates require at least one DNS or IP Subject Alternative Name")
+		}
+		cert.KeyUsage = x509.KeyUsageDigitalSignature
+		cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	case UsageClient:
+		cert.KeyUsage = x509.KeyUsageDigitalSignature
+		cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	default:
+		return nil, nil, fmt.Errorf("unknown certificate usage %q", template.Usage)
+	}
+
+	key, err := GenerateKey(template.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent, parentKey := cert, key
+	if issuer != nil {
+		if cert.NotAfter.After(issuer.Cert.NotAfter) {
+			cert.NotAfter = issuer.Cert.NotAfter
+		}
+		parent, parentKey = issuer.Cert, issuer.Key
+	} else if template.Usage != UsageCA {
+		return nil, nil, errors.New("only CA certificates can be self-signed")
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, cert, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// LoadIssuer loads a CA certificate and its key, which is decrypted with `passphrase` if it is encrypted.
+func LoadIssuer(certPath string, keyPath string, passphrase []byte) (*Issuer, error) {
+	keyPair, err := loadKeyPair(certPath, keyPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s: not a CA certificate", certPath)
+	}
+
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type", keyPath)
+	}
+
+	return &Issuer{Cert: cert, Key: key}, nil
+}
+
+// WriteCertificate writes `cert` to `path` in PEM format.
+func WriteCertificate(path string, cert *x509.Certificate) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644)
+}
+
+// WriteKey writes `key` to `path` in PKCS #8 PEM format, readable only by the current user. If `passphrase` is not empty, the key is encrypted as an encrypted PKCS #8 key, with PBKDF2-HMAC-SHA256 and AES-256-CBC. Existing keys are never overwritten.
+func WriteKey(path string, key crypto.Signer, passphrase []byte) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	if len(passphrase) > 0 {
+		block, err = encryptPKCS8(der, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(file, block); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadKeyPair loads a key pair like tls.LoadX509KeyPair, but also accepts encrypted PKCS #8 keys, and keys in the legacy PEM encryption of RFC 1423 written by earlier versions, which are decrypted with `passphrase`.
+func loadKeyPair(certPath string, keyPath string, passphrase []byte) (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block != nil && (block.Type == encryptedKeyBlockType || x509.IsEncryptedPEMBlock(block)) {
+		if len(passphrase) == 0 {
+			return tls.Certificate{}, fmt.Errorf("%s: the key is encrypted, but no passphrase was given", keyPath)
+		}
+
+		der, blockType := []byte(nil), "PRIVATE KEY"
+		if block.Type == encryptedKeyBlockType {
+			der, err = decryptPKCS8(block.Bytes, passphrase)
+		} else {
+			der, err = x509.DecryptPEMBlock(block, passphrase)
+			blockType = block.Type
+		}
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("%s: %w", keyPath, err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// ParseDistinguishedName parses a distinguished name in the format used by the authorization config, e.g. `CN=Client 1,OU=Client,O=Mohamed\, Inc.,C=CA`. Multi-valued RDNs are not supported.
+func ParseDistinguishedName(dn string) (pkix.Name, error) {
+	name := pkix.Name{}
+
+	// split on unescaped commas
+	attributes := []string{}
+	var attribute strings.Builder
+	for i := 0; i < len(dn); i++ {
+		switch {
+		case dn[i] == '\\' && i+1 < len(dn):
+			i++
+			attribute.WriteByte(dn[i])
+		case dn[i] == ',':
+			attributes = append(attributes, attribute.String())
+			attribute.Reset()
+		case dn[i] == '+':
+			return pkix.Name{}, errors.New("multi-valued RDNs are not supported")
+		default:
+			attribute.WriteByte(dn[i])
+		}
+	}
+	attributes = append(attributes, attribute.String())
+
+	// attributes are listed from the most to the least specific, so they are applied in reverse to preserve the order of repeated attributes
+	for i := len(attributes) - 1; i >= 0; i-- {
+		parts := strings.SplitN(attributes[i], "=", 2)
+		if len(parts) != 2 {
+			return pkix.Name{}, fmt.Errorf("%q is not of the form TYPE=value", attributes[i])
+		}
+
+		set, ok := distinguishedNameAttributes[strings.ToUpper(strings.TrimSpace(parts[0]))]
+		if !ok {
+			return pkix.Name{}, fmt.Errorf("unsupported attribute type %q", parts[0])
+		}
+		set(&name, parts[1])
+	}
+
+	return name, nil
+}
+
+// ClientConfigs returns, for each identity source, the authorization config entries that identify `cert`.
+func ClientConfigs(cert *x509.Certificate) map[IdentitySource][]ClientConfig {
+	configs := map[IdentitySource][]ClientConfig{}
+	for source, extract := range identityExtractors {
+		for _, clientId := range extract(cert) {
+			config := ClientConfig{}
+			switch source {
+			case IdentitySubject:
+				config.Issuer, config.Subject = clientId.Issuer, clientId.Subject
+			case IdentityURI:
+				config.URI = clientId.Name
+			case IdentityDNS:
+				config.DNS = clientId.Name
+			case IdentityEmail:
+				config.Email = clientId.Name
+			case IdentityFingerprint:
+				config.Fingerprint = clientId.Name
+			}
+			configs[source] = append(configs[source], config)
+		}
+	}
+
+	return configs
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCertificateRevoked = errors.New("client certificate has been revoked")
+	ErrCRLExpired         = errors.New("certificate revocation list has expired")
+)
+
+// RevocationList holds the serial numbers of revoked client certificates, loaded from a local CRL file. The CRL must be signed by one of the trusted CAs.
+type RevocationList struct {
+	path    string
+	issuers []*x509.Certificate // issuers are the CA certificates that the CRL can be signed by.
+
+	mu        *sync.RWMutex       // mu controls access to the fields below.
+	issuer    []byte              // issuer is the raw subject of the CA that signed the CRL. Only certificates issued by this CA can be revoked by the CRL.
+	revoked   map[string]struct{} // revoked is the set of revoked serial numbers, formatted in base 10.
+	updatedAt time.Time           // updatedAt is the CRL's thisUpdate time.
+	expiresAt time.Time           // expiresAt is the CRL's nextUpdate time, after which it is stale. It is zero if the CRL does not set one.
+}
+
+// LoadRevocationList loads the CRL at `crlPath`, and checks that it was signed by one of the CA certificates in `caCertPaths`.
+func LoadRevocationList(crlPath string, caCertPaths ...string) (*RevocationList, error) {
+	issuers := []*x509.Certificate{}
+	for _, caCertPath := range caCertPaths {
+		certs, err := loadCertificates(caCertPath)
+		if err != nil {
+			return nil, err
+		}
+		issuers = append(issuers, certs...)
+	}
+
+	crl := &RevocationList{path: crlPath, issuers: issuers, mu: &sync.RWMutex{}}
+	if err := crl.Reload(); err != nil {
+		return nil, err
+	}
+
+	return crl, nil
+}
+
+// Reload reads the CRL file again. If it can not be read, is not signed by a trusted CA, has expired, or is older than the currently loaded CRL, the currently loaded CRL is kept and an error is returned.
+func (crl *RevocationList) Reload() error {
+	data, err := ioutil.ReadFile(crl.path)
+	if err != nil {
+		return err
+	}
+
+	// accept both PEM and DER encoded CRLs
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	certList, err := x509.ParseDERCRL(data)
+	if err != nil {
+		return fmt.Errorf("%s: malformed CRL: %w", crl.path, err)
+	}
+
+	issuer, err := crl.verify(certList)
+	if err != nil {
+		return fmt.Errorf("%s: %w", crl.path, err)
+	}
+
+	nextUpdate := certList.TBSCertList.NextUpdate
+	if !nextUpdate.IsZero() && time.Now().After(nextUpdate) {
+		return fmt.Errorf("%s: CRL expired at %s", crl.path, nextUpdate)
+	}
+
+	revoked := map[string]struct{}{}
+	for _, revokedCert := range certList.TBSCertList.RevokedCertificates {
+		revoked[revokedCert.SerialNumber.String()] = struct{}{}
+	}
+
+	crl.mu.Lock()
+	defer crl.mu.Unlock()
+
+	if certList.TBSCertList.ThisUpdate.Before(crl.updatedAt) {
+		return fmt.Errorf("%s: CRL was issued at %s, which is older than the loaded CRL", crl.path, certList.TBSCertList.ThisUpdate)
+	}
+
+	crl.issuer = issuer.RawSubject
+	crl.revoked = revoked
+	crl.updatedAt = certList.TBSCertList.ThisUpdate
+	crl.expiresAt = nextUpdate
+
+	return nil
+}
+
+// verify checks that `certList` was signed by one of the trusted CAs, and returns that CA.
+func (crl *RevocationList) verify(certList *pkix.CertificateList) (*x509.Certificate, error) {
+	for _, issuer := range crl.issuers {
+		if issuer.CheckCRLSignature(certList) == nil {
+			return issuer, nil
+		}
+	}
+
+	return nil, errors.New("CRL is not signed by a trusted CA")
+}
+
+// Watch reloads the CRL file every `interval`, until `done` is closed. Reload errors are logged, and the previously loaded CRL is kept.
+func (crl *RevocationList) Watch(done <-chan struct{}, interval time.Duration) {
+	logger := log.WithFields(log.Fields{"func": "RevocationList.Watch", "path": crl.path})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := crl.Reload(); err != nil {
+					logger.WithError(err).Error("unable to reload CRL, keeping the previous one")
+					continue
+				}
+				logger.Debug("reloaded CRL")
+
+			case <-done:
+				logger.Debug("done signal received")
+				return
+			}
+		}
+	}()
+}
+
+// IsRevoked returns true if `cert` was issued by the CRL's CA and its serial number is in the CRL.
+func (crl *RevocationList) IsRevoked(cert *x509.Certificate) bool {
+	return crl.isRevoked(cert.RawIssuer, cert.SerialNumber)
+}
+
+// isRevoked returns true if the certificate with raw issuer `rawIssuer` and serial number `serial` was issued by the CRL's CA and is in the CRL.
+func (crl *RevocationList) isRevoked(rawIssuer []byte, serial *big.Int) bool {
+	crl.mu.RLock()
+	defer crl.mu.RUnlock()
+
+	if !bytes.Equal(rawIssuer, crl.issuer) {
+		return false
+	}
+
+	_, revoked := crl.revoked[serial.String()]
+	return revoked
+}
+
+// Check returns ErrCertificateRevoked and logs the serial number if `cert` was revoked. Once the CRL is past its nextUpdate time, it returns ErrCRLExpired for every certificate, since a stale CRL may miss recent revocations.
+func (crl *RevocationList) Check(cert *x509.Certificate) error {
+	return crl.check(cert.RawIssuer, cert.SerialNumber, log.Fields{"subject": cert.Subject.String()})
+}
+
+// CheckSerial is like Check for the certificate with raw issuer `rawIssuer` and serial number `serial`, e.g. the certificate that a bearer token was issued with.
+func (crl *RevocationList) CheckSerial(rawIssuer []byte, serial *big.Int) error {
+	return crl.check(rawIssuer, serial, log.Fields{})
+}
+
+// check implements Check and CheckSerial, and adds `fields` to the log entries of rejected certificates.
+func (crl *RevocationList) check(rawIssuer []byte, serial *big.Int, fields log.Fields) error {
+	logger := log.WithFields(log.Fields{"func": "RevocationList.Check", "serial": serial.String()}).WithFields(fields)
+
+	if expiresAt := crl.getExpiresAt(); !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		logger.WithFields(log.Fields{"path": crl.path, "expiresAt": expiresAt}).Error("rejected a client certificate, since the CRL has expired")
+		return ErrCRLExpired
+	}
+
+	if crl.isRevoked(rawIssuer, serial) {
+		logger.Warn("rejected a revoked client certificate")
+		return ErrCertificateRevoked
+	}
+
+	return nil
+}
+
+// getExpiresAt returns the CRL's nextUpdate time in a thread-safe way.
+func (crl *RevocationList) getExpiresAt() time.Time {
+	crl.mu.RLock()
+	defer crl.mu.RUnlock()
+	return crl.expiresAt
+}
+
+// loadCertificates loads all PEM encoded certificates in the file at `path`.
+func loadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+
+	return certs, nil
+}
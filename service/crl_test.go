@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// testCA is an in-memory CA that can issue client certificates and CRLs.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA generates a new self-signed CA.
+func newTestCA(t *testing.T, commonName string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+// issue issues a client certificate with serial number `serial`.
+func (ca *testCA) issue(t *testing.T, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "Client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+// writeCRL writes a PEM encoded CRL revoking `serials` to `path`, which expires in an hour.
+func (ca *testCA) writeCRL(t *testing.T, path string, number int64, serials ...int64) {
+	ca.writeCRLUntil(t, path, number, time.Now().Add(time.Hour), serials...)
+}
+
+// writeCRLUntil writes a PEM encoded CRL revoking `serials` to `path`, with nextUpdate time `nextUpdate`.
+func (ca *testCA) writeCRLUntil(t *testing.T, path string, number int64, nextUpdate time.Time, serials ...int64) {
+	revoked := []pkix.RevokedCertificate{}
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+
+	template := &x509.RevocationList{Number: big.NewInt(number), ThisUpdate: time.Now().Add(-time.Minute), NextUpdate: nextUpdate, RevokedCertificates: revoked}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644))
+}
+
+// TestRevocationList checks that revoked certificates are rejected by the interceptors with Unauthenticated, and that CRLs not signed by the CA are not loaded.
+func TestRevocationList(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	caPath, crlPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "crl.pem")
+
+	ca := newTestCA(t, "CA")
+	require.NoError(t, ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0644))
+
+	revokedCert, validCert := ca.issue(t, 100), ca.issue(t, 101)
+	ca.writeCRL(t, crlPath, 1, 100)
+
+	crl, err := service.LoadRevocationList(crlPath, caPath)
+	require.NoError(t, err)
+	require.True(t, crl.IsRevoked(revokedCert))
+	require.False(t, crl.IsRevoked(validCert))
+
+	require.ErrorIs(t, crl.Check(revokedCert), service.ErrCertificateRevoked)
+	require.NoError(t, crl.Check(validCert))
+
+	// revoked certificates pass the handshake, and are rejected by the interceptors, also on connections that were established before they were revoked
+	policy, err := service.NewPolicy(service.PolicyConfig{Identity: service.IdentityFingerprint, Users: []service.UserConfig{
+		{Id: "revoked", Clients: []service.ClientConfig{{Fingerprint: service.Fingerprint(revokedCert)}}},
+		{Id: "valid", Clients: []service.ClientConfig{{Fingerprint: service.Fingerprint(validCert)}}},
+	}})
+	require.NoError(t, err)
+
+	auth := service.NewAuthorizer(policy, worker.NewJobStore())
+	auth.SetRevocationList(crl)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.JobService_ServiceDesc.ServiceName + "/JobList"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for cert, code := range map[*x509.Certificate]codes.Code{revokedCert: codes.Unauthenticated, validCert: codes.OK} {
+		tlsInfo := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert, ca.cert}}}}
+		ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tlsInfo})
+
+		_, err := auth.UnaryAuth(ctx, &pb.JobListRequest{}, info, handler)
+		require.Equal(t, code, status.Code(err))
+	}
+
+	// tokens stop working when the certificate that they were issued with is revoked
+	tokens, err := service.NewHMACTokenAuthority([]byte(strings.Repeat("s", 32)), time.Hour)
+	require.NoError(t, err)
+	auth.SetTokenAuthority(tokens)
+	for userId, cert := range map[string]*x509.Certificate{"revoked": revokedCert, "valid": validCert} {
+		token, _, err := tokens.Issue(userId, cert, time.Hour)
+		require.NoError(t, err)
+		verified, err := tokens.Verify(token)
+		require.NoError(t, err)
+		require.Equal(t, cert.SerialNumber, verified.CertSerial)
+
+		_, err = auth.UnaryAuth(tokenContext(token), &pb.JobListRequest{}, info, handler)
+		if cert == revokedCert {
+			require.Equal(t, codes.Unauthenticated, status.Code(err))
+		} else {
+			require.NoError(t, err)
+		}
+	}
+
+	// a CRL signed by another CA should not replace the loaded one
+	newTestCA(t, "CA").writeCRL(t, crlPath, 2)
+	require.Error(t, crl.Reload())
+	require.True(t, crl.IsRevoked(revokedCert))
+
+	// a new CRL signed by the CA should be picked up by the watcher
+	done := make(chan struct{})
+	defer close(done)
+	crl.Watch(done, 10*time.Millisecond)
+
+	ca.writeCRL(t, crlPath, 3, 101)
+	require.Eventually(t, func() bool {
+		return crl.IsRevoked(validCert) && !crl.IsRevoked(revokedCert)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestRevocationListExpiry checks that expired CRLs are not loaded, and that a CRL that expires after it was loaded rejects all certificates until it is replaced.
+func TestRevocationListExpiry(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	caPath, crlPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "crl.pem")
+
+	ca := newTestCA(t, "CA")
+	require.NoError(t, ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0644))
+	cert := ca.issue(t, 100)
+
+	ca.writeCRLUntil(t, crlPath, 1, time.Now().Add(-time.Second))
+	_, err := service.LoadRevocationList(crlPath, caPath)
+	require.Error(t, err)
+
+	ca.writeCRLUntil(t, crlPath, 2, time.Now().Add(2*time.Second))
+	crl, err := service.LoadRevocationList(crlPath, caPath)
+	require.NoError(t, err)
+	require.NoError(t, crl.Check(cert))
+
+	require.Eventually(t, func() bool { return errors.Is(crl.Check(cert), service.ErrCRLExpired) }, 5*time.Second, 10*time.Millisecond)
+
+	ca.writeCRL(t, crlPath, 3)
+	require.NoError(t, crl.Reload())
+	require.NoError(t, crl.Check(cert))
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+)
+
+const (
+	redactedValue = "<redacted>" // redactedValue replaces the values of environment variables in job info, unless they are shown.
+)
+
+var (
+	DefaultEnvAllowlist = []string{"PATH", "LANG", "LC_ALL", "TZ"} // DefaultEnvAllowlist are the variables of the server's environment that jobs with base ENV_ALLOWLIST inherit by default.
+	DefaultEnvShown     = []string{"LANG", "LC_ALL", "TZ"}         // DefaultEnvShown are the variables whose values job info shows by default.
+
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`) // envNamePattern matches valid environment variable names.
+)
+
+// validateEnv returns an error if `env` sets an invalid variable, or one that the server sets itself.
+func validateEnv(env map[string]string) error {
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if name == worker.WorkspaceEnv || name == worker.SecretsEnv {
+			return fmt.Errorf("environment variable %s is set by the server", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("value of environment variable %s contains a null byte", name)
+		}
+	}
+
+	return nil
+}
+
+// baseEnv returns the variables of the server's environment that a job with `base` starts from, or nil if it inherits all of them. Jobs with base ENV_ALLOWLIST only inherit the variables in `allowlist`.
+func baseEnv(base pb.EnvBase, allowlist []string) ([]string, error) {
+	switch base {
+	case pb.EnvBase_ENV_INHERIT:
+		return nil, nil
+	case pb.EnvBase_ENV_EMPTY:
+		return []string{}, nil
+	case pb.EnvBase_ENV_ALLOWLIST:
+		environ := []string{}
+		for _, name := range allowlist {
+			if value, ok := os.LookupEnv(name); ok {
+				environ = append(environ, name+"="+value)
+			}
+		}
+		return environ, nil
+	}
+
+	return nil, errors.New("unknown base environment")
+}
+
+// validateWorkingDir returns an error if `dir` is not a valid working directory for a job in the root filesystem at `root`, or in the server's if it is empty. Absolute directories must exist, and relative ones must stay in the job's workspace.
+func validateWorkingDir(dir string, root string) error {
+	if strings.ContainsRune(dir, 0) {
+		return errors.New("working directory contains a null byte")
+	}
+
+	if !filepath.IsAbs(dir) {
+		if clean := filepath.Clean(dir); clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.New("relative working directories must be in the job's workspace")
+		}
+		return nil
+	}
+
+	info, err := os.Stat(filepath.Join(root, dir))
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("working directory %s does not exist", dir)
+	}
+
+	return nil
+}
+
+// redactEnv returns a copy of `env` with the values of all variables that are not in `shown` redacted, since any value can hold a secret.
+func redactEnv(env map[string]string, shown []string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(env))
+	for name := range env {
+		redacted[name] = redactedValue
+	}
+	for _, name := range shown {
+		if value, ok := env[name]; ok {
+			redacted[name] = value
+		}
+	}
+
+	return redacted
+}
+
+// ParseEnv parses environment variables in "name=value" form, e.g. from the command line.
+func ParseEnv(environ []string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("environment variable %q must be in name=value form", variable)
+		}
+		env[parts[0]] = parts[1]
+	}
+
+	return env, validateEnv(env)
+}
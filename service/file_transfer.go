@@ -0,0 +1,319 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	fileChunkSize   = 64 * 1024 // fileChunkSize is the largest chunk of a file that downloads send in one message.
+	defaultFileMode = 0644      // defaultFileMode is the mode of uploaded files that do not set one.
+)
+
+// FileUpload is a client-side streaming RPC to upload a file to the workspace of a held job. The file is written next to its destination, and only moved into place once its size and checksum were verified.
+func (server *JobServer) FileUpload(stream pb.JobService_FileUploadServer) error {
+	logger := log.WithFields(log.Fields{"func": "FileUpload"})
+
+	// get userId attached to context
+	userId, err := GetUserIdFromContext(stream.Context())
+	if err != nil {
+		logger.WithError(err).Error("unable to get userId from context")
+		return status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user id in context
+	}
+
+	// the first request decides the job and the file that the stream writes to
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no job id was sent")
+	}
+	if err != nil {
+		return err
+	}
+	jobId, path := req.GetJobId(), req.GetPath()
+
+	logger = logger.WithFields(log.Fields{"userId": userId, "jobId": jobId, "path": path})
+
+	logger.Debug("received a file upload request")
+
+	if server.MaxFileSizeMiB == 0 {
+		return status.Error(codes.FailedPrecondition, "file transfers are not enabled")
+	}
+
+	mode := os.FileMode(req.GetMode())
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+	if mode != mode.Perm() {
+		return status.Error(codes.InvalidArgument, "the mode of a file can only set permission bits")
+	}
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return status.Error(codes.Internal, "job is invalid")
+	}
+
+	upload, err := job.CreateFile(path, mode)
+	if err != nil {
+		return fileError(logger, err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			upload.Abort()
+		}
+	}()
+
+	// the checksum is computed while the file is written, and compared to the one sent by the client once the upload is complete
+	hash := sha256.New()
+	writer := io.MultiWriter(upload, hash)
+	maxSize := server.MaxFileSizeMiB << 20
+	size := uint64(0)
+	checksum := ""
+	for {
+		if req.GetJobId() != jobId {
+			return status.Error(codes.InvalidArgument, "all requests must be for the same job")
+		}
+
+		if data := req.GetData(); len(data) > 0 {
+			size += uint64(len(data))
+			if size > maxSize {
+				logger.Debug("file is too large")
+				return status.Errorf(codes.InvalidArgument, "files can be at most %d MiB", server.MaxFileSizeMiB)
+			}
+
+			if _, err := writer.Write(data); err != nil {
+				return fileError(logger, err)
+			}
+		}
+
+		if req.GetSha256() != "" {
+			checksum = req.GetSha256()
+		}
+
+		req, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if checksum == "" {
+		return status.Error(codes.InvalidArgument, "no checksum was sent")
+	}
+	if !strings.EqualFold(checksum, sum) {
+		logger.WithFields(log.Fields{"expected": checksum, "actual": sum}).Debug("checksum does not match")
+		return status.Error(codes.DataLoss, "the checksum of the uploaded file does not match")
+	}
+
+	if err := upload.Commit(); err != nil {
+		return fileError(logger, err)
+	}
+	committed = true
+
+	logger.WithField("size", size).Debug("uploaded a file")
+
+	return stream.SendAndClose(&pb.FileUploadResponse{Size: size, Sha256: sum})
+}
+
+// FileDownload is a server-side streaming RPC to download a file from the workspace of a job, while the workspace exists. The size and mode of the file are sent first, and its checksum last, so that the client can verify the file.
+func (server *JobServer) FileDownload(req *pb.FileDownloadRequest, stream pb.JobService_FileDownloadServer) error {
+	jobId, path := req.GetJobId(), req.GetPath()
+
+	logger := log.WithFields(log.Fields{"func": "FileDownload", "jobId": jobId, "path": path})
+
+	// get userId attached to context
+	userId, err := GetUserIdFromContext(stream.Context())
+	if err != nil {
+		logger.WithError(err).Error("unable to get userId from context")
+		return status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user id in context
+	}
+
+	logger = logger.WithField("userId", userId)
+
+	logger.Debug("received a file download request")
+
+	if server.MaxFileSizeMiB == 0 {
+		return status.Error(codes.FailedPrecondition, "file transfers are not enabled")
+	}
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return status.Error(codes.Internal, "job is invalid")
+	}
+
+	file, err := job.OpenFile(path)
+	if err != nil {
+		return fileError(logger, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fileError(logger, err)
+	}
+	if uint64(info.Size()) > server.MaxFileSizeMiB<<20 {
+		logger.WithField("size", info.Size()).Debug("file is too large")
+		return status.Errorf(codes.InvalidArgument, "files can be at most %d MiB", server.MaxFileSizeMiB)
+	}
+
+	sum, err := sendFile(logger, stream, file, uint64(info.Size()), info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(log.Fields{"size": info.Size(), "sha256": sum}).Debug("downloaded a file")
+
+	return nil
+}
+
+// ArtifactDownload is a server-side streaming RPC to download an artifact that was collected from a job, in the same format as FileDownload. It still works after the job's workspace was deleted.
+func (server *JobServer) ArtifactDownload(req *pb.ArtifactDownloadRequest, stream pb.JobService_ArtifactDownloadServer) error {
+	jobId, name := req.GetJobId(), req.GetName()
+
+	logger := log.WithFields(log.Fields{"func": "ArtifactDownload", "jobId": jobId, "name": name})
+
+	// get userId attached to context
+	userId, err := GetUserIdFromContext(stream.Context())
+	if err != nil {
+		logger.WithError(err).Error("unable to get userId from context")
+		return status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user id in context
+	}
+
+	logger = logger.WithField("userId", userId)
+
+	logger.Debug("received an artifact download request")
+
+	if server.Artifacts == nil {
+		return status.Error(codes.FailedPrecondition, "artifacts are not enabled")
+	}
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return status.Error(codes.Internal, "job is invalid")
+	}
+
+	artifact, err := job.GetArtifact(name)
+	if err != nil {
+		return status.Error(codes.NotFound, "artifact was not found")
+	}
+
+	file, err := server.Artifacts.Open(artifact.Digest)
+	if err != nil {
+		logger.WithError(err).WithField("sha256", artifact.Digest).Error("unable to open artifact")
+		return status.Error(codes.Internal, "unable to open artifact")
+	}
+	defer file.Close()
+
+	// the stored file is checked against its digest as it is sent, so that a corrupted store is not passed on silently
+	sum, err := sendFile(logger, stream, file, uint64(artifact.Size), artifact.Mode)
+	if err != nil {
+		return err
+	}
+	if sum != artifact.Digest {
+		logger.WithFields(log.Fields{"expected": artifact.Digest, "actual": sum}).Error("artifact is corrupted")
+		return status.Error(codes.DataLoss, "the artifact is corrupted")
+	}
+
+	logger.WithField("size", artifact.Size).Debug("downloaded an artifact")
+
+	return nil
+}
+
+// fileSender is the sending side of a FileDownload or ArtifactDownload stream.
+type fileSender interface {
+	Send(*pb.FileDownloadResponse) error
+}
+
+// sendFile sends `size` bytes of `file` with permission bits `mode` over `stream` in chunks, followed by their checksum, which it returns. It returns a gRPC status error.
+func sendFile(logger *log.Entry, stream fileSender, file io.Reader, size uint64, mode os.FileMode) (string, error) {
+	// only the size that was announced is sent, even if a running job appends to the file
+	hash := sha256.New()
+	reader := io.TeeReader(io.LimitReader(file, int64(size)), hash)
+	res := &pb.FileDownloadResponse{Size: size, Mode: uint32(mode)}
+	buffer := make([]byte, fileChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", fileError(logger, err)
+		}
+
+		res.Data = buffer[:n]
+		if n < len(buffer) {
+			res.Sha256 = hex.EncodeToString(hash.Sum(nil))
+		}
+
+		if err := stream.Send(res); err != nil {
+			if IsAuthError(err) {
+				logger.WithError(err).Debug("client is no longer authorized to download the file")
+				return "", err
+			}
+
+			logger.WithError(err).Error("unable to send file chunk")
+			return "", status.Error(codes.Internal, "unable to send file chunk")
+		}
+
+		if res.Sha256 != "" {
+			return res.Sha256, nil
+		}
+		res = &pb.FileDownloadResponse{}
+	}
+}
+
+// artifacts converts the artifacts of a job to their API representation.
+func artifacts(artifacts []worker.Artifact) []*pb.Artifact {
+	infos := []*pb.Artifact{}
+	for _, artifact := range artifacts {
+		infos = append(infos, &pb.Artifact{Name: artifact.Name, Size: uint64(artifact.Size), Sha256: artifact.Digest, Mode: uint32(artifact.Mode)})
+	}
+
+	return infos
+}
+
+// fileError converts an error accessing a file in a job's workspace to a gRPC status error.
+func fileError(logger *log.Entry, err error) error {
+	switch {
+	case errors.Is(err, worker.ErrInvalidPath) || errors.Is(err, worker.ErrNotRegularFile):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, worker.ErrJobNotHeld) || errors.Is(err, worker.ErrWorkspaceTmpfs):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, worker.ErrUploadLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		return status.Error(codes.NotFound, "file was not found")
+	}
+
+	logger.WithError(err).Error("unable to access file")
+	return status.Error(codes.Internal, "unable to access file")
+}
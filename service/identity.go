@@ -0,0 +1,80 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// IdentitySource determines which part of a client certificate is used to identify the client.
+type IdentitySource string
+
+const (
+	IdentitySubject     IdentitySource = "subject"     // IdentitySubject identifies clients by the certificate's Issuer and Subject, formatted as RDN sequence strings.
+	IdentityURI         IdentitySource = "uri"         // IdentityURI identifies clients by a URI Subject Alternative Name, such as a SPIFFE ID.
+	IdentityDNS         IdentitySource = "dns"         // IdentityDNS identifies clients by a DNS Subject Alternative Name.
+	IdentityEmail       IdentitySource = "email"       // IdentityEmail identifies clients by an email Subject Alternative Name.
+	IdentityFingerprint IdentitySource = "fingerprint" // IdentityFingerprint identifies clients by the SHA-256 fingerprint of the whole certificate.
+)
+
+var (
+	// identityExtractors returns, for each identity source, all of the client ids that a certificate can be identified by.
+	identityExtractors = map[IdentitySource]func(cert *x509.Certificate) []ClientId{
+		IdentitySubject: func(cert *x509.Certificate) []ClientId {
+			return []ClientId{{Issuer: cert.Issuer.ToRDNSequence().String(), Subject: cert.Subject.ToRDNSequence().String()}}
+		},
+		IdentityURI: func(cert *x509.Certificate) []ClientId {
+			clientIds := []ClientId{}
+			for _, uri := range cert.URIs {
+				clientIds = append(clientIds, ClientId{Name: uri.String()})
+			}
+			return clientIds
+		},
+		IdentityDNS: func(cert *x509.Certificate) []ClientId {
+			clientIds := []ClientId{}
+			for _, dnsName := range cert.DNSNames {
+				clientIds = append(clientIds, ClientId{Name: strings.ToLower(dnsName)})
+			}
+			return clientIds
+		},
+		IdentityEmail: func(cert *x509.Certificate) []ClientId {
+			clientIds := []ClientId{}
+			for _, email := range cert.EmailAddresses {
+				clientIds = append(clientIds, ClientId{Name: email})
+			}
+			return clientIds
+		},
+		IdentityFingerprint: func(cert *x509.Certificate) []ClientId {
+			return []ClientId{{Name: Fingerprint(cert)}}
+		},
+	}
+)
+
+// ClientId uniquely identifies a client. With the `subject` identity source it is composed of the client certificate's issuer and subject, and with all other sources only Name is set.
+type ClientId struct {
+	Issuer  string
+	Subject string
+	Name    string // Name is the URI, DNS name, email address or fingerprint of the client.
+}
+
+// ClientIds returns all of the client ids that `cert` can be identified by using `source`.
+func ClientIds(source IdentitySource, cert *x509.Certificate) []ClientId {
+	extract, ok := identityExtractors[source]
+	if !ok {
+		return nil
+	}
+
+	return extract(cert)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate as lowercase hex.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint lowercases a fingerprint and strips colons, so that both `AB:CD:...` (as printed by openssl) and `abcd...` are accepted in the config.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
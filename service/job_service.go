@@ -3,6 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"io"
+	"math"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -16,20 +19,264 @@ import (
 // JobServer is a server wrapper around a job store.
 type JobServer struct {
 	pb.UnimplementedJobServiceServer
-	Store *worker.JobStore
+	Store   *worker.JobStore
+	Tokens  *TokenAuthority     // Tokens issues bearer tokens. If it is nil, token authentication is disabled and TokenIssue fails.
+	Policy  PolicyProvider      // Policy provides the command policy that new jobs are admitted by. If it is nil, any command can be run.
+	Rootfs  *worker.RootfsStore // Rootfs provides the root filesystems that jobs can run in. If it is nil, jobs that request one are rejected.
+	Secrets *SecretStore        // Secrets provides the secrets that jobs can reference. If it is nil, jobs that reference one are rejected.
+
+	Artifacts *worker.ArtifactStore // Artifacts keeps the artifacts collected from jobs. If it is nil, jobs that declare artifacts are rejected.
+	Scheduler *worker.Scheduler     // Scheduler starts jobs in the order they were submitted, within its concurrency limit.
+
+	MaxWorkspaceTmpfsMiB uint64             // MaxWorkspaceTmpfsMiB is the largest tmpfs workspace that jobs can request. If it is zero, jobs that request one are rejected.
+	MaxFileSizeMiB       uint64             // MaxFileSizeMiB is the largest file that can be uploaded to or downloaded from a job's workspace. If it is zero, file transfers are rejected.
+	UploadLimit          worker.UploadLimit // UploadLimit caps the files that can be uploaded to each held job.
+	HoldTimeout          time.Duration      // HoldTimeout is how long held jobs wait to be released before they are stopped. If it is zero, they wait until they are released or stopped.
+	EnvAllowlist         []string           // EnvAllowlist are the variables of the server's environment that jobs with base ENV_ALLOWLIST inherit.
+	EnvShown             []string           // EnvShown are the variables whose values job info shows. The values of all other variables that jobs set are redacted.
+}
+
+// PolicyProvider provides the current authorization policy. It is implemented by Authorizer, so that policy reloads also apply to the command policy.
+type PolicyProvider interface {
+	GetPolicy() *Policy
 }
 
 // NewJobServer returns a new JobServer.
 func NewJobServer(store *worker.JobStore) *JobServer {
-	return &JobServer{Store: store}
+	return &JobServer{Store: store, Scheduler: worker.NewScheduler(0), EnvAllowlist: DefaultEnvAllowlist, EnvShown: DefaultEnvShown}
 }
 
 // JobStart is a unary RPC to start a new job.
 func (server *JobServer) JobStart(ctx context.Context, req *pb.JobStartRequest) (*pb.JobStartResponse, error) {
-	// get command name and args from request
-	command, args := req.GetCommand(), req.GetArgs()
+	// get command name, args, group and root filesystem from request
+	command, args, group, rootfsName := req.GetCommand(), req.GetArgs(), req.GetGroup(), req.GetRootfs()
+	env, workingDir := req.GetEnv(), req.GetWorkingDir()
+
+	logger := log.WithFields(log.Fields{"func": "JobStart", "command": command, "args": args, "group": group, "rootfs": rootfsName})
+
+	// get user attached to context
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to get user from context")
+		return nil, status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user in context
+	}
+
+	logger = logger.WithField("userId", user.Id)
+
+	logger.Debug("received a job start request")
+
+	// users can only share jobs with groups they are a member of
+	if group != "" && !user.IsMember(group) {
+		logger.Debug("user is not a member of the group")
+		return nil, status.Error(codes.PermissionDenied, "user is not a member of the group")
+	}
+
+	if _, ok := pb.WorkspaceRetention_name[int32(req.GetWorkspaceRetention())]; !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown workspace retention")
+	}
+
+	// tmpfs workspaces use the server's memory, so their size is capped
+	tmpfsMiB := req.GetWorkspaceTmpfsMib()
+	if tmpfsMiB > 0 && server.MaxWorkspaceTmpfsMiB == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "tmpfs workspaces are not enabled")
+	}
+	if tmpfsMiB > server.MaxWorkspaceTmpfsMiB {
+		logger.WithField("workspaceTmpfsMiB", tmpfsMiB).Debug("tmpfs workspace is too large")
+		return nil, status.Errorf(codes.InvalidArgument, "tmpfs workspaces can be at most %d MiB", server.MaxWorkspaceTmpfsMiB)
+	}
+
+	if err := validateEnv(env); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	environ, err := baseEnv(req.GetEnvBase(), server.EnvAllowlist)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	opts := []worker.JobOption{worker.WithGroup(group), worker.WithWorkspaceRetention(req.GetWorkspaceRetention()), worker.WithEnv(env), worker.WithWorkingDir(workingDir)}
+	if environ != nil {
+		opts = append(opts, worker.WithBaseEnv(req.GetEnvBase(), environ))
+	}
+	if tmpfsMiB > 0 {
+		opts = append(opts, worker.WithWorkspaceTmpfs(tmpfsMiB<<20))
+	}
+	if req.GetTty() {
+		size, err := terminalSize(req.GetTtySize())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts = append(opts, worker.WithTerminal(size))
+	}
+	if req.GetStdin() {
+		if req.GetTty() {
+			return nil, status.Error(codes.InvalidArgument, "jobs with a terminal read their input from it, and can not have stdin")
+		}
+		opts = append(opts, worker.WithStdin())
+	}
+	if patterns := req.GetArtifacts(); len(patterns) > 0 {
+		if server.Artifacts == nil {
+			return nil, status.Error(codes.FailedPrecondition, "artifacts are not enabled")
+		}
+		if err := worker.ValidateArtifactPatterns(patterns); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid artifact pattern: %s", err)
+		}
+		// the contents of tmpfs workspaces are only visible to the job
+		if tmpfsMiB > 0 {
+			return nil, status.Error(codes.InvalidArgument, "artifacts can not be collected from tmpfs workspaces")
+		}
+		opts = append(opts, worker.WithArtifacts(patterns, server.Artifacts))
+	}
+	if user.RunAs != nil {
+		opts = append(opts, worker.WithCredential(user.RunAs.Uid, user.RunAs.Gid, user.RunAs.Groups))
+	}
+	if user.Security != nil {
+		opts = append(opts, worker.WithSecurityProfile(*user.Security))
+	}
+
+	// resolve the root filesystem first, since commands are resolved in it
+	rootfsPath := ""
+	if rootfsName != "" {
+		if server.Rootfs == nil {
+			return nil, status.Error(codes.FailedPrecondition, "root filesystems are not enabled")
+		}
+
+		rootfs, err := server.Rootfs.Resolve(rootfsName)
+		if errors.Is(err, worker.ErrRootfsDoesNotExist) || errors.Is(err, worker.ErrRootfsInvalidName) {
+			logger.WithError(err).Debug("invalid root filesystem")
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err != nil {
+			logger.WithError(err).Error("unable to prepare root filesystem")
+			return nil, status.Error(codes.Internal, "unable to prepare root filesystem")
+		}
+
+		opts = append(opts, worker.WithRootfs(rootfs))
+		rootfsPath = rootfs.Path
+	}
+
+	// secrets are only read once the user is known to be allowed to use them
+	secrets, err := server.resolveSecrets(user, req.GetSecrets(), env)
+	if err != nil {
+		logger.WithError(err).Debug("unable to resolve secrets")
+		return nil, err
+	}
+	if len(secrets) > 0 {
+		opts = append(opts, worker.WithSecrets(secrets))
+	}
+
+	// absolute working directories are looked up in the root filesystem
+	if err := validateWorkingDir(workingDir, rootfsPath); err != nil {
+		logger.WithError(err).Debug("invalid working directory")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// check the command against the command policy. Allowed jobs run the resolved command, so that the command that was checked is the one that runs
+	if server.Policy != nil {
+		admission, err := server.Policy.GetPolicy().Admit(user, command, args, env, rootfsPath)
+		if err != nil {
+			logger.WithError(err).Debug("unable to evaluate command policy")
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		logger = logger.WithFields(log.Fields{"action": admission.Action, "rule": admission.Rule, "path": admission.Path})
+		switch admission.Action {
+		case ActionDeny:
+			logger.Debug("command policy denied the job")
+			return nil, status.Error(codes.PermissionDenied, "command is not allowed by the command policy")
+		case ActionRequireApproval:
+			opts = append(opts, worker.WithPendingApproval())
+		}
+		command = admission.Path
+	}
+
+	// held jobs wait for files to be uploaded to them
+	if req.GetHold() {
+		opts = append(opts, worker.WithHold(), worker.WithHoldTimeout(server.HoldTimeout), worker.WithUploadLimit(server.UploadLimit))
+	}
+
+	job, err := server.Store.AddJob(user.Id, command, args, opts...)
+	if err != nil {
+		logger.WithError(err).Error("failed to add job")
+		return nil, status.Error(codes.Internal, "failed to add job")
+	}
+
+	// held jobs are started by JobRelease, and jobs that require approval by JobApprove
+	switch jobStatus := job.GetJobStatus(); jobStatus {
+	case pb.JobStatus_HELD:
+		logger.WithField("jobId", job.Key.JobId).Debug("job is held")
+		return &pb.JobStartResponse{JobId: job.Key.JobId, JobStatus: jobStatus}, nil
+	case pb.JobStatus_PENDING_APPROVAL:
+		logger.WithField("jobId", job.Key.JobId).Info("job is pending approval")
+		return &pb.JobStartResponse{JobId: job.Key.JobId, JobStatus: jobStatus}, nil
+	}
+
+	jobStatus, err := server.submit(job)
+	if err != nil {
+		logger.WithError(err).Error("failed to start job")
+		return nil, status.Error(codes.Internal, "failed to start job")
+	}
 
-	logger := log.WithFields(log.Fields{"func": "JobStart", "command": command, "args": args})
+	logger.WithField("jobStatus", jobStatus).Debug("successfully submitted a job")
+
+	res := &pb.JobStartResponse{JobId: job.Key.JobId, JobStatus: jobStatus}
+	return res, nil
+}
+
+// JobApprove is a unary RPC to approve and start a job that is pending approval. Users can not approve their own jobs.
+func (server *JobServer) JobApprove(ctx context.Context, req *pb.JobApproveRequest) (*pb.JobApproveResponse, error) {
+	jobId := req.GetJobId()
+
+	logger := log.WithFields(log.Fields{"func": "JobApprove", "jobId": jobId})
+
+	// get user attached to context
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to get user from context")
+		return nil, status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user in context
+	}
+
+	logger = logger.WithField("userId", user.Id)
+
+	logger.Debug("received a job approve request")
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return nil, status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return nil, status.Error(codes.Internal, "job is invalid")
+	}
+
+	if job.Key.UserId == user.Id {
+		logger.Debug("user tried to approve their own job")
+		return nil, status.Error(codes.PermissionDenied, "users can not approve their own jobs")
+	}
+
+	err = job.Approve()
+	if err != nil {
+		logger.WithError(err).Debug("job can not be approved")
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	jobStatus, err := server.submit(job)
+	if err != nil {
+		logger.WithError(err).Error("failed to start job")
+		return nil, status.Error(codes.Internal, "failed to start job")
+	}
+
+	logger.WithFields(log.Fields{"owner": job.Key.UserId, "jobStatus": jobStatus}).Info("approved and submitted a job")
+
+	return &pb.JobApproveResponse{}, nil
+}
+
+// JobRelease is a unary RPC to start a held job, or to hand it over for approval if the command policy requires it.
+func (server *JobServer) JobRelease(ctx context.Context, req *pb.JobReleaseRequest) (*pb.JobReleaseResponse, error) {
+	jobId := req.GetJobId()
+
+	logger := log.WithFields(log.Fields{"func": "JobRelease", "jobId": jobId})
 
 	// get userId attached to context
 	userId, err := GetUserIdFromContext(ctx)
@@ -40,24 +287,54 @@ func (server *JobServer) JobStart(ctx context.Context, req *pb.JobStartRequest)
 
 	logger = logger.WithField("userId", userId)
 
-	logger.Debug("received a job start request")
+	logger.Debug("received a job release request")
 
-	job, err := server.Store.AddJob(userId, command, args)
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
 	if err != nil {
-		logger.WithError(err).Error("failed to add job")
-		return nil, status.Error(codes.Internal, "failed to add job")
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return nil, status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return nil, status.Error(codes.Internal, "job is invalid")
+	}
+
+	err = job.Release()
+	if err != nil {
+		logger.WithError(err).Debug("job can not be released")
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	// jobs that require approval are started by JobApprove
+	if job.GetJobStatus() == pb.JobStatus_PENDING_APPROVAL {
+		logger.Info("job is pending approval")
+		return &pb.JobReleaseResponse{JobStatus: pb.JobStatus_PENDING_APPROVAL}, nil
 	}
 
-	err = job.Start()
+	jobStatus, err := server.submit(job)
 	if err != nil {
 		logger.WithError(err).Error("failed to start job")
 		return nil, status.Error(codes.Internal, "failed to start job")
 	}
 
-	logger.Debug("successfully started a job")
+	logger.WithField("jobStatus", jobStatus).Debug("released and submitted a job")
 
-	res := &pb.JobStartResponse{JobId: job.Key.JobId}
-	return res, nil
+	return &pb.JobReleaseResponse{JobStatus: jobStatus}, nil
+}
+
+// submit hands a queued job to the scheduler, and returns QUEUED if it waits for a free slot, or RUNNING if it was started.
+func (server *JobServer) submit(job *worker.Job) (pb.JobStatus, error) {
+	started, err := server.Scheduler.Submit(job)
+	if err != nil {
+		return pb.JobStatus_QUEUED, err
+	}
+
+	if !started {
+		return pb.JobStatus_QUEUED, nil
+	}
+
+	return pb.JobStatus_RUNNING, nil
 }
 
 // JobStop is a unary RPC to stop an existing job.
@@ -78,7 +355,7 @@ func (server *JobServer) JobStop(ctx context.Context, req *pb.JobStopRequest) (*
 
 	logger.Debug("received a job stop request")
 
-	job, err := server.Store.LoadJob(worker.JobKey{UserId: userId, JobId: jobId})
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
 	if err != nil {
 		if errors.Is(err, worker.ErrJobDoesNotExist) {
 			logger.Debug("job was not found")
@@ -113,7 +390,7 @@ func (server *JobServer) JobStatus(ctx context.Context, req *pb.JobStatusRequest
 
 	logger.Debug("received a job status query request")
 
-	job, err := server.Store.LoadJob(worker.JobKey{UserId: userId, JobId: jobId})
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
 	if err != nil {
 		if errors.Is(err, worker.ErrJobDoesNotExist) {
 			logger.Debug("job was not found")
@@ -124,22 +401,112 @@ func (server *JobServer) JobStatus(ctx context.Context, req *pb.JobStatusRequest
 		return nil, status.Error(codes.Internal, "job is invalid")
 	}
 
-	jobStatus := &pb.JobStatusResponse{
-		JobInfo: &pb.JobInfo{
-			Id:         job.Key.JobId,
-			UserId:     job.Key.UserId,
-			Command:    job.Command,
-			Args:       job.Args,
-			JobStatus:  job.GetJobStatus(),
-			ExitCode:   job.GetExitCode(),
-			CreatedAt:  timestamppb.New(job.CreatedAt),
-			FinishedAt: timestamppb.New(job.GetFinishedAt()),
-		},
-	}
+	jobStatus := &pb.JobStatusResponse{JobInfo: server.jobInfo(job)}
 
 	return jobStatus, nil
 }
 
+// JobList is a unary RPC to list all jobs that the client is allowed to query the status of.
+func (server *JobServer) JobList(ctx context.Context, req *pb.JobListRequest) (*pb.JobListResponse, error) {
+	logger := log.WithFields(log.Fields{"func": "JobList"})
+
+	// get user attached to context
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to get user from context")
+		return nil, status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user in context
+	}
+
+	logger = logger.WithField("userId", user.Id)
+
+	logger.Debug("received a job list request")
+
+	// only list jobs within the scope that the interceptor granted
+	scope := GetScopeFromContext(ctx)
+	jobInfos := []*pb.JobInfo{}
+	for _, job := range server.Store.ListJobs() {
+		if user.CanAccess(scope, job) {
+			jobInfos = append(jobInfos, server.jobInfo(job))
+		}
+	}
+
+	return &pb.JobListResponse{JobInfos: jobInfos}, nil
+}
+
+// TokenIssue is a unary RPC to issue a bearer token to a client that authenticated with a certificate.
+func (server *JobServer) TokenIssue(ctx context.Context, req *pb.TokenIssueRequest) (*pb.TokenIssueResponse, error) {
+	logger := log.WithFields(log.Fields{"func": "TokenIssue"})
+
+	if server.Tokens == nil {
+		return nil, status.Error(codes.FailedPrecondition, "token authentication is not enabled")
+	}
+
+	// get user attached to context
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to get user from context")
+		return nil, status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user in context
+	}
+
+	logger = logger.WithField("userId", user.Id)
+
+	logger.Debug("received a token issue request")
+
+	// a missing ttl means the maximum lifetime
+	var ttl time.Duration
+	if req.GetTtl() != nil {
+		if err := req.GetTtl().CheckValid(); err != nil || req.GetTtl().AsDuration() < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid ttl")
+		}
+		ttl = req.GetTtl().AsDuration()
+	}
+
+	// tokens are bound to the client's certificate, so that revoking the certificate also revokes the token. The interceptors only let clients with a certificate issue tokens
+	cert, err := certificateFromContext(ctx)
+	if err != nil {
+		logger.WithError(err).Debug("client has no certificate")
+		return nil, status.Error(codes.PermissionDenied, "method requires a client certificate")
+	}
+
+	token, expiresAt, err := server.Tokens.Issue(user.Id, cert, ttl)
+	if err != nil {
+		logger.WithError(err).Error("failed to issue token")
+		return nil, status.Error(codes.Internal, "failed to issue token")
+	}
+
+	logger.WithField("expiresAt", expiresAt).Info("issued a token")
+
+	return &pb.TokenIssueResponse{Token: token, ExpiresAt: timestamppb.New(expiresAt)}, nil
+}
+
+// jobInfo returns the job's information in its API representation.
+func (server *JobServer) jobInfo(job *worker.Job) *pb.JobInfo {
+	return &pb.JobInfo{
+		Id:          job.Key.JobId,
+		UserId:      job.Key.UserId,
+		Command:     job.Command,
+		Args:        job.Args,
+		JobStatus:   job.GetJobStatus(),
+		ExitCode:    job.GetExitCode(),
+		CreatedAt:   timestamppb.New(job.CreatedAt),
+		FinishedAt:  timestamppb.New(job.GetFinishedAt()),
+		Group:       job.Group,
+		Rootfs:      job.Rootfs,
+		ImageDigest: job.ImageDigest,
+
+		WorkspaceRetention: job.WorkspaceRetention,
+		Env:                redactEnv(job.Env, server.EnvShown),
+		EnvBase:            job.EnvBase,
+		WorkingDir:         job.WorkingDir,
+		Secrets:            secretRefs(job.SecretRefs()),
+		Tty:                job.TTY,
+		Stdin:              job.Stdin,
+		ArtifactPatterns:   job.ArtifactPatterns,
+		Artifacts:          artifacts(job.GetArtifacts()),
+		QueuePosition:      uint32(server.Scheduler.Position(job)),
+	}
+}
+
 // JobLog is a server-side streaming RPC to follow a job's log until the job is done.
 func (server *JobServer) JobLogsStream(req *pb.JobLogsRequest, stream pb.JobService_JobLogsStreamServer) error {
 	// get command name and args from request
@@ -158,7 +525,7 @@ func (server *JobServer) JobLogsStream(req *pb.JobLogsRequest, stream pb.JobServ
 
 	logger.Debug("received a job log follow request")
 
-	job, err := server.Store.LoadJob(worker.JobKey{UserId: userId, JobId: jobId})
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
 	if err != nil {
 		if errors.Is(err, worker.ErrJobDoesNotExist) {
 			logger.Debug("job was not found")
@@ -179,6 +546,11 @@ func (server *JobServer) JobLogsStream(req *pb.JobLogsRequest, stream pb.JobServ
 		res := &pb.JobLogsResponse{Log: logChunk}
 		err := stream.Send(res)
 		if err != nil {
+			if IsAuthError(err) {
+				logger.WithError(err).Debug("client is no longer authorized to follow logs")
+				return err
+			}
+
 			logger.WithError(err).Error("unable to send log chunk")
 			return status.Errorf(codes.Internal, "unable to send log chunk")
 		}
@@ -186,3 +558,234 @@ func (server *JobServer) JobLogsStream(req *pb.JobLogsRequest, stream pb.JobServ
 
 	return nil
 }
+
+// JobAttach is a bidirectional streaming RPC to attach to the terminal of a job. Input and size changes received from the client are applied to the job's terminal, while the job's output is streamed from the start of its log until the job is done.
+func (server *JobServer) JobAttach(stream pb.JobService_JobAttachServer) error {
+	logger := log.WithFields(log.Fields{"func": "JobAttach"})
+
+	// get userId attached to context
+	userId, err := GetUserIdFromContext(stream.Context())
+	if err != nil {
+		logger.WithError(err).Error("unable to get userId from context")
+		return status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user id in context
+	}
+
+	// the first request decides the job that the stream is attached to
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no job id was sent")
+	}
+	if err != nil {
+		return err
+	}
+	jobId := req.GetJobId()
+
+	logger = logger.WithFields(log.Fields{"userId": userId, "jobId": jobId})
+
+	logger.Debug("received a job attach request")
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return status.Error(codes.Internal, "job is invalid")
+	}
+
+	if !job.TTY {
+		return status.Error(codes.FailedPrecondition, worker.ErrNoTerminal.Error())
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	// apply requests until the client stops sending. Output is still streamed after the client closed its side of the stream
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			if req.GetJobId() != jobId {
+				recvErr <- status.Error(codes.InvalidArgument, "all requests must be for the same job")
+				cancel()
+				return
+			}
+			if err := attachRequest(ctx, job, req); err != nil {
+				recvErr <- err
+				cancel()
+				return
+			}
+
+			var err error
+			req, err = stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				recvErr <- err
+				cancel()
+				return
+			}
+		}
+	}()
+
+	logChannel, err := job.Log(ctx)
+	if err != nil {
+		logger.WithError(err).Error("unable to follow logs")
+		return status.Error(codes.Internal, "server unable to follow job logs")
+	}
+
+	for logChunk := range logChannel {
+		err := stream.Send(&pb.JobAttachResponse{Output: logChunk})
+		if err != nil {
+			if IsAuthError(err) {
+				logger.WithError(err).Debug("client is no longer authorized to attach")
+				return err
+			}
+
+			logger.WithError(err).Error("unable to send output")
+			return status.Errorf(codes.Internal, "unable to send output")
+		}
+	}
+
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+// JobStdin is a client-side streaming RPC to write to the stdin of a job. The job's stdin is only closed if the client asks for it, so that another stream can continue writing where an interrupted one stopped.
+func (server *JobServer) JobStdin(stream pb.JobService_JobStdinServer) error {
+	logger := log.WithFields(log.Fields{"func": "JobStdin"})
+
+	// get userId attached to context
+	userId, err := GetUserIdFromContext(stream.Context())
+	if err != nil {
+		logger.WithError(err).Error("unable to get userId from context")
+		return status.Error(codes.Internal, "unable to get userId") // internal server error since the interceptor should have set the user id in context
+	}
+
+	// the first request decides the job that the stream writes to
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no job id was sent")
+	}
+	if err != nil {
+		return err
+	}
+	jobId := req.GetJobId()
+
+	logger = logger.WithFields(log.Fields{"userId": userId, "jobId": jobId})
+
+	logger.Debug("received a job stdin request")
+
+	job, err := server.Store.LoadJobById(jobId) // access to the job was checked by the authorization interceptor
+	if err != nil {
+		if errors.Is(err, worker.ErrJobDoesNotExist) {
+			logger.Debug("job was not found")
+			return status.Error(codes.NotFound, "job was not found")
+		}
+
+		logger.WithError(err).Error("job is invalid")
+		return status.Error(codes.Internal, "job is invalid")
+	}
+
+	release, err := job.AcquireStdin()
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer release()
+
+	written := uint64(0)
+	for {
+		if req.GetJobId() != jobId {
+			return status.Error(codes.InvalidArgument, "all requests must be for the same job")
+		}
+
+		if data := req.GetData(); len(data) > 0 {
+			if err := job.WriteStdin(stream.Context(), data); err != nil {
+				return stdinError(logger, err)
+			}
+			written += uint64(len(data))
+		}
+
+		if req.GetClose() {
+			if err := job.CloseStdin(); err != nil {
+				return stdinError(logger, err)
+			}
+			logger.WithField("bytesWritten", written).Debug("closed stdin")
+		}
+
+		req, err = stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.JobStdinResponse{BytesWritten: written})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// stdinError converts an error writing to a job's stdin to a gRPC status error.
+func stdinError(logger *log.Entry, err error) error {
+	if errors.Is(err, worker.ErrStdinClosed) || errors.Is(err, worker.ErrNoStdin) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err() // the client went away while the job was not reading
+	}
+
+	logger.WithError(err).Error("unable to write to stdin")
+	return status.Error(codes.Internal, "unable to write to stdin")
+}
+
+// attachRequest writes the input of `req` to the terminal of `job`, and resizes it if requested. Requests that write to or resize the terminal of a job that is not running fail with FailedPrecondition, rather than losing the input. Writes to a terminal that the job does not read are interrupted once `ctx` is done. It returns a gRPC status error.
+func attachRequest(ctx context.Context, job *worker.Job, req *pb.JobAttachRequest) error {
+	logger := log.WithFields(log.Fields{"func": "attachRequest", "jobKey": job.Key})
+
+	if size := req.GetResize(); size != nil {
+		terminalSize, err := terminalSize(size)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		err = job.ResizeTerminal(terminalSize)
+		if errors.Is(err, worker.ErrTerminalClosed) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err != nil {
+			logger.WithError(err).Error("unable to resize terminal")
+			return status.Error(codes.Internal, "unable to resize terminal")
+		}
+	}
+
+	if stdin := req.GetStdin(); len(stdin) > 0 {
+		err := job.WriteTerminal(ctx, stdin)
+		if errors.Is(err, worker.ErrTerminalClosed) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err).Err() // the client went away while the job was not reading
+		}
+		if err != nil {
+			logger.WithError(err).Error("unable to write to terminal")
+			return status.Error(codes.Internal, "unable to write to terminal")
+		}
+	}
+
+	return nil
+}
+
+// terminalSize converts the API representation of a terminal size. The default size is used if `size` is not set.
+func terminalSize(size *pb.TerminalSize) (worker.TerminalSize, error) {
+	if size == nil {
+		return worker.DefaultTerminalSize, nil
+	}
+	if size.GetRows() > math.MaxUint16 || size.GetCols() > math.MaxUint16 {
+		return worker.TerminalSize{}, errors.New("terminal size is too large")
+	}
+
+	return worker.TerminalSize{Rows: uint16(size.GetRows()), Cols: uint16(size.GetCols())}, nil
+}
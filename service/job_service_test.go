@@ -3,10 +3,14 @@ package service_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mlaradji/int-backend-mohamed/pb"
 	"github.com/mlaradji/int-backend-mohamed/service"
@@ -50,12 +54,21 @@ func init() {
 
 	tlsCredentials := service.MakeServerTLSCredentials(cert, certPool)
 
+	policy, err := service.LoadPolicy("../config/auth.yaml")
+	if err != nil {
+		logger.WithError(err).Fatal("cannot load authorization config")
+	}
+
 	// initialize job service
 	jobStore := worker.NewJobStore()
 	jobServer := service.NewJobServer(jobStore)
+	jobServer.MaxWorkspaceTmpfsMiB = 1
+	jobServer.MaxFileSizeMiB = 1
+	jobServer.Artifacts = worker.NewArtifactStore("tmp/artifacts", 1<<20)
+	authorizer := service.NewAuthorizer(policy, jobStore)
 
 	// initialize gRPC server with authentication and authorization interceptors
-	grpcServer := grpc.NewServer(grpc.Creds(tlsCredentials), grpc.UnaryInterceptor(service.UnaryAuth), grpc.StreamInterceptor(service.StreamAuth))
+	grpcServer := grpc.NewServer(grpc.Creds(tlsCredentials), grpc.UnaryInterceptor(authorizer.UnaryAuth), grpc.StreamInterceptor(authorizer.StreamAuth))
 	pb.RegisterJobServiceServer(grpcServer, jobServer)
 
 	// start listening
@@ -211,6 +224,12 @@ func TestJobFlow(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, codes.NotFound, errStatus.Code())
 
+	listRes, err := client2.JobList(ctx, &pb.JobListRequest{})
+	require.NoError(t, err)
+	for _, jobInfo := range listRes.GetJobInfos() {
+		require.NotEqual(t, jobId, jobInfo.Id)
+	}
+
 	_, err = client2.JobStop(ctx, &pb.JobStopRequest{JobId: jobId})
 	errStatus, ok = status.FromError(err)
 	require.True(t, ok)
@@ -223,3 +242,396 @@ func TestJobFlow(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, codes.NotFound, errStatus.Code())
 }
+
+// TestJobSharing starts a job that is shared with a group, and checks that another member of the group can query and stop it.
+func TestJobSharing(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	// users can not share jobs with groups they are not a member of
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "sleep", Args: []string{"10"}, Group: "strangers"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sleep", Args: []string{"10"}, Group: "team"})
+	require.NoError(t, err)
+	jobId := startRes.GetJobId()
+
+	// query and stop the job from another member of the group
+	conn2, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client2/cert.pem", "../certs/client2/key.pem")
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	client2 := pb.NewJobServiceClient(conn2)
+
+	statusRes, err := client2.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+	require.NoError(t, err)
+	require.Equal(t, "client1", statusRes.GetJobInfo().UserId)
+	require.Equal(t, "team", statusRes.GetJobInfo().Group)
+
+	_, err = client2.JobStop(ctx, &pb.JobStopRequest{JobId: jobId})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+		return err == nil && statusRes.GetJobInfo().JobStatus == pb.JobStatus_STOPPED
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestJobWorkspaceRequest checks that the workspace retention of a job is reported in its status, and that invalid workspace requests are rejected.
+func TestJobWorkspaceRequest(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "echo", WorkspaceRetention: pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE})
+	require.NoError(t, err)
+	statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: startRes.GetJobId()})
+	require.NoError(t, err)
+	require.Equal(t, pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE, statusRes.GetJobInfo().WorkspaceRetention)
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "echo", WorkspaceRetention: pb.WorkspaceRetention(42)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "echo", WorkspaceTmpfsMib: 2})
+	require.Equal(t, codes.InvalidArgument, status.Code(err), "tmpfs workspaces are capped by the server")
+}
+
+// TestJobEnv starts a job with environment variables and a working directory, and checks that only the values of shown variables are in its status and that invalid requests are rejected.
+func TestJobEnv(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	env := map[string]string{"GREETING": "hello", "API_TOKEN": "s3cret", "DATABASE_URL": "postgres://user:s3cret@db/app", "LANG": "C"}
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", `echo "$GREETING $API_TOKEN ${HOME-unset}"`}, Env: env, EnvBase: pb.EnvBase_ENV_EMPTY, WorkingDir: "src"})
+	require.NoError(t, err)
+
+	logStream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: startRes.GetJobId()})
+	require.NoError(t, err)
+	output := []byte{}
+	for {
+		logRes, err := logStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		output = append(output, logRes.GetLog()...)
+	}
+	require.Equal(t, "hello s3cret unset\n", string(output))
+
+	statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: startRes.GetJobId()})
+	require.NoError(t, err)
+	jobInfo := statusRes.GetJobInfo()
+	require.Equal(t, map[string]string{"GREETING": "<redacted>", "API_TOKEN": "<redacted>", "DATABASE_URL": "<redacted>", "LANG": "C"}, jobInfo.Env)
+	require.Equal(t, pb.EnvBase_ENV_EMPTY, jobInfo.EnvBase)
+	require.True(t, strings.HasSuffix(jobInfo.WorkingDir, "/workspace/src"), jobInfo.WorkingDir)
+
+	invalidRequests := map[string]*pb.JobStartRequest{
+		"invalid name":           {Command: "env", Env: map[string]string{"1A": "a"}},
+		"variable of the server": {Command: "env", Env: map[string]string{worker.WorkspaceEnv: "/"}},
+		"unknown base":           {Command: "env", EnvBase: pb.EnvBase(42)},
+		"outside of workspace":   {Command: "env", WorkingDir: "../.."},
+		"missing directory":      {Command: "env", WorkingDir: "/no/such/dir"},
+	}
+	for name, req := range invalidRequests {
+		_, err := client.JobStart(ctx, req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), name)
+	}
+}
+
+// TestJobAttach starts a job in a terminal, and checks that a client attached to it can write to it and resize it, and receives its output until it is done.
+func TestJobAttach(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", `read line && stty size && echo "got $line"`}, Tty: true})
+	require.NoError(t, err)
+
+	stream, err := client.JobAttach(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.JobAttachRequest{JobId: startRes.GetJobId(), Resize: &pb.TerminalSize{Rows: 40, Cols: 120}, Stdin: []byte("hello\n")}))
+	require.NoError(t, stream.CloseSend())
+
+	output := []byte{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		output = append(output, res.GetOutput()...)
+	}
+	require.Equal(t, "hello\r\n40 120\r\ngot hello\r\n", string(output))
+
+	statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: startRes.GetJobId()})
+	require.NoError(t, err)
+	require.True(t, statusRes.GetJobInfo().Tty)
+
+	// input for a job that is not running is rejected rather than lost
+	startRes, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Tty: true, Hold: true})
+	require.NoError(t, err)
+	stream, err = client.JobAttach(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.JobAttachRequest{JobId: startRes.GetJobId(), Stdin: []byte("lost\n")}))
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.JobStop(ctx, &pb.JobStopRequest{JobId: startRes.GetJobId()})
+	require.NoError(t, err)
+
+	// jobs without a terminal can not be attached to
+	startRes, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "echo"})
+	require.NoError(t, err)
+	stream, err = client.JobAttach(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.JobAttachRequest{JobId: startRes.GetJobId()}))
+	_, err = stream.Recv()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Tty: true, TtySize: &pb.TerminalSize{Rows: 1 << 16, Cols: 80}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// TestJobStdin writes to the stdin of a job over two streams, and checks that stdin is only closed when the client asks for it.
+func TestJobStdin(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", `wc -c`}, Stdin: true})
+	require.NoError(t, err)
+	jobId := startRes.GetJobId()
+
+	for _, reqs := range [][]*pb.JobStdinRequest{
+		{{JobId: jobId, Data: []byte("hello ")}},
+		{{JobId: jobId, Data: []byte("world")}, {JobId: jobId, Data: []byte("\n"), Close: true}},
+	} {
+		stream, err := client.JobStdin(ctx)
+		require.NoError(t, err)
+		written := 0
+		for _, req := range reqs {
+			require.NoError(t, stream.Send(req))
+			written += len(req.Data)
+		}
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(written), res.GetBytesWritten())
+	}
+
+	logStream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: jobId})
+	require.NoError(t, err)
+	output := []byte{}
+	for {
+		logRes, err := logStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		output = append(output, logRes.GetLog()...)
+	}
+	require.Equal(t, "12", strings.TrimSpace(string(output)))
+
+	statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+	require.NoError(t, err)
+	require.True(t, statusRes.GetJobInfo().Stdin)
+
+	// stdin can not be written to once it is closed, or if the job was not started with stdin
+	startRes, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "sleep", Args: []string{"1"}})
+	require.NoError(t, err)
+	for _, id := range []string{jobId, startRes.GetJobId()} {
+		stream, err := client.JobStdin(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.JobStdinRequest{JobId: id, Data: []byte("late")}))
+		_, err = stream.CloseAndRecv()
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	}
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "cat", Stdin: true, Tty: true})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// TestJobFiles uploads a script to a held job, releases it, and downloads its output, checking the checksums on both ends.
+func TestJobFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", "./script.sh > out"}, Hold: true, WorkspaceRetention: pb.WorkspaceRetention_WORKSPACE_RETAIN})
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_HELD, startRes.GetJobStatus())
+	jobId := startRes.GetJobId()
+
+	upload := func(path string, data []byte, checksum string) (*pb.FileUploadResponse, error) {
+		stream, err := client.FileUpload(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.FileUploadRequest{JobId: jobId, Path: path, Mode: 0755, Data: data[:len(data)/2]}))
+		require.NoError(t, stream.Send(&pb.FileUploadRequest{JobId: jobId, Data: data[len(data)/2:], Sha256: checksum}))
+		return stream.CloseAndRecv()
+	}
+
+	script := []byte(echoLoop)
+	sum := sha256.Sum256(script)
+	checksum := hex.EncodeToString(sum[:])
+
+	// uploads with a wrong checksum, that are too large, or that leave the workspace are rejected
+	_, err = upload("script.sh", script, strings.Repeat("0", len(checksum)))
+	require.Equal(t, codes.DataLoss, status.Code(err))
+	_, err = upload("script.sh", make([]byte, 1<<20+1), checksum)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = upload("../script.sh", script, checksum)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	uploadRes, err := upload("script.sh", script, checksum)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(script)), uploadRes.GetSize())
+	require.Equal(t, checksum, uploadRes.GetSha256())
+
+	releaseRes, err := client.JobRelease(ctx, &pb.JobReleaseRequest{JobId: jobId})
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_RUNNING, releaseRes.GetJobStatus())
+	_, err = client.JobRelease(ctx, &pb.JobReleaseRequest{JobId: jobId})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = upload("late.sh", script, checksum)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	logStream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: jobId})
+	require.NoError(t, err)
+	for {
+		_, err := logStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	downloadStream, err := client.FileDownload(ctx, &pb.FileDownloadRequest{JobId: jobId, Path: "out"})
+	require.NoError(t, err)
+	output := []byte{}
+	var last *pb.FileDownloadResponse
+	for {
+		res, err := downloadStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if last == nil {
+			require.NotZero(t, res.GetSize())
+		}
+		output = append(output, res.GetData()...)
+		last = res
+	}
+	sum = sha256.Sum256(output)
+	require.Equal(t, hex.EncodeToString(sum[:]), last.GetSha256())
+	require.Contains(t, string(output), "Command no.")
+
+	downloadStream, err = client.FileDownload(ctx, &pb.FileDownloadRequest{JobId: jobId, Path: "missing"})
+	require.NoError(t, err)
+	_, err = downloadStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// only the owner of a job can release it, even if it is shared with others
+	conn2, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client2/cert.pem", "../certs/client2/key.pem")
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	startRes, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "true", Hold: true, Group: "team"})
+	require.NoError(t, err)
+	_, err = pb.NewJobServiceClient(conn2).JobRelease(ctx, &pb.JobReleaseRequest{JobId: startRes.GetJobId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestJobArtifacts declares artifacts for a job, and checks that they are listed once the job is done and can be downloaded after its workspace was deleted.
+func TestJobArtifacts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn, err := createConnection(ctx, "../certs/ca1/cert.pem", "../certs/client1/cert.pem", "../certs/client1/key.pem")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewJobServiceClient(conn)
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "true", Artifacts: []string{"../*"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "true", Artifacts: []string{"*"}, WorkspaceTmpfsMib: 1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	startRes, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", "mkdir out && echo report > out/report.txt && echo log > build.log"}, Artifacts: []string{"out/*.txt"}})
+	require.NoError(t, err)
+	jobId := startRes.GetJobId()
+
+	var info *pb.JobInfo
+	require.Eventually(t, func() bool {
+		statusRes, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+		require.NoError(t, err)
+		info = statusRes.GetJobInfo()
+		return info.GetJobStatus() == pb.JobStatus_SUCCEEDED
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sum := sha256.Sum256([]byte("report\n"))
+	require.Equal(t, []string{"out/*.txt"}, info.GetArtifactPatterns())
+	require.Len(t, info.GetArtifacts(), 1)
+	require.Equal(t, "out/report.txt", info.GetArtifacts()[0].GetName())
+	require.Equal(t, uint64(len("report\n")), info.GetArtifacts()[0].GetSize())
+	require.Equal(t, hex.EncodeToString(sum[:]), info.GetArtifacts()[0].GetSha256())
+
+	// the workspace was deleted, but the artifact is kept
+	downloadStream, err := client.FileDownload(ctx, &pb.FileDownloadRequest{JobId: jobId, Path: "out/report.txt"})
+	require.NoError(t, err)
+	_, err = downloadStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	artifactStream, err := client.ArtifactDownload(ctx, &pb.ArtifactDownloadRequest{JobId: jobId, Name: "out/report.txt"})
+	require.NoError(t, err)
+	output := []byte{}
+	checksum := ""
+	for {
+		res, err := artifactStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		output = append(output, res.GetData()...)
+		checksum = res.GetSha256()
+	}
+	require.Equal(t, "report\n", string(output))
+	require.Equal(t, hex.EncodeToString(sum[:]), checksum)
+
+	artifactStream, err = client.ArtifactDownload(ctx, &pb.ArtifactDownloadRequest{JobId: jobId, Name: "build.log"})
+	require.NoError(t, err)
+	_, err = artifactStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
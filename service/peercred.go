@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerCredInfo contains the credentials of the process on the other end of a Unix socket connection, as reported by the kernel.
+type PeerCredInfo struct {
+	credentials.CommonAuthInfo
+	Pid int32
+	Uid uint32
+	Gid uint32
+}
+
+// AuthType returns the type of PeerCredInfo as a string.
+func (PeerCredInfo) AuthType() string {
+	return "peercred"
+}
+
+// PeerCredentials authenticates clients connecting over a Unix socket by the uid and gid of their process, using SO_PEERCRED. It implements credentials.TransportCredentials, and is only supported on Linux.
+type PeerCredentials struct{}
+
+// ClientHandshake does nothing, since clients trust the server through the permissions of the socket file.
+func (PeerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, PeerCredInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}}, nil
+}
+
+// ServerHandshake reads the credentials of the client's process from the Unix socket connection.
+func (PeerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil, errors.New("peer credentials are only available on Unix socket connections")
+	}
+
+	info, err := peerCred(unixConn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, info, nil
+}
+
+// Info returns the protocol info of PeerCredentials.
+func (PeerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+// Clone returns a copy of PeerCredentials.
+func (creds PeerCredentials) Clone() credentials.TransportCredentials {
+	return creds
+}
+
+// OverrideServerName does nothing, since Unix socket connections have no server name.
+func (PeerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// peerCredFromContext extracts the peer credentials from a request context. It returns false if the client did not connect over a Unix socket.
+func peerCredFromContext(ctx context.Context) (PeerCredInfo, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return PeerCredInfo{}, false
+	}
+
+	info, ok := p.AuthInfo.(PeerCredInfo)
+	return info, ok
+}
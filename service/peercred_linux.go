@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/credentials"
+)
+
+// peerCred returns the credentials of the process on the other end of `conn`.
+func peerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+
+	var ucred *unix.Ucred
+	var ucredErr error
+	err = rawConn.Control(func(fd uintptr) {
+		ucred, ucredErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+	if ucredErr != nil {
+		return PeerCredInfo{}, ucredErr
+	}
+
+	// the kernel vouches for the credentials, and the connection never leaves the host
+	return PeerCredInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}, Pid: ucred.Pid, Uid: ucred.Uid, Gid: ucred.Gid}, nil
+}
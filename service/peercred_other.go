@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package service
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCred is not supported on this platform, so Unix socket connections are always rejected.
+func peerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	return PeerCredInfo{}, errors.New("peer credentials are only supported on Linux")
+}
@@ -0,0 +1,83 @@
+package service_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unixClient serves a job server for `jobStore` with server options `opts` on a Unix socket, and returns a client connected to it. The server is stopped when the test ends.
+func unixClient(t *testing.T, jobStore *worker.JobStore, opts ...grpc.ServerOption) pb.JobServiceClient {
+	return serveUnix(t, service.NewJobServer(jobStore), opts...)
+}
+
+// serveUnix serves `jobServer` with server options `opts` on a Unix socket, and returns a client connected to it. The server is stopped when the test ends.
+func serveUnix(t *testing.T, jobServer *service.JobServer, opts ...grpc.ServerOption) pb.JobServiceClient {
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{grpc.Creds(service.PeerCredentials{})}, opts...)...)
+	pb.RegisterJobServiceServer(grpcServer, jobServer)
+
+	socketPath := filepath.Join(t.TempDir(), "worker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("unix:"+socketPath, grpc.WithTransportCredentials(service.PeerCredentials{}))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewJobServiceClient(conn)
+}
+
+// TestPeerCredentials checks that local clients connecting over a Unix socket are mapped to a user by their uid, or by their user name.
+func TestPeerCredentials(t *testing.T) {
+	t.Parallel()
+
+	currentUser, err := user.Current()
+	require.NoError(t, err)
+
+	uid := uint32(os.Getuid())
+	otherUid := uid + 1
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "other", Clients: []service.ClientConfig{{UID: &otherUid}}},
+	}})
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+
+	client := unixClient(t, jobStore, grpc.UnaryInterceptor(authorizer.UnaryAuth), grpc.StreamInterceptor(authorizer.StreamAuth))
+
+	// the current uid is not in the policy
+	_, err = client.JobList(context.Background(), &pb.JobListRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the current user is identified by name
+	policy, err = service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Roles: []service.Role{service.RoleAdmin}, Clients: []service.ClientConfig{{UnixUser: currentUser.Username}}},
+	}})
+	require.NoError(t, err)
+	authorizer.SetPolicy(policy)
+
+	_, err = client.JobList(context.Background(), &pb.JobListRequest{})
+	require.NoError(t, err)
+
+	// local clients have no certificate that a token could be bound to
+	_, err = client.TokenIssue(context.Background(), &pb.TokenIssueRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	user, ok := policy.IdentifyUid(uid)
+	require.True(t, ok)
+	require.Equal(t, "local", user.Id)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+)
+
+// Permission is an action that an RPC requires the client to be allowed to perform.
+type Permission string
+
+const (
+	PermissionJobStart   Permission = "job.start" // PermissionJobStart also allows users to upload files to their held jobs, and to release them.
+	PermissionJobStop    Permission = "job.stop"
+	PermissionJobStatus  Permission = "job.status"  // PermissionJobStatus also controls which jobs are returned by JobList.
+	PermissionJobLogs    Permission = "job.logs"    // PermissionJobLogs also allows users to download files from the workspaces of jobs, and their artifacts.
+	PermissionJobAttach  Permission = "job.attach"  // PermissionJobAttach allows users to write input to jobs, through their terminal or their stdin, and to follow their output.
+	PermissionJobApprove Permission = "job.approve" // PermissionJobApprove allows users to approve jobs that the command policy holds for approval.
+
+	PermissionTokenIssue Permission = "token.issue" // PermissionTokenIssue allows users to issue bearer tokens for themselves.
+)
+
+// Scope determines which jobs a permission applies to. A higher scope includes all jobs of the lower scopes.
+type Scope int
+
+const (
+	ScopeNone   Scope = iota // ScopeNone means that the permission is not granted.
+	ScopeOwn                 // ScopeOwn applies to jobs started by the user.
+	ScopeShared              // ScopeShared applies to jobs started by the user, and jobs that were shared with a group they are a member of.
+	ScopeAny                 // ScopeAny applies to jobs of all users.
+)
+
+var (
+	// methodPermissions declares the permission that each RPC requires. RPCs that are not listed here are denied to everyone.
+	methodPermissions = map[string]Permission{
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobStart":         PermissionJobStart,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobStop":          PermissionJobStop,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobStatus":        PermissionJobStatus,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobLogsStream":    PermissionJobLogs,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobList":          PermissionJobStatus,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/TokenIssue":       PermissionTokenIssue,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobApprove":       PermissionJobApprove,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobAttach":        PermissionJobAttach,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobStdin":         PermissionJobAttach,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobRelease":       PermissionJobStart,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/FileUpload":       PermissionJobStart,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/FileDownload":     PermissionJobLogs,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/ArtifactDownload": PermissionJobLogs,
+	}
+
+	// certificateOnlyMethods are RPCs that can only be called with a client certificate, so that a leaked token can not be used to renew itself, and every token is bound to a certificate that can be revoked.
+	certificateOnlyMethods = map[string]bool{
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/TokenIssue": true,
+	}
+
+	// roleGrants maps each role to the permissions it grants, and the scope they are granted at. Permissions that a role does not list are not granted.
+	roleGrants = map[Role]map[Permission]Scope{
+		RoleAdmin: {
+			PermissionJobStart:   ScopeOwn,
+			PermissionJobStop:    ScopeAny,
+			PermissionJobStatus:  ScopeAny,
+			PermissionJobLogs:    ScopeAny,
+			PermissionJobApprove: ScopeAny,
+			PermissionJobAttach:  ScopeOwn, // attaching writes to the job, which may run as another user, so admins can only attach to their own jobs
+
+			PermissionTokenIssue: ScopeOwn,
+		},
+		RoleOperator: {
+			PermissionJobStart:  ScopeOwn,
+			PermissionJobStop:   ScopeAny,
+			PermissionJobStatus: ScopeAny,
+			PermissionJobLogs:   ScopeShared,
+			PermissionJobAttach: ScopeOwn, // attaching writes to the job, which may run as its owner, so sharing a job does not grant it
+
+			PermissionTokenIssue: ScopeOwn,
+		},
+		RoleUser: {
+			PermissionJobStart:  ScopeOwn,
+			PermissionJobStop:   ScopeShared,
+			PermissionJobStatus: ScopeShared,
+			PermissionJobLogs:   ScopeShared,
+			PermissionJobAttach: ScopeOwn,
+
+			PermissionTokenIssue: ScopeOwn,
+		},
+		RoleViewer: {
+			PermissionJobStatus: ScopeShared,
+			PermissionJobLogs:   ScopeShared,
+
+			PermissionTokenIssue: ScopeOwn,
+		},
+	}
+)
+
+// Scope returns the widest scope at which any of the user's roles grants `permission`.
+func (user *User) Scope(permission Permission) Scope {
+	scope := ScopeNone
+	for _, role := range user.Roles {
+		if roleScope := roleGrants[role][permission]; roleScope > scope {
+			scope = roleScope
+		}
+	}
+
+	return scope
+}
+
+// CanAccess returns true if `job` is within `scope` for the user.
+func (user *User) CanAccess(scope Scope, job *worker.Job) bool {
+	switch scope {
+	case ScopeAny:
+		return true
+	case ScopeShared:
+		return job.Key.UserId == user.Id || (job.Group != "" && user.IsMember(job.Group))
+	case ScopeOwn:
+		return job.Key.UserId == user.Id
+	default:
+		return false
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+const (
+	encryptedKeyBlockType = "ENCRYPTED PRIVATE KEY" // encryptedKeyBlockType is the PEM type of encrypted PKCS #8 keys.
+	pbkdf2Iterations      = 600000                  // pbkdf2Iterations is the number of PBKDF2 iterations used to derive the key that encrypts new keys.
+	pbkdf2SaltSize        = 16                      // pbkdf2SaltSize is the size in bytes of the salt of new keys.
+)
+
+var (
+	ErrIncorrectPassphrase = errors.New("the passphrase is incorrect")
+
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+
+	// aesCBCKeySizes maps the OIDs of the AES-CBC encryption schemes to their key size in bytes.
+	aesCBCKeySizes = map[string]int{
+		"2.16.840.1.101.3.4.1.2":  16,
+		"2.16.840.1.101.3.4.1.22": 24,
+		"2.16.840.1.101.3.4.1.42": 32,
+	}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is the EncryptedPrivateKeyInfo structure of RFC 5208.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the parameters of the PBES2 encryption scheme of RFC 8018.
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the parameters of the PBKDF2 key derivation function of RFC 8018. The key length is implied by the encryption scheme.
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPKCS8 encrypts the PKCS #8 key `der` with `passphrase`, using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC, which OpenSSL also reads and writes by default.
+func encryptPKCS8(der []byte, passphrase []byte) (*pem.Block, error) {
+	salt := make([]byte, pbkdf2SaltSize)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2(passphrase, salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// pad to the block size as in PKCS #7
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	encrypted := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{Salt: salt, IterationCount: pbkdf2Iterations, PRF: pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue}})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, EncryptedData: encrypted})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pem.Block{Type: encryptedKeyBlockType, Bytes: info}, nil
+}
+
+// decryptPKCS8 decrypts an encrypted PKCS #8 key with `passphrase`, and returns the key in PKCS #8 DER format. Only PBES2 with PBKDF2 and AES-CBC is supported.
+func decryptPKCS8(data []byte, passphrase []byte) ([]byte, error) {
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("malformed encrypted key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption %s", info.Algorithm.Algorithm)
+	}
+
+	params := pbes2Params{}
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("malformed encrypted key: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	keySize, ok := aesCBCKeySizes[params.EncryptionScheme.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key encryption scheme %s", params.EncryptionScheme.Algorithm)
+	}
+	iv := []byte{}
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("malformed encrypted key: invalid IV")
+	}
+
+	kdfParams := pbkdf2Params{}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("malformed encrypted key: %w", err)
+	}
+	if kdfParams.IterationCount <= 0 || (kdfParams.KeyLength != 0 && kdfParams.KeyLength != keySize) {
+		return nil, errors.New("malformed encrypted key: invalid key derivation parameters")
+	}
+
+	// the pseudorandom function defaults to HMAC-SHA1
+	prf := sha1.New
+	switch {
+	case len(kdfParams.PRF.Algorithm) == 0 || kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported pseudorandom function %s", kdfParams.PRF.Algorithm)
+	}
+
+	encrypted := info.EncryptedData
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted key: invalid length")
+	}
+
+	block, err := aes.NewCipher(pbkdf2(passphrase, kdfParams.Salt, kdfParams.IterationCount, keySize, prf))
+	if err != nil {
+		return nil, err
+	}
+	der := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(der, encrypted)
+
+	// a wrong passphrase shows as invalid padding, or rarely as a key that does not parse
+	padding := int(der[len(der)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(der[len(der)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	return der[:len(der)-padding], nil
+}
+
+// pbkdf2 derives a key of `keyLen` bytes from `password` and `salt` as in RFC 8018, with HMAC over hash function `h` as the pseudorandom function.
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	key := []byte{}
+	for blockIndex := uint32(1); len(key) < keyLen; blockIndex++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, blockIndex)
+		u := prf.Sum(nil)
+
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is a named set of permissions that can be granted to a user.
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"    // RoleAdmin can start jobs, and list, stop and view the status and logs of any user's jobs.
+	RoleOperator Role = "operator" // RoleOperator can start jobs, list and stop any user's jobs and view their status, but can only view logs of their own and shared jobs.
+	RoleUser     Role = "user"     // RoleUser can start jobs, and stop and view jobs that they started or that were shared with them.
+	RoleViewer   Role = "viewer"   // RoleViewer can not start or stop jobs, and can only view the status and logs of jobs that were shared with them.
+)
+
+var (
+	knownRoles = map[Role]bool{RoleAdmin: true, RoleOperator: true, RoleUser: true, RoleViewer: true} // knownRoles contains all roles that can be assigned in the authorization config.
+)
+
+// IsKnown returns true if `role` is one of the roles that can be assigned in the authorization config.
+func (role Role) IsKnown() bool {
+	return knownRoles[role]
+}
+
+// PolicyConfig is the on-disk format of the authorization config. Both YAML and JSON are accepted.
+type PolicyConfig struct {
+	Identity IdentitySource `yaml:"identity"` // Identity is the part of the client certificate that clients are identified by. It defaults to `subject`.
+	Users    []UserConfig   `yaml:"users"`
+	Groups   []GroupConfig  `yaml:"groups"`
+	Quotas   map[Role]Quota `yaml:"quotas"` // Quotas limits the users of each role. Roles that are not listed are not limited.
+
+	Security *worker.SecurityProfile `yaml:"security"` // Security restricts the privileges of every job, if it is set.
+
+	Commands []CommandRuleConfig `yaml:"commands"` // Commands are the rules of the command policy, in order of precedence. If there are none, any command can be run.
+	Secrets  []SecretRuleConfig  `yaml:"secrets"`  // Secrets are the rules that grant users access to secrets of the secret store. If there are none, no secrets can be used.
+}
+
+// UserConfig maps one or more client certificate identities to a user id and its roles.
+type UserConfig struct {
+	Id      string         `yaml:"id"`
+	Roles   []Role         `yaml:"roles"` // Roles defaults to `user` if empty.
+	Clients []ClientConfig `yaml:"clients"`
+	Quota   *Quota         `yaml:"quota"`  // Quota replaces the quotas of the user's roles, if it is set.
+	RunAs   *RunAsConfig   `yaml:"run_as"` // RunAs is the Unix user and groups that the user's jobs run as. If it is not set, jobs run as the server's user.
+
+	Security *worker.SecurityProfile `yaml:"security"` // Security replaces the security profile of the user's jobs, if it is set.
+}
+
+// RunAsConfig is the Unix user and groups that jobs run as. Either User, or both UID and GID, must be set.
+type RunAsConfig struct {
+	User   string   `yaml:"user"`   // User is the name of a local user, whose uid, primary group and supplementary groups are used.
+	UID    *uint32  `yaml:"uid"`    // UID is the uid to run as, if User is not set.
+	GID    *uint32  `yaml:"gid"`    // GID is the primary gid to run as, if User is not set.
+	Groups []string `yaml:"groups"` // Groups are the supplementary groups, as names or gids. They replace the groups of User, if set. Without groups, jobs have no supplementary groups.
+}
+
+// credential resolves the config to a uid, gid and supplementary gids.
+func (config RunAsConfig) credential() (*Credential, error) {
+	credential := &Credential{}
+	groups := config.Groups
+
+	switch {
+	case config.User != "" && (config.UID != nil || config.GID != nil):
+		return nil, errors.New("user can not be combined with uid and gid")
+	case config.User != "":
+		unixUser, err := user.Lookup(config.User)
+		if err != nil {
+			return nil, err
+		}
+
+		uid, err := strconv.ParseUint(unixUser.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %q has a non-numeric uid %q", config.User, unixUser.Uid)
+		}
+		gid, err := strconv.ParseUint(unixUser.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %q has a non-numeric gid %q", config.User, unixUser.Gid)
+		}
+		credential.Uid, credential.Gid = uint32(uid), uint32(gid)
+
+		if groups == nil {
+			groups, err = unixUser.GroupIds()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case config.UID != nil && config.GID != nil:
+		credential.Uid, credential.Gid = *config.UID, *config.GID
+	default:
+		return nil, errors.New("either user, or both uid and gid are required")
+	}
+
+	for _, group := range groups {
+		gid, err := strconv.ParseUint(group, 10, 32)
+		if err != nil {
+			unixGroup, lookupErr := user.LookupGroup(group)
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			gid, err = strconv.ParseUint(unixGroup.Gid, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("group %q has a non-numeric gid %q", group, unixGroup.Gid)
+			}
+		}
+		credential.Groups = append(credential.Groups, uint32(gid))
+	}
+
+	return credential, nil
+}
+
+// Credential is the uid, gid and supplementary gids that a user's jobs run as.
+type Credential struct {
+	Uid    uint32
+	Gid    uint32
+	Groups []uint32
+}
+
+// Quota limits how much of the server a single user can use. Zero values are not limited.
+type Quota struct {
+	MaxRunningJobs int `yaml:"max_running_jobs"` // MaxRunningJobs is the maximum number of the user's jobs that can be running at the same time.
+	JobsPerMinute  int `yaml:"jobs_per_minute"`  // JobsPerMinute is the maximum number of jobs that the user can start in any 60 second window.
+	MaxLogStreams  int `yaml:"max_log_streams"`  // MaxLogStreams is the maximum number of log streams that the user can have open at the same time.
+}
+
+// validate returns an error if any of the quota's limits is negative.
+func (quota Quota) validate() error {
+	if quota.MaxRunningJobs < 0 || quota.JobsPerMinute < 0 || quota.MaxLogStreams < 0 {
+		return errors.New("quota limits can not be negative")
+	}
+
+	return nil
+}
+
+// roleQuota returns the quota of a user with roles `roles`. Users with several roles get the most permissive limit of any of their roles, and roles without a quota are not limited.
+func roleQuota(quotas map[Role]Quota, roles []Role) Quota {
+	mostPermissive := func(limit int, roleLimit int) int {
+		if limit == 0 || roleLimit == 0 {
+			return 0
+		}
+		if roleLimit > limit {
+			return roleLimit
+		}
+		return limit
+	}
+
+	quota := Quota{}
+	for i, role := range roles {
+		roleQuota, ok := quotas[role]
+		if !ok {
+			return Quota{}
+		}
+		if i == 0 {
+			quota = roleQuota
+			continue
+		}
+
+		quota.MaxRunningJobs = mostPermissive(quota.MaxRunningJobs, roleQuota.MaxRunningJobs)
+		quota.JobsPerMinute = mostPermissive(quota.JobsPerMinute, roleQuota.JobsPerMinute)
+		quota.MaxLogStreams = mostPermissive(quota.MaxLogStreams, roleQuota.MaxLogStreams)
+	}
+
+	return quota
+}
+
+// ClientConfig identifies a client certificate. Only the fields of the configured identity source may be set: Issuer and Subject (formatted as RDN sequence strings) for `subject`, and the field of the same name for the other sources.
+type ClientConfig struct {
+	Issuer      string `yaml:"issuer,omitempty"`
+	Subject     string `yaml:"subject,omitempty"`
+	URI         string `yaml:"uri,omitempty"`
+	DNS         string `yaml:"dns,omitempty"`
+	Email       string `yaml:"email,omitempty"`
+	Fingerprint string `yaml:"fingerprint,omitempty"` // Fingerprint is the SHA-256 fingerprint of the certificate, in hex with or without colons.
+
+	// local clients connecting over the Unix socket are identified by the uid of their process, independently of the identity source
+
+	UID      *uint32 `yaml:"uid,omitempty"`
+	UnixUser string  `yaml:"unix_user,omitempty"` // UnixUser is resolved to a uid when the config is loaded.
+}
+
+// unixUid returns the uid that the client config identifies local clients by, and false if it identifies a client certificate instead.
+func (config ClientConfig) unixUid() (uint32, bool, error) {
+	if config.UID == nil && config.UnixUser == "" {
+		return 0, false, nil
+	}
+
+	if config.Issuer != "" || config.Subject != "" || config.URI != "" || config.DNS != "" || config.Email != "" || config.Fingerprint != "" {
+		return 0, false, errors.New("uid and unix_user can not be combined with a client certificate identity in the same entry")
+	}
+	if config.UID != nil && config.UnixUser != "" {
+		return 0, false, errors.New("only one of uid and unix_user can be set")
+	}
+	if config.UID != nil {
+		return *config.UID, true, nil
+	}
+
+	unixUser, err := user.Lookup(config.UnixUser)
+	if err != nil {
+		return 0, false, err
+	}
+
+	uid, err := strconv.ParseUint(unixUser.Uid, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("unix_user %q has a non-numeric uid %q", config.UnixUser, unixUser.Uid)
+	}
+
+	return uint32(uid), true, nil
+}
+
+// clientId validates the client config for identity source `source`, and returns the client id it matches.
+func (config ClientConfig) clientId(source IdentitySource) (ClientId, error) {
+	fields := map[IdentitySource]string{IdentityURI: config.URI, IdentityDNS: config.DNS, IdentityEmail: config.Email, IdentityFingerprint: config.Fingerprint}
+
+	// fields of other identity sources are most likely a mistake, so they are rejected rather than ignored
+	for fieldSource, value := range fields {
+		if fieldSource != source && value != "" {
+			return ClientId{}, fmt.Errorf("%s is set, but the identity source is %q", fieldSource, source)
+		}
+	}
+	if source != IdentitySubject && (config.Issuer != "" || config.Subject != "") {
+		return ClientId{}, fmt.Errorf("issuer and subject are set, but the identity source is %q", source)
+	}
+
+	switch source {
+	case IdentitySubject:
+		if config.Issuer == "" || config.Subject == "" {
+			return ClientId{}, errors.New("both issuer and subject are required")
+		}
+		return ClientId{Issuer: config.Issuer, Subject: config.Subject}, nil
+	case IdentityDNS:
+		if config.DNS == "" {
+			return ClientId{}, errors.New("dns is required")
+		}
+		return ClientId{Name: strings.ToLower(config.DNS)}, nil
+	case IdentityFingerprint:
+		fingerprint := normalizeFingerprint(config.Fingerprint)
+		if _, err := hex.DecodeString(fingerprint); err != nil || len(fingerprint) != 2*sha256.Size {
+			return ClientId{}, fmt.Errorf("fingerprint %q is not a hex-encoded SHA-256 hash", config.Fingerprint)
+		}
+		return ClientId{Name: fingerprint}, nil
+	default:
+		if fields[source] == "" {
+			return ClientId{}, fmt.Errorf("%s is required", source)
+		}
+		return ClientId{Name: fields[source]}, nil
+	}
+}
+
+// GroupConfig defines a group of users that can share jobs with each other.
+type GroupConfig struct {
+	Name    string   `yaml:"name"`
+	Members []string `yaml:"members"` // Members are user ids.
+}
+
+// User is an authorized user of the API.
+type User struct {
+	Id     string
+	Roles  []Role
+	Groups []string    // Groups are the names of the groups that the user is a member of.
+	Quota  Quota       // Quota limits the user's use of the server.
+	RunAs  *Credential // RunAs is the credential that the user's jobs run with, or nil to run them as the server's user.
+
+	Security *worker.SecurityProfile // Security restricts the privileges of the user's jobs, if it is not nil.
+}
+
+// IsMember returns true if the user is a member of `group`.
+func (user *User) IsMember(group string) bool {
+	for _, userGroup := range user.Groups {
+		if userGroup == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Policy is a validated authorization config. It is safe for concurrent reads.
+type Policy struct {
+	identity IdentitySource     // identity is the part of the client certificate that clients are identified by.
+	clients  map[ClientId]*User // clients maps client ids to a user. Clients not in this map will not be allowed access.
+	users    map[string]*User   // users maps user ids to a user.
+	uids     map[uint32]*User   // uids maps the uids of local clients to a user.
+	commands []commandRule      // commands are the rules of the command policy.
+	secrets  []SecretRuleConfig // secrets are the validated rules that grant access to secrets.
+}
+
+// Identify returns the user that the client certificate `cert` is mapped to, the client id that it was matched by, and whether the client is authorized at all. If the certificate can be identified by multiple client ids, e.g. because it has multiple URI SANs, the first one found in the policy is used.
+func (policy *Policy) Identify(cert *x509.Certificate) (*User, ClientId, bool) {
+	clientIds := ClientIds(policy.identity, cert)
+	for _, clientId := range clientIds {
+		if user, ok := policy.clients[clientId]; ok {
+			return user, clientId, true
+		}
+	}
+
+	if len(clientIds) == 0 {
+		return nil, ClientId{}, false
+	}
+	return nil, clientIds[0], false
+}
+
+// Authorize returns the user that `clientId` is mapped to, and whether the client is authorized at all.
+func (policy *Policy) Authorize(clientId ClientId) (*User, bool) {
+	user, ok := policy.clients[clientId]
+	return user, ok
+}
+
+// IdentifyUid returns the user that local clients running as `uid` are mapped to, and whether they are authorized at all.
+func (policy *Policy) IdentifyUid(uid uint32) (*User, bool) {
+	user, ok := policy.uids[uid]
+	return user, ok
+}
+
+// LookupUser returns the user with id `userId`, and whether the user is in the policy at all.
+func (policy *Policy) LookupUser(userId string) (*User, bool) {
+	user, ok := policy.users[userId]
+	return user, ok
+}
+
+// LoadPolicy reads and validates the authorization config at `path`.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// ParsePolicy parses and validates an authorization config. Unknown fields are rejected so that typos do not silently grant or deny access.
+func ParsePolicy(data []byte) (*Policy, error) {
+	config := PolicyConfig{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("malformed authorization config: %w", err)
+	}
+
+	return NewPolicy(config)
+}
+
+// NewPolicy validates `config` and builds a Policy from it.
+func NewPolicy(config PolicyConfig) (*Policy, error) {
+	policy := &Policy{identity: config.Identity, clients: map[ClientId]*User{}, uids: map[uint32]*User{}}
+	if policy.identity == "" {
+		policy.identity = IdentitySubject
+	}
+	if _, ok := identityExtractors[policy.identity]; !ok {
+		return nil, fmt.Errorf("identity: unknown identity source %q", policy.identity)
+	}
+
+	for role, quota := range config.Quotas {
+		if !knownRoles[role] {
+			return nil, fmt.Errorf("quotas: unknown role %q", role)
+		}
+		if err := quota.validate(); err != nil {
+			return nil, fmt.Errorf("quotas (%s): %w", role, err)
+		}
+	}
+
+	if config.Security != nil {
+		if err := config.Security.Validate(); err != nil {
+			return nil, fmt.Errorf("security: %w", err)
+		}
+	}
+
+	for i, ruleConfig := range config.Commands {
+		rule, err := newCommandRule(ruleConfig)
+		if err != nil {
+			return nil, fmt.Errorf("commands[%d]: %w", i, err)
+		}
+		policy.commands = append(policy.commands, rule)
+	}
+
+	for i, ruleConfig := range config.Secrets {
+		if err := ruleConfig.validate(); err != nil {
+			return nil, fmt.Errorf("secrets[%d]: %w", i, err)
+		}
+		policy.secrets = append(policy.secrets, ruleConfig)
+	}
+
+	users := map[string]*User{}
+	policy.users = users
+
+	for i, userConfig := range config.Users {
+		if userConfig.Id == "" {
+			return nil, fmt.Errorf("users[%d]: id is required", i)
+		}
+		if _, ok := users[userConfig.Id]; ok {
+			return nil, fmt.Errorf("users[%d]: duplicate user id %q", i, userConfig.Id)
+		}
+
+		user := &User{Id: userConfig.Id, Roles: userConfig.Roles}
+		users[user.Id] = user
+		if len(user.Roles) == 0 {
+			user.Roles = []Role{RoleUser}
+		}
+		for _, role := range user.Roles {
+			if !knownRoles[role] {
+				return nil, fmt.Errorf("users[%d] (%s): unknown role %q", i, user.Id, role)
+			}
+		}
+
+		if userConfig.RunAs != nil {
+			credential, err := userConfig.RunAs.credential()
+			if err != nil {
+				return nil, fmt.Errorf("users[%d] (%s): run_as: %w", i, user.Id, err)
+			}
+			user.RunAs = credential
+		}
+
+		user.Security = config.Security
+		if userConfig.Security != nil {
+			if err := userConfig.Security.Validate(); err != nil {
+				return nil, fmt.Errorf("users[%d] (%s): security: %w", i, user.Id, err)
+			}
+			user.Security = userConfig.Security
+		}
+
+		user.Quota = roleQuota(config.Quotas, user.Roles)
+		if userConfig.Quota != nil {
+			if err := userConfig.Quota.validate(); err != nil {
+				return nil, fmt.Errorf("users[%d] (%s): quota: %w", i, user.Id, err)
+			}
+			user.Quota = *userConfig.Quota
+		}
+
+		if len(userConfig.Clients) == 0 {
+			return nil, fmt.Errorf("users[%d] (%s): at least one client is required", i, user.Id)
+		}
+		for j, clientConfig := range userConfig.Clients {
+			uid, ok, err := clientConfig.unixUid()
+			if err != nil {
+				return nil, fmt.Errorf("users[%d] (%s): clients[%d]: %w", i, user.Id, j, err)
+			}
+			if ok {
+				if existing, ok := policy.uids[uid]; ok {
+					return nil, fmt.Errorf("users[%d] (%s): clients[%d]: uid %d is already mapped to user %q", i, user.Id, j, uid, existing.Id)
+				}
+				policy.uids[uid] = user
+				continue
+			}
+
+			clientId, err := clientConfig.clientId(policy.identity)
+			if err != nil {
+				return nil, fmt.Errorf("users[%d] (%s): clients[%d]: %w", i, user.Id, j, err)
+			}
+
+			if existing, ok := policy.clients[clientId]; ok {
+				return nil, fmt.Errorf("users[%d] (%s): clients[%d]: client is already mapped to user %q", i, user.Id, j, existing.Id)
+			}
+			policy.clients[clientId] = user
+		}
+	}
+
+	groupNames := map[string]bool{}
+	for i, groupConfig := range config.Groups {
+		if groupConfig.Name == "" {
+			return nil, fmt.Errorf("groups[%d]: name is required", i)
+		}
+		if groupNames[groupConfig.Name] {
+			return nil, fmt.Errorf("groups[%d]: duplicate group name %q", i, groupConfig.Name)
+		}
+		groupNames[groupConfig.Name] = true
+
+		for j, member := range groupConfig.Members {
+			user, ok := users[member]
+			if !ok {
+				return nil, fmt.Errorf("groups[%d] (%s): members[%d]: unknown user id %q", i, groupConfig.Name, j, member)
+			}
+			if user.IsMember(groupConfig.Name) {
+				return nil, fmt.Errorf("groups[%d] (%s): members[%d]: duplicate member %q", i, groupConfig.Name, j, member)
+			}
+			user.Groups = append(user.Groups, groupConfig.Name)
+		}
+	}
+
+	for i, ruleConfig := range config.Secrets {
+		for j, userId := range ruleConfig.Users {
+			if _, ok := users[userId]; !ok {
+				return nil, fmt.Errorf("secrets[%d]: users[%d]: unknown user id %q", i, j, userId)
+			}
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,215 @@
+package service_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer  = "CN=CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+	testSubject = "CN=Client 1,OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+)
+
+// TestParsePolicy checks that a valid config in either YAML or JSON maps client identities to users, and that roles default to `user`.
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	yamlConfig := `
+users:
+  - id: alice
+    clients:
+      - issuer: "CN=CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+        subject: "CN=Client 1,OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"
+`
+	jsonConfig := `{"users": [{"id": "alice", "roles": ["user"], "clients": [{"issuer": "CN=CA,OU=CA,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA", "subject": "CN=Client 1,OU=Client,O=Mohamed\\, Inc.,L=Vancouver,ST=British Columbia,C=CA"}]}]}`
+
+	for _, config := range []string{yamlConfig, jsonConfig} {
+		policy, err := service.ParsePolicy([]byte(config))
+		require.NoError(t, err)
+
+		user, ok := policy.Authorize(service.ClientId{Issuer: testIssuer, Subject: testSubject})
+		require.True(t, ok)
+		require.Equal(t, "alice", user.Id)
+		require.Equal(t, []service.Role{service.RoleUser}, user.Roles)
+
+		_, ok = policy.Authorize(service.ClientId{Issuer: testIssuer, Subject: "CN=Client 3"})
+		require.False(t, ok)
+	}
+}
+
+// TestParsePolicyInvalid checks that malformed configs are rejected with an error pointing at the offending entry.
+func TestParsePolicyInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"unknown field":   "users:\n  - id: alice\n    name: Alice\n",
+		"missing id":      "users:\n  - clients: [{issuer: a, subject: b}]\n",
+		"duplicate id":    "users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\n  - id: alice\n    clients: [{issuer: a, subject: c}]\n",
+		"unknown role":    "users:\n  - id: alice\n    roles: [superuser]\n    clients: [{issuer: a, subject: b}]\n",
+		"no clients":      "users:\n  - id: alice\n",
+		"missing subject": "users:\n  - id: alice\n    clients: [{issuer: a}]\n",
+		"shared client":   "users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\n  - id: bob\n    clients: [{issuer: a, subject: b}]\n",
+		"not a list":      "users: alice\n",
+		"unnamed group":   "users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\ngroups:\n  - members: [alice]\n",
+		"unknown member":  "users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\ngroups:\n  - name: team\n    members: [bob]\n",
+		"duplicate group": "users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\ngroups:\n  - name: team\n  - name: team\n",
+		"shared uid":      "users:\n  - id: alice\n    clients: [{uid: 1000}]\n  - id: bob\n    clients: [{uid: 1000}]\n",
+		"uid and subject": "users:\n  - id: alice\n    clients: [{uid: 1000, issuer: a, subject: b}]\n",
+		"unknown user":    "users:\n  - id: alice\n    clients: [{unix_user: no-such-user}]\n",
+		"run_as no gid":   "users:\n  - id: alice\n    clients: [{uid: 1000}]\n    run_as: {uid: 1000}\n",
+		"run_as user uid": "users:\n  - id: alice\n    clients: [{uid: 1000}]\n    run_as: {user: root, uid: 1000}\n",
+		"run_as group":    "users:\n  - id: alice\n    clients: [{uid: 1000}]\n    run_as: {uid: 1000, gid: 1000, groups: [no-such-group]}\n",
+		"capability":      "users:\n  - id: alice\n    clients: [{uid: 1000}]\nsecurity: {capabilities: [CAP_NO_SUCH_CAPABILITY]}\n",
+		"syscall":         "users:\n  - id: alice\n    clients: [{uid: 1000}]\n    security: {blocked_syscalls: [no_such_syscall]}\n",
+	}
+
+	for name, config := range testCases {
+		_, err := service.ParsePolicy([]byte(config))
+		require.Error(t, err, name)
+	}
+}
+
+// TestReloadPolicy checks that a reload swaps in a valid config, and keeps the previous policy if the new config is invalid. It also checks that changes on disk are picked up by WatchPolicy.
+func TestReloadPolicy(t *testing.T) {
+	t.Parallel()
+
+	clientId := service.ClientId{Issuer: "a", Subject: "b"}
+	path := filepath.Join(t.TempDir(), "auth.yaml")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("users:\n  - id: alice\n    clients: [{issuer: a, subject: b}]\n"), 0644))
+	policy, err := service.LoadPolicy(path)
+	require.NoError(t, err)
+	auth := service.NewAuthorizer(policy, worker.NewJobStore())
+
+	// an invalid config should leave the previous policy in place
+	require.NoError(t, ioutil.WriteFile(path, []byte("users:\n  - id: bob\n"), 0644))
+	require.Error(t, auth.ReloadPolicy(path))
+	user, ok := auth.GetPolicy().Authorize(clientId)
+	require.True(t, ok)
+	require.Equal(t, "alice", user.Id)
+
+	// a valid config should be swapped in
+	require.NoError(t, ioutil.WriteFile(path, []byte("users:\n  - id: bob\n    clients: [{issuer: a, subject: b}]\n"), 0644))
+	require.NoError(t, auth.ReloadPolicy(path))
+	user, ok = auth.GetPolicy().Authorize(clientId)
+	require.True(t, ok)
+	require.Equal(t, "bob", user.Id)
+
+	// changes on disk should be picked up by the watcher
+	done := make(chan struct{})
+	defer close(done)
+	require.NoError(t, auth.WatchPolicy(done, path))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("users:\n  - id: carol\n    clients: [{issuer: a, subject: c}]\n"), 0644))
+	require.Eventually(t, func() bool {
+		_, ok := auth.GetPolicy().Authorize(clientId)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestIdentitySources checks that clients can be identified by each supported part of their certificate.
+func TestIdentitySources(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	spiffeId, err := url.Parse("spiffe://example.org/ci/runner")
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "CI Runner", Organization: []string{"Mohamed, Inc."}},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		URIs:           []*url.URL{spiffeId},
+		DNSNames:       []string{"Runner.example.org"},
+		EmailAddresses: []string{"ci@example.org"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	fingerprint := strings.ToUpper(service.Fingerprint(cert))
+
+	testCases := map[service.IdentitySource]service.ClientConfig{
+		service.IdentitySubject:     {Issuer: "CN=CI Runner,O=Mohamed\\, Inc.", Subject: "CN=CI Runner,O=Mohamed\\, Inc."},
+		service.IdentityURI:         {URI: "spiffe://example.org/ci/runner"},
+		service.IdentityDNS:         {DNS: "runner.example.org"},
+		service.IdentityEmail:       {Email: "ci@example.org"},
+		service.IdentityFingerprint: {Fingerprint: fingerprint[:2] + ":" + fingerprint[2:]},
+	}
+
+	for source, clientConfig := range testCases {
+		policy, err := service.NewPolicy(service.PolicyConfig{Identity: source, Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{clientConfig}}}})
+		require.NoError(t, err, source)
+
+		user, _, ok := policy.Identify(cert)
+		require.True(t, ok, source)
+		require.Equal(t, "ci", user.Id, source)
+	}
+
+	// a certificate without a matching SAN should not be identified
+	policy, err := service.NewPolicy(service.PolicyConfig{Identity: service.IdentityURI, Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{{URI: "spiffe://example.org/other"}}}}})
+	require.NoError(t, err)
+	_, _, ok := policy.Identify(cert)
+	require.False(t, ok)
+
+	// fields of another identity source and malformed fingerprints should be rejected
+	invalidConfigs := []service.PolicyConfig{
+		{Identity: "serial", Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{{URI: "spiffe://example.org/ci/runner"}}}}},
+		{Identity: service.IdentityURI, Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{{DNS: "runner.example.org"}}}}},
+		{Identity: service.IdentityDNS, Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{{Issuer: "a", Subject: "b"}}}}},
+		{Identity: service.IdentityFingerprint, Users: []service.UserConfig{{Id: "ci", Clients: []service.ClientConfig{{Fingerprint: "abcd"}}}}},
+	}
+	for _, config := range invalidConfigs {
+		_, err := service.NewPolicy(config)
+		require.Error(t, err)
+	}
+}
+
+// TestRunAs checks that the Unix user and groups that jobs run as are resolved from names and ids.
+func TestRunAs(t *testing.T) {
+	t.Parallel()
+
+	config := `
+users:
+  - id: alice
+    clients: [{issuer: a, subject: alice}]
+    run_as: {uid: 1000, gid: 1001, groups: ["1002", root]}
+  - id: bob
+    clients: [{issuer: a, subject: bob}]
+    run_as: {user: root, groups: []}
+  - id: carol
+    clients: [{issuer: a, subject: carol}]
+`
+	policy, err := service.ParsePolicy([]byte(config))
+	require.NoError(t, err)
+
+	alice, ok := policy.LookupUser("alice")
+	require.True(t, ok)
+	require.Equal(t, &service.Credential{Uid: 1000, Gid: 1001, Groups: []uint32{1002, 0}}, alice.RunAs)
+
+	bob, ok := policy.LookupUser("bob")
+	require.True(t, ok)
+	require.Equal(t, &service.Credential{Uid: 0, Gid: 0}, bob.RunAs)
+
+	carol, ok := policy.LookupUser("carol")
+	require.True(t, ok)
+	require.Nil(t, carol.RunAs)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	retryAfterHeader = "retry-after"   // retryAfterHeader is the metadata key that tells clients how many seconds to wait before retrying a request that exceeded a quota.
+	quotaWindow      = time.Minute     // quotaWindow is the window over which JobsPerMinute is enforced.
+	concurrencyRetry = 5 * time.Second // concurrencyRetry is the suggested wait when a concurrency limit is reached, since it is not known when a job or stream will finish.
+)
+
+var (
+	// jobStartMethods are the RPCs that run jobs, which count towards the MaxRunningJobs and JobsPerMinute quotas. Jobs only count once they are submitted to run, so held jobs count when they are released rather than when they are started.
+	jobStartMethods = map[string]bool{
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobStart":   true,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobRelease": true,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobApprove": true,
+	}
+
+	// ownerChargedMethods are the job start methods that are charged to the owner of the job rather than to the caller, since the caller is not the one whose job runs.
+	ownerChargedMethods = map[string]bool{
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobApprove": true,
+	}
+
+	// logStreamMethods are the streaming RPCs that follow a job's output, which count towards the MaxLogStreams quota.
+	logStreamMethods = map[string]bool{
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobLogsStream": true,
+		"/" + pb.JobService_ServiceDesc.ServiceName + "/JobAttach":     true,
+	}
+)
+
+// QuotaLimiter enforces the quotas of users, as set in the authorization policy. Its interceptors must run after the authorization interceptors, since they rely on the user attached to the request context.
+type QuotaLimiter struct {
+	store      *worker.JobStore
+	policy     PolicyProvider         // policy provides the quotas of job owners, for the requests that are charged to them.
+	mu         *sync.Mutex            // mu controls access to the maps below.
+	starts     map[string][]time.Time // starts maps user ids to the times of the jobs they started within the last quota window.
+	starting   map[string]int         // starting maps user ids to the number of job start requests for their jobs that are in progress, which are not running yet but count towards MaxRunningJobs.
+	running    map[string]int         // running maps user ids to the number of jobs they started that are not done yet. Queued jobs count as running, so that one user can not fill the queue.
+	logStreams map[string]int         // logStreams maps user ids to the number of their open log streams.
+}
+
+// NewQuotaLimiter returns a new QuotaLimiter that looks up the jobs started through it in `store`, and the quotas of their owners in `policy`.
+func NewQuotaLimiter(store *worker.JobStore, policy PolicyProvider) *QuotaLimiter {
+	return &QuotaLimiter{store: store, policy: policy, mu: &sync.Mutex{}, starts: map[string][]time.Time{}, starting: map[string]int{}, running: map[string]int{}, logStreams: map[string]int{}}
+}
+
+// UnaryQuota is a unary gRPC interceptor that enforces the MaxRunningJobs and JobsPerMinute quotas on JobStart and JobRelease, and on JobApprove for the owner of the approved job.
+func (limiter *QuotaLimiter) UnaryQuota(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !jobStartMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "unable to get user") // internal server error since the interceptor should have set the user in context
+	}
+
+	if ownerChargedMethods[info.FullMethod] {
+		var ok bool
+		if user, ok = limiter.jobOwner(req); !ok {
+			return handler(ctx, req) // the handler reports jobs that do not exist or can not be approved. Owners that were removed from the policy have no quota left to enforce
+		}
+	}
+
+	startedAt, retryAfter, err := limiter.reserveStart(user)
+	if err != nil {
+		setRetryAfter(ctx, retryAfter)
+		return nil, err
+	}
+
+	res, err := handler(ctx, req)
+	limiter.releaseStart(user.Id, startedAt, limiter.startedJob(req, res, err))
+
+	return res, err
+}
+
+// StreamQuota is a server stream gRPC interceptor that enforces the MaxLogStreams quota on JobLogsStream and JobAttach.
+func (limiter *QuotaLimiter) StreamQuota(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !logStreamMethods[info.FullMethod] {
+		return handler(srv, ss)
+	}
+
+	user, err := GetUserFromContext(ss.Context())
+	if err != nil {
+		return status.Error(codes.Internal, "unable to get user") // internal server error since the interceptor should have set the user in context
+	}
+
+	err = limiter.openLogStream(user)
+	if err != nil {
+		setRetryAfter(ss.Context(), concurrencyRetry)
+		return err
+	}
+	defer limiter.closeLogStream(user.Id)
+
+	return handler(srv, ss)
+}
+
+// reserveStart checks that `user` can start another job, and records the start until the request is finished. It returns the time of the start, or how long the client should wait before retrying if it can not.
+func (limiter *QuotaLimiter) reserveStart(user *User) (time.Time, time.Duration, error) {
+	logger := log.WithFields(log.Fields{"func": "QuotaLimiter.reserveStart", "userId": user.Id})
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+
+	// forget starts that are outside of the window
+	starts := limiter.starts[user.Id]
+	for len(starts) > 0 && now.Sub(starts[0]) >= quotaWindow {
+		starts = starts[1:]
+	}
+	limiter.starts[user.Id] = starts
+	if len(starts) == 0 {
+		delete(limiter.starts, user.Id)
+	}
+
+	if limit := user.Quota.JobsPerMinute; limit > 0 && len(starts) >= limit {
+		logger.WithField("jobsPerMinute", limit).Debug("user exceeded their job start rate")
+		return time.Time{}, starts[len(starts)-limit].Add(quotaWindow).Sub(now), status.Errorf(codes.ResourceExhausted, "quota of %d jobs per minute exceeded", limit)
+	}
+
+	if limit := user.Quota.MaxRunningJobs; limit > 0 && limiter.running[user.Id]+limiter.starting[user.Id] >= limit {
+		logger.WithField("maxRunningJobs", limit).Debug("user reached their running jobs quota")
+		return time.Time{}, concurrencyRetry, status.Errorf(codes.ResourceExhausted, "quota of %d running jobs reached", limit)
+	}
+
+	limiter.starts[user.Id] = append(starts, now)
+	limiter.starting[user.Id]++
+
+	return now, 0, nil
+}
+
+// releaseStart marks the job start request for a job of user `userId` that reserved a start at `startedAt` as finished. If it started `job`, the job counts as running until it is done. Otherwise `job` is nil, and the start no longer counts towards the job start rate either.
+func (limiter *QuotaLimiter) releaseStart(userId string, startedAt time.Time, job *worker.Job) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.starting[userId]--
+	if limiter.starting[userId] == 0 {
+		delete(limiter.starting, userId)
+	}
+
+	if job == nil {
+		starts := limiter.starts[userId]
+		for i := len(starts) - 1; i >= 0; i-- {
+			if starts[i].Equal(startedAt) {
+				limiter.starts[userId] = append(starts[:i:i], starts[i+1:]...)
+				break
+			}
+		}
+		if len(limiter.starts[userId]) == 0 {
+			delete(limiter.starts, userId)
+		}
+		return
+	}
+
+	limiter.running[userId]++
+	go func() {
+		<-job.Done
+
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+
+		limiter.running[userId]--
+		if limiter.running[userId] == 0 {
+			delete(limiter.running, userId)
+		}
+	}()
+}
+
+// jobOwner returns the owner of the job that `req` refers to, if both still exist and the job is pending approval.
+func (limiter *QuotaLimiter) jobOwner(req interface{}) (*User, bool) {
+	jobReq, ok := req.(jobRequest)
+	if !ok || limiter.policy == nil {
+		return nil, false
+	}
+
+	job, err := limiter.store.LoadJobById(jobReq.GetJobId())
+	if err != nil || job.GetJobStatus() != pb.JobStatus_PENDING_APPROVAL {
+		return nil, false
+	}
+
+	return limiter.policy.GetPolicy().LookupUser(job.Key.UserId)
+}
+
+// startedJob returns the job that a successful job start request submitted to the scheduler, or nil if it failed, or left the job held or pending approval.
+func (limiter *QuotaLimiter) startedJob(req interface{}, res interface{}, err error) *worker.Job {
+	if err != nil {
+		return nil
+	}
+
+	// JobStart returns the id of the new job, and the other requests carry it
+	jobId := ""
+	if jobRes, ok := res.(jobRequest); ok {
+		jobId = jobRes.GetJobId()
+	}
+	if jobReq, ok := req.(jobRequest); ok && jobId == "" {
+		jobId = jobReq.GetJobId()
+	}
+
+	job, err := limiter.store.LoadJobById(jobId)
+	if err != nil {
+		return nil
+	}
+
+	if jobStatus := job.GetJobStatus(); jobStatus == pb.JobStatus_HELD || jobStatus == pb.JobStatus_PENDING_APPROVAL {
+		return nil
+	}
+
+	return job
+}
+
+// openLogStream checks that `user` can open another log stream, and records it.
+func (limiter *QuotaLimiter) openLogStream(user *User) error {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if limit := user.Quota.MaxLogStreams; limit > 0 && limiter.logStreams[user.Id] >= limit {
+		log.WithFields(log.Fields{"func": "QuotaLimiter.openLogStream", "userId": user.Id, "maxLogStreams": limit}).Debug("user reached their log streams quota")
+		return status.Errorf(codes.ResourceExhausted, "quota of %d log streams reached", limit)
+	}
+
+	limiter.logStreams[user.Id]++
+	return nil
+}
+
+// closeLogStream marks a log stream of user `userId` as closed.
+func (limiter *QuotaLimiter) closeLogStream(userId string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.logStreams[userId]--
+	if limiter.logStreams[userId] == 0 {
+		delete(limiter.logStreams, userId)
+	}
+}
+
+// setRetryAfter attaches the `retry-after` header to the response of the request with context `ctx`.
+func setRetryAfter(ctx context.Context, retryAfter time.Duration) {
+	err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterHeader, retryAfterSeconds(retryAfter)))
+	if err != nil {
+		log.WithField("func", "setRetryAfter").WithError(err).Debug("unable to set retry-after header")
+	}
+}
+
+// retryAfterSeconds formats `retryAfter` as a whole number of seconds, rounded up.
+func retryAfterSeconds(retryAfter time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+}
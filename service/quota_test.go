@@ -0,0 +1,226 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestQuotaConfig checks that users get the most permissive quota of their roles, unless they have a quota of their own.
+func TestQuotaConfig(t *testing.T) {
+	t.Parallel()
+
+	config := `
+quotas:
+  user: {max_running_jobs: 2, jobs_per_minute: 10}
+  operator: {max_running_jobs: 5, jobs_per_minute: 5, max_log_streams: 3}
+users:
+  - id: alice
+    clients: [{uid: 1000}]
+  - id: bob
+    roles: [user, operator]
+    clients: [{uid: 1001}]
+  - id: carol
+    roles: [user, admin]
+    clients: [{uid: 1002}]
+  - id: dave
+    quota: {max_running_jobs: 1}
+    clients: [{uid: 1003}]
+`
+	policy, err := service.ParsePolicy([]byte(config))
+	require.NoError(t, err)
+
+	expected := map[string]service.Quota{
+		"alice": {MaxRunningJobs: 2, JobsPerMinute: 10},
+		"bob":   {MaxRunningJobs: 5, JobsPerMinute: 10},
+		"carol": {},
+		"dave":  {MaxRunningJobs: 1},
+	}
+	for userId, quota := range expected {
+		user, ok := policy.LookupUser(userId)
+		require.True(t, ok)
+		require.Equal(t, quota, user.Quota, userId)
+	}
+
+	_, err = service.ParsePolicy([]byte("quotas:\n  root: {max_running_jobs: 1}\n"))
+	require.Error(t, err, "unknown role")
+	_, err = service.ParsePolicy([]byte("quotas:\n  user: {max_running_jobs: -1}\n"))
+	require.Error(t, err, "negative limit")
+}
+
+// TestQuotaLimiter checks that starting jobs and opening log streams beyond a user's quota fails with ResourceExhausted and a retry-after header.
+func TestQuotaLimiter(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(os.Getuid())
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Quota: &service.Quota{MaxRunningJobs: 1, JobsPerMinute: 2, MaxLogStreams: 1}, Clients: []service.ClientConfig{{UID: &uid}}},
+	}})
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+	quotas := service.NewQuotaLimiter(jobStore, authorizer)
+
+	client := unixClient(t, jobStore, grpc.ChainUnaryInterceptor(authorizer.UnaryAuth, quotas.UnaryQuota), grpc.ChainStreamInterceptor(authorizer.StreamAuth, quotas.StreamQuota))
+	ctx := context.Background()
+
+	start := func() (string, metadata.MD, error) {
+		header := metadata.MD{}
+		res, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sh", Args: []string{"-c", "while true; do echo tick; sleep 0.1; done"}}, grpc.Header(&header))
+		return res.GetJobId(), header, err
+	}
+
+	stop := func(jobId string) {
+		_, err := client.JobStop(ctx, &pb.JobStopRequest{JobId: jobId})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			res, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+			return err == nil && res.GetJobInfo().GetJobStatus() != pb.JobStatus_RUNNING
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// starts that fail count neither towards the running jobs nor towards the jobs per minute
+	for i := 0; i < 3; i++ {
+		_, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "/does/not/exist"})
+		require.Error(t, err)
+		require.NotEqual(t, codes.ResourceExhausted, status.Code(err))
+	}
+
+	// only one job can be running at a time
+	jobId, _, err := start()
+	require.NoError(t, err)
+
+	_, header, err := start()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, header.Get("retry-after"))
+
+	// only one log stream can be open at a time
+	streamCtx, cancel := context.WithCancel(ctx)
+	logStream, err := client.JobLogsStream(streamCtx, &pb.JobLogsRequest{JobId: jobId})
+	require.NoError(t, err)
+	_, err = logStream.Recv()
+	require.NoError(t, err)
+
+	secondStream, err := client.JobLogsStream(ctx, &pb.JobLogsRequest{JobId: jobId})
+	require.NoError(t, err)
+	_, err = secondStream.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	cancel()
+	require.Eventually(t, func() bool {
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := client.JobLogsStream(streamCtx, &pb.JobLogsRequest{JobId: jobId})
+		if err != nil {
+			return false
+		}
+		_, err = stream.Recv()
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "closed streams should not count towards the quota")
+
+	// only two jobs can be started per minute, whether or not they are still running
+	stop(jobId)
+	jobId, _, err = start()
+	require.NoError(t, err)
+	stop(jobId)
+
+	_, header, err = start()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, []string{"60"}, header.Get("retry-after"))
+}
+
+// TestQuotaHeldJobs checks that a held job counts once towards the jobs per minute, when it is released.
+func TestQuotaHeldJobs(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(os.Getuid())
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Quota: &service.Quota{JobsPerMinute: 1}, Clients: []service.ClientConfig{{UID: &uid}}},
+	}})
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+	quotas := service.NewQuotaLimiter(jobStore, authorizer)
+
+	client := unixClient(t, jobStore, grpc.ChainUnaryInterceptor(authorizer.UnaryAuth, quotas.UnaryQuota), grpc.ChainStreamInterceptor(authorizer.StreamAuth, quotas.StreamQuota))
+	ctx := context.Background()
+
+	res, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "true", Hold: true})
+	require.NoError(t, err)
+	_, err = client.JobRelease(ctx, &pb.JobReleaseRequest{JobId: res.GetJobId()})
+	require.NoError(t, err)
+
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "true"})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// TestJobQueue checks that jobs beyond the concurrency limit are queued with their position, that queued jobs count towards the MaxRunningJobs quota, and that they can be stopped.
+func TestJobQueue(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(os.Getuid())
+	policy, err := service.NewPolicy(service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "local", Quota: &service.Quota{MaxRunningJobs: 3}, Clients: []service.ClientConfig{{UID: &uid}}},
+	}})
+	require.NoError(t, err)
+
+	jobStore := worker.NewJobStore()
+	authorizer := service.NewAuthorizer(policy, jobStore)
+	quotas := service.NewQuotaLimiter(jobStore, authorizer)
+	jobServer := service.NewJobServer(jobStore)
+	jobServer.Scheduler = worker.NewScheduler(1)
+
+	client := serveUnix(t, jobServer, grpc.ChainUnaryInterceptor(authorizer.UnaryAuth, quotas.UnaryQuota), grpc.StreamInterceptor(authorizer.StreamAuth))
+	ctx := context.Background()
+
+	jobIds := []string{}
+	for i, expected := range []pb.JobStatus{pb.JobStatus_RUNNING, pb.JobStatus_QUEUED, pb.JobStatus_QUEUED} {
+		res, err := client.JobStart(ctx, &pb.JobStartRequest{Command: "sleep", Args: []string{"10"}})
+		require.NoError(t, err)
+		require.Equal(t, expected, res.GetJobStatus(), i)
+		jobIds = append(jobIds, res.GetJobId())
+	}
+
+	queuePosition := func(jobId string) uint32 {
+		res, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobId})
+		require.NoError(t, err)
+		return res.GetJobInfo().GetQueuePosition()
+	}
+	require.Equal(t, []uint32{0, 1, 2}, []uint32{queuePosition(jobIds[0]), queuePosition(jobIds[1]), queuePosition(jobIds[2])})
+
+	// queued jobs count towards the quota, so that one user can not fill the queue
+	_, err = client.JobStart(ctx, &pb.JobStartRequest{Command: "sleep", Args: []string{"10"}})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// a stopped queued job is never started, and the job behind it moves up
+	_, err = client.JobStop(ctx, &pb.JobStopRequest{JobId: jobIds[1]})
+	require.NoError(t, err)
+	res, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobIds[1]})
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_STOPPED, res.GetJobInfo().GetJobStatus())
+	require.Equal(t, uint32(1), queuePosition(jobIds[2]))
+
+	// the next job is started once the running job is stopped
+	_, err = client.JobStop(ctx, &pb.JobStopRequest{JobId: jobIds[0]})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		res, err := client.JobStatus(ctx, &pb.JobStatusRequest{JobId: jobIds[2]})
+		return err == nil && res.GetJobInfo().GetJobStatus() == pb.JobStatus_RUNNING && res.GetJobInfo().GetQueuePosition() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = client.JobStop(ctx, &pb.JobStopRequest{JobId: jobIds[2]})
+	require.NoError(t, err)
+}
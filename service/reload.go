@@ -0,0 +1,78 @@
+package service
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadPolicy loads the authorization config at `path` and swaps it in. If the config cannot be loaded or is invalid, the previous policy is left in place and an error is returned.
+func (auth *Authorizer) ReloadPolicy(path string) error {
+	logger := log.WithFields(log.Fields{"func": "Authorizer.ReloadPolicy", "path": path})
+
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		logger.WithError(err).Error("unable to reload authorization config, keeping the previous policy")
+		return err
+	}
+
+	auth.SetPolicy(policy)
+	logger.Info("reloaded authorization config")
+
+	return nil
+}
+
+// WatchPolicy reloads the authorization config at `path` every time it is changed on disk, until `done` is closed. The parent directory is watched rather than the file itself, so that editors and tools that replace the file by renaming over it are also picked up.
+func (auth *Authorizer) WatchPolicy(done <-chan struct{}, path string) error {
+	logger := log.WithFields(log.Fields{"func": "Authorizer.WatchPolicy", "path": path})
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.WithError(err).Error("unable to initialize a new watcher")
+		return err
+	}
+
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		logger.WithError(err).Error("unable to watch config directory")
+
+		wErr := watcher.Close()
+		if wErr != nil {
+			logger.WithError(wErr).Error("unable to close watcher")
+		}
+
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+
+				logger.WithField("event", event).Debug("authorization config changed")
+				_ = auth.ReloadPolicy(path) // errors are logged, and the previous policy is kept
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.WithError(err).Error("encountered a watcher error")
+
+			case <-done:
+				logger.Debug("done signal received")
+				return
+			}
+		}
+	}()
+
+	return nil
+}
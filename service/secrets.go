@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	secretKeySize    = 32                       // secretKeySize is the size of the master key of a secret store, which encrypts it with AES-256-GCM.
+	secretStoreMagic = "worker-secret-store-v1" // secretStoreMagic starts every secret store file, and is authenticated along with its contents.
+)
+
+var (
+	ErrSecretDoesNotExist = errors.New("secret does not exist")
+	ErrSecretInvalidName  = errors.New("secret names can only contain letters, digits, dots, dashes and underscores, and must start with a letter or digit")
+
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`) // secretNamePattern matches valid names of secrets and of secret files.
+)
+
+// SecretStore is a file of named secrets on disk, encrypted with AES-256-GCM under a master key. The file is read on every access, so that changes made with `worker-server secrets` apply to running servers.
+type SecretStore struct {
+	path string
+	aead cipher.AEAD
+	mu   *sync.Mutex // mu serializes changes to the file.
+}
+
+// NewSecretStore returns a SecretStore for the file at `path`, encrypted with the 32 byte master key `key`. The file does not need to exist yet.
+func NewSecretStore(path string, key []byte) (*SecretStore, error) {
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("secret store key must be %d bytes", secretKeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretStore{path: path, aead: aead, mu: &sync.Mutex{}}, nil
+}
+
+// LoadSecretStore reads the master key at `keyPath`, which holds 32 random bytes either raw or base64-encoded (e.g. generated by `openssl rand -base64 32`), and returns a SecretStore for the file at `path`.
+func LoadSecretStore(path string, keyPath string) (*SecretStore, error) {
+	key, err := readKeyFile(keyPath, secretKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewSecretStore(path, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	return store, nil
+}
+
+// readKeyFile reads a key of `size` bytes at `path`, which holds it either raw or base64-encoded. The key is returned as is if it is neither, for the caller to reject.
+func readKeyFile(path string, size int) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil && len(decoded) == size {
+		return decoded, nil
+	}
+
+	return data, nil
+}
+
+// Get returns the value of the secret `name`, or ErrSecretDoesNotExist.
+func (store *SecretStore) Get(name string) ([]byte, error) {
+	secrets, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := secrets[name]
+	if !ok {
+		return nil, ErrSecretDoesNotExist
+	}
+
+	return value, nil
+}
+
+// Names returns the names of all secrets in the store, in order.
+func (store *SecretStore) Names() ([]string, error) {
+	secrets, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Set adds the secret `name` to the store, or replaces its value.
+func (store *SecretStore) Set(name string, value []byte) error {
+	if !secretNamePattern.MatchString(name) {
+		return ErrSecretInvalidName
+	}
+
+	return store.update(func(secrets map[string][]byte) error {
+		secrets[name] = value
+		return nil
+	})
+}
+
+// Delete removes the secret `name` from the store, or returns ErrSecretDoesNotExist.
+func (store *SecretStore) Delete(name string) error {
+	return store.update(func(secrets map[string][]byte) error {
+		if _, ok := secrets[name]; !ok {
+			return ErrSecretDoesNotExist
+		}
+		delete(secrets, name)
+		return nil
+	})
+}
+
+// update applies `change` to the secrets in the store, and writes them back if it succeeds. The file is replaced atomically, so that readers never see a partially written store.
+func (store *SecretStore) update(change func(secrets map[string][]byte) error) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	secrets, err := store.load()
+	if err != nil {
+		return err
+	}
+	if err := change(secrets); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, store.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := append([]byte(secretStoreMagic), nonce...)
+	data = store.aead.Seal(data, nonce, plaintext, []byte(secretStoreMagic))
+
+	file, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), store.path)
+}
+
+// load decrypts the secrets in the store. A store whose file does not exist is empty.
+func (store *SecretStore) load() (map[string][]byte, error) {
+	secrets := map[string][]byte{}
+
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := store.aead.NonceSize()
+	if !bytes.HasPrefix(data, []byte(secretStoreMagic)) || len(data) < len(secretStoreMagic)+nonceSize {
+		return nil, fmt.Errorf("%s is not a secret store", store.path)
+	}
+	data = data[len(secretStoreMagic):]
+
+	plaintext, err := store.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(secretStoreMagic))
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to decrypt secret store, the key may be wrong: %w", store.path, err)
+	}
+
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("%s: %w", store.path, err)
+	}
+
+	return secrets, nil
+}
+
+// SecretRuleConfig grants users access to secrets of the secret store, in the authorization config. Users can only reference the secrets that a rule grants them.
+type SecretRuleConfig struct {
+	Names []string `yaml:"names"` // Names are glob patterns (as in path.Match) of the names of the secrets that the rule grants access to.
+	Roles []Role   `yaml:"roles"` // Roles are the roles that the rule applies to.
+	Users []string `yaml:"users"` // Users are the ids of users that the rule applies to, regardless of their roles.
+}
+
+// validate returns an error if the rule has no names, or an invalid pattern or unknown role.
+func (config SecretRuleConfig) validate() error {
+	if len(config.Names) == 0 {
+		return errors.New("at least one name is required")
+	}
+	for _, pattern := range config.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("name %q: %w", pattern, err)
+		}
+	}
+	for _, role := range config.Roles {
+		if !knownRoles[role] {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	return nil
+}
+
+// grants returns true if the rule grants `user` access to the secret `name`.
+func (config SecretRuleConfig) grants(user *User, name string) bool {
+	applies := false
+	for _, userId := range config.Users {
+		applies = applies || userId == user.Id
+	}
+	for _, role := range config.Roles {
+		for _, userRole := range user.Roles {
+			applies = applies || role == userRole
+		}
+	}
+	if !applies {
+		return false
+	}
+
+	for _, pattern := range config.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanUseSecret returns true if any secret rule grants `user` access to the secret `name`.
+func (policy *Policy) CanUseSecret(user *User, name string) bool {
+	for _, rule := range policy.secrets {
+		if rule.grants(user, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveSecrets validates the secret references of a job started by `user` with the environment variables `env`, checks that the user can use them, and reads their values. It returns a gRPC status error.
+func (server *JobServer) resolveSecrets(user *User, refs []*pb.SecretRef, env map[string]string) ([]worker.Secret, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	if server.Secrets == nil {
+		return nil, status.Error(codes.FailedPrecondition, "secrets are not enabled")
+	}
+
+	secrets := []worker.Secret{}
+	targets := map[string]bool{} // targets are the environment variables and files that are already set
+	for _, ref := range refs {
+		name, envName, file := ref.GetName(), ref.GetEnv(), ref.GetFile()
+		if !secretNamePattern.MatchString(name) {
+			return nil, status.Error(codes.InvalidArgument, ErrSecretInvalidName.Error())
+		}
+		if (envName == "") == (file == "") {
+			return nil, status.Errorf(codes.InvalidArgument, "secret %s must set exactly one of env and file", name)
+		}
+
+		target := "env " + envName
+		if envName != "" {
+			if err := validateEnv(map[string]string{envName: ""}); err != nil || envName == worker.SecretsEnv {
+				return nil, status.Errorf(codes.InvalidArgument, "secret %s can not be set as environment variable %q", name, envName)
+			}
+		} else {
+			if !secretNamePattern.MatchString(file) {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid secret file name %q", file)
+			}
+			target = "file " + file
+		}
+		if _, ok := env[envName]; ok || targets[target] {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is set more than once", target)
+		}
+		targets[target] = true
+
+		if server.Policy == nil || !server.Policy.GetPolicy().CanUseSecret(user, name) {
+			return nil, status.Errorf(codes.PermissionDenied, "user can not use secret %s", name)
+		}
+
+		value, err := server.Secrets.Get(name)
+		if errors.Is(err, ErrSecretDoesNotExist) {
+			return nil, status.Errorf(codes.NotFound, "secret %s does not exist", name)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{"func": "JobServer.resolveSecrets", "secret": name}).WithError(err).Error("unable to read secret")
+			return nil, status.Error(codes.Internal, "unable to read secret")
+		}
+
+		secrets = append(secrets, worker.Secret{Name: name, Env: envName, File: file, Value: value})
+	}
+
+	return secrets, nil
+}
+
+// secretRefs converts the secrets of a job, without their values, to their API representation.
+func secretRefs(secrets []worker.Secret) []*pb.SecretRef {
+	refs := []*pb.SecretRef{}
+	for _, secret := range secrets {
+		refs = append(refs, &pb.SecretRef{Name: secret.Name, Env: secret.Env, File: secret.File})
+	}
+
+	return refs
+}
@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestSecretStore checks that secrets can be set, read, listed and deleted, that they are encrypted on disk, and that a store can not be read with the wrong key.
+func TestSecretStore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets")
+	keyPath := filepath.Join(dir, "secrets.key")
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))+"\n"), 0600))
+
+	store, err := service.LoadSecretStore(path, keyPath)
+	require.NoError(t, err)
+	names, err := store.Names()
+	require.NoError(t, err)
+	require.Empty(t, names, "a store without a file is empty")
+
+	require.NoError(t, store.Set("b", []byte("s3cret")))
+	require.NoError(t, store.Set("a", []byte("other")))
+	require.ErrorIs(t, store.Set("../a", []byte("value")), service.ErrSecretInvalidName)
+
+	value, err := store.Get("b")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", string(value))
+	names, err = store.Names()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, names)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "s3cret")
+
+	require.NoError(t, store.Delete("b"))
+	_, err = store.Get("b")
+	require.ErrorIs(t, err, service.ErrSecretDoesNotExist)
+	require.ErrorIs(t, store.Delete("b"), service.ErrSecretDoesNotExist)
+
+	otherStore, err := service.NewSecretStore(path, bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+	_, err = otherStore.Get("a")
+	require.Error(t, err)
+
+	_, err = service.NewSecretStore(path, []byte("short"))
+	require.Error(t, err)
+}
+
+// TestJobSecrets checks that jobs can only reference the secrets that the policy grants their owner, and that secret values are never reported in job info.
+func TestJobSecrets(t *testing.T) {
+	t.Parallel()
+
+	config := service.PolicyConfig{
+		Users: []service.UserConfig{
+			{Id: "client1", Clients: []service.ClientConfig{clientConfig("Client 1")}},
+			{Id: "client2", Clients: []service.ClientConfig{clientConfig("Client 2")}},
+		},
+		Secrets: []service.SecretRuleConfig{{Names: []string{"ci-*"}, Users: []string{"client1"}}},
+	}
+	policy, err := service.NewPolicy(config)
+	require.NoError(t, err)
+
+	store, err := service.NewSecretStore(filepath.Join(t.TempDir(), "secrets"), bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	require.NoError(t, store.Set("ci-token", []byte("s3cret")))
+	require.NoError(t, store.Set("prod-token", []byte("other")))
+
+	jobStore := worker.NewJobStore()
+	auth := service.NewAuthorizer(policy, jobStore)
+	jobServer := service.NewJobServer(jobStore)
+	jobServer.Policy = auth
+	jobServer.Secrets = store
+
+	jobStart := func(certPath string, req *pb.JobStartRequest) (*pb.JobStartResponse, error) {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.JobService_ServiceDesc.ServiceName + "/JobStart"}
+		res, err := auth.UnaryAuth(peerContext(t, certPath), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return jobServer.JobStart(ctx, req.(*pb.JobStartRequest))
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res.(*pb.JobStartResponse), nil
+	}
+
+	refs := []*pb.SecretRef{{Name: "ci-token", Env: "TOKEN"}}
+	res, err := jobStart("../certs/client1/cert.pem", &pb.JobStartRequest{Command: "sh", Args: []string{"-c", `echo "$TOKEN"`}, Secrets: refs})
+	require.NoError(t, err)
+	job, err := jobStore.LoadJobById(res.GetJobId())
+	require.NoError(t, err)
+	<-job.Done
+	data, err := ioutil.ReadFile(job.LogFilepath())
+	require.NoError(t, err)
+	require.Equal(t, "s3cret\n", string(data))
+	require.Equal(t, []worker.Secret{{Name: "ci-token", Env: "TOKEN"}}, job.SecretRefs())
+
+	testCases := map[string]struct {
+		certPath string
+		req      *pb.JobStartRequest
+		code     codes.Code
+	}{
+		"not granted to user":   {"../certs/client2/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: refs}, codes.PermissionDenied},
+		"not granted to secret": {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "prod-token", Env: "TOKEN"}}}, codes.PermissionDenied},
+		"missing secret":        {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "ci-other", Env: "TOKEN"}}}, codes.NotFound},
+		"no target":             {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "ci-token"}}}, codes.InvalidArgument},
+		"env and file":          {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "ci-token", Env: "TOKEN", File: "token"}}}, codes.InvalidArgument},
+		"file outside dir":      {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "ci-token", File: "../token"}}}, codes.InvalidArgument},
+		"env set twice":         {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Env: map[string]string{"TOKEN": "a"}, Secrets: refs}, codes.InvalidArgument},
+		"variable of server":    {"../certs/client1/cert.pem", &pb.JobStartRequest{Command: "env", Secrets: []*pb.SecretRef{{Name: "ci-token", Env: worker.SecretsEnv}}}, codes.InvalidArgument},
+	}
+	for name, testCase := range testCases {
+		_, err := jobStart(testCase.certPath, testCase.req)
+		require.Equal(t, testCase.code, status.Code(err), name)
+	}
+
+	// rules must be valid
+	for _, rule := range []service.SecretRuleConfig{{Users: []string{"client1"}}, {Names: []string{"["}}, {Names: []string{"a"}, Roles: []service.Role{"superuser"}}, {Names: []string{"a"}, Users: []string{"nobody"}}} {
+		config.Secrets = []service.SecretRuleConfig{rule}
+		_, err := service.NewPolicy(config)
+		require.Error(t, err, rule)
+	}
+}
@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"sync"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // ServerStreamWithContext implements grpc.ServerStream, and allows one to replace the context.
@@ -21,3 +26,56 @@ func (sswc *ServerStreamWithContext) Context() context.Context {
 func NewServerStreamWithContext(ctx context.Context, ss grpc.ServerStream) *ServerStreamWithContext {
 	return &ServerStreamWithContext{ServerStream: ss, ctx: ctx}
 }
+
+// authorizedServerStream re-authorizes the client against the current policy before every message, so that a policy reload can cut off streams that are already open. Received requests that refer to a job are checked against the scope of the client's permission, and the job is checked again before every message that is sent.
+type authorizedServerStream struct {
+	*ServerStreamWithContext
+	auth   *Authorizer
+	method string      // method is the full RPC method name of the stream.
+	userId string      // userId is the user that the client was mapped to when the stream was opened.
+	mu     *sync.Mutex // mu controls access to `req`, since messages can be sent and received from different goroutines.
+	req    interface{} // req is the last request received from the client, or nil if none was received yet.
+}
+
+// SendMsg checks that the client is still authorized, and then sends a message.
+func (stream *authorizedServerStream) SendMsg(m interface{}) error {
+	stream.mu.Lock()
+	req := stream.req
+	stream.mu.Unlock()
+
+	if err := stream.reauthorize(req); err != nil {
+		return err
+	}
+	return stream.ServerStreamWithContext.SendMsg(m)
+}
+
+// RecvMsg receives a message, and then checks that the client is authorized to send it.
+func (stream *authorizedServerStream) RecvMsg(m interface{}) error {
+	if err := stream.ServerStreamWithContext.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if err := stream.reauthorize(m); err != nil {
+		return err
+	}
+	stream.mu.Lock()
+	stream.req = m
+	stream.mu.Unlock()
+
+	return nil
+}
+
+// reauthorize returns an error if the client is no longer authorized to make request `req`, or is now mapped to a different user.
+func (stream *authorizedServerStream) reauthorize(req interface{}) error {
+	user, _, err := stream.auth.authorize(stream.ServerStream.Context(), stream.method, req)
+	if err != nil {
+		return err
+	}
+
+	if user.Id != stream.userId {
+		log.WithFields(log.Fields{"func": "authorizedServerStream.reauthorize", "userId": stream.userId, "newUserId": user.Id}).Debug("client was mapped to a different user")
+		return status.Error(codes.PermissionDenied, "client is not authorized to access resource")
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	tokenAlgorithmHMAC    = "HS256" // tokenAlgorithmHMAC signs tokens with HMAC-SHA256.
+	tokenAlgorithmEd25519 = "EdDSA" // tokenAlgorithmEd25519 signs tokens with Ed25519.
+
+	authorizationHeader = "authorization"
+	bearerPrefix        = "Bearer "
+	minTokenSecretSize  = 32 // minTokenSecretSize is the minimum size of HMAC secrets, in bytes.
+)
+
+var (
+	ErrTokenInvalid = errors.New("token is malformed or its signature is invalid")
+	ErrTokenExpired = errors.New("token has expired")
+)
+
+// tokenHeader is the JOSE header of a token.
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// tokenClaims are the claims of a token. The subject is the id of the user that the token was issued to.
+type tokenClaims struct {
+	Id         string `json:"jti"`
+	Subject    string `json:"sub"`
+	IssuedAt   int64  `json:"iat"`
+	ExpiresAt  int64  `json:"exp"`
+	CertIssuer string `json:"cert_iss,omitempty"` // CertIssuer is the base64url encoded raw issuer of the client certificate that the token was issued with, if any.
+	CertSerial string `json:"cert_sn,omitempty"`  // CertSerial is the serial number of that certificate, in base 10.
+}
+
+// Token is the identity carried by a verified token.
+type Token struct {
+	UserId     string   // UserId is the id of the user that the token was issued to.
+	CertIssuer []byte   // CertIssuer is the raw issuer of the client certificate that the token was issued with, or nil if it was not issued with one, e.g. over the Unix socket.
+	CertSerial *big.Int // CertSerial is the serial number of that certificate, so that the token stops working when the certificate is revoked.
+}
+
+// TokenAuthority issues and verifies short-lived bearer tokens, which are JWTs signed with either an HMAC secret or an Ed25519 key. Tokens only carry the user id: the user's roles and groups are looked up in the current authorization policy whenever the token is used, so policy changes also apply to tokens that were already issued.
+type TokenAuthority struct {
+	algorithm  string
+	secret     []byte             // secret is the HMAC secret, if the algorithm is HS256.
+	privateKey ed25519.PrivateKey // privateKey is the signing key, if the algorithm is EdDSA.
+	maxTTL     time.Duration      // maxTTL is the maximum lifetime of issued tokens.
+}
+
+// NewHMACTokenAuthority returns a TokenAuthority that signs tokens with HMAC-SHA256 using `secret`, which must be at least 32 bytes.
+func NewHMACTokenAuthority(secret []byte, maxTTL time.Duration) (*TokenAuthority, error) {
+	if len(secret) < minTokenSecretSize {
+		return nil, fmt.Errorf("token secret must be at least %d bytes", minTokenSecretSize)
+	}
+
+	return &TokenAuthority{algorithm: tokenAlgorithmHMAC, secret: secret, maxTTL: maxTTL}, nil
+}
+
+// NewEd25519TokenAuthority returns a TokenAuthority that signs tokens with `privateKey`.
+func NewEd25519TokenAuthority(privateKey ed25519.PrivateKey, maxTTL time.Duration) *TokenAuthority {
+	return &TokenAuthority{algorithm: tokenAlgorithmEd25519, privateKey: privateKey, maxTTL: maxTTL}
+}
+
+// LoadTokenAuthority loads the token signing key at `keyPath`. A PEM encoded Ed25519 private key (e.g. generated by `openssl genpkey -algorithm ed25519`) signs tokens with EdDSA, and any other file is used as an HMAC-SHA256 secret.
+func LoadTokenAuthority(keyPath string, maxTTL time.Duration) (*TokenAuthority, error) {
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		tokens, err := NewHMACTokenAuthority(bytes.TrimRight(data, "\r\n"), maxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyPath, err)
+		}
+		return tokens, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: only Ed25519 keys are supported", keyPath)
+	}
+
+	return NewEd25519TokenAuthority(privateKey, maxTTL), nil
+}
+
+// Issue issues a token for the user with id `userId` that expires after `ttl`. If `ttl` is not positive or exceeds the authority's maximum lifetime, the maximum lifetime is used instead. If the user authenticated with client certificate `cert`, the token records it, so that it can be checked against the CRL; otherwise `cert` is nil.
+func (tokens *TokenAuthority) Issue(userId string, cert *x509.Certificate, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 || ttl > tokens.maxTTL {
+		ttl = tokens.maxTTL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	header, err := json.Marshal(tokenHeader{Algorithm: tokens.algorithm, Type: "JWT"})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := tokenClaims{Id: uuid.New().String(), Subject: userId, IssuedAt: now.Unix(), ExpiresAt: expiresAt.Unix()}
+	if cert != nil {
+		claims.CertIssuer = base64.RawURLEncoding.EncodeToString(cert.RawIssuer)
+		claims.CertSerial = cert.SerialNumber.String()
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature := base64.RawURLEncoding.EncodeToString(tokens.sign([]byte(signingInput)))
+
+	return signingInput + "." + signature, time.Unix(expiresAt.Unix(), 0), nil
+}
+
+// Verify checks the signature and expiry of `token`, and returns who it was issued to.
+func (tokens *TokenAuthority) Verify(token string) (*Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenInvalid
+	}
+
+	// the algorithm is fixed by the authority rather than taken from the token, so that tokens can not choose a weaker algorithm
+	header := tokenHeader{}
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Algorithm != tokens.algorithm {
+		return nil, ErrTokenInvalid
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !tokens.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrTokenInvalid
+	}
+
+	claims := tokenClaims{}
+	if err := decodeTokenPart(parts[1], &claims); err != nil || claims.Subject == "" {
+		return nil, ErrTokenInvalid
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	verified := &Token{UserId: claims.Subject}
+	if claims.CertSerial != "" {
+		serial, ok := new(big.Int).SetString(claims.CertSerial, 10)
+		if !ok {
+			return nil, ErrTokenInvalid
+		}
+		verified.CertIssuer, err = base64.RawURLEncoding.DecodeString(claims.CertIssuer)
+		if err != nil {
+			return nil, ErrTokenInvalid
+		}
+		verified.CertSerial = serial
+	}
+
+	return verified, nil
+}
+
+// sign signs `signingInput` with the authority's key.
+func (tokens *TokenAuthority) sign(signingInput []byte) []byte {
+	if tokens.algorithm == tokenAlgorithmEd25519 {
+		return ed25519.Sign(tokens.privateKey, signingInput)
+	}
+
+	mac := hmac.New(sha256.New, tokens.secret)
+	mac.Write(signingInput)
+	return mac.Sum(nil)
+}
+
+// verify checks that `signature` is a valid signature of `signingInput` by the authority's key.
+func (tokens *TokenAuthority) verify(signingInput []byte, signature []byte) bool {
+	if tokens.algorithm == tokenAlgorithmEd25519 {
+		return ed25519.Verify(tokens.privateKey.Public().(ed25519.PublicKey), signingInput, signature)
+	}
+
+	return hmac.Equal(tokens.sign(signingInput), signature)
+}
+
+// decodeTokenPart decodes a base64url encoded JSON part of a token into `v`.
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// tokenFromContext extracts the bearer token from the `authorization` metadata of a request context. It returns false if no token was passed.
+func tokenFromContext(ctx context.Context) (string, bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false, nil
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return "", false, nil
+	}
+	if len(values) > 1 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return "", false, errors.New("authorization metadata is not a single bearer token")
+	}
+
+	return strings.TrimPrefix(values[0], bearerPrefix), true, nil
+}
+
+// TokenCredentials attaches a bearer token to every request. It implements credentials.PerRPCCredentials.
+type TokenCredentials struct {
+	Token string
+}
+
+// GetRequestMetadata returns the `authorization` metadata carrying the token.
+func (creds TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: bearerPrefix + creds.Token}, nil
+}
+
+// RequireTransportSecurity returns true, since tokens must not be sent in plain text.
+func (creds TokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
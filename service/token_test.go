@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/service"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokenContext returns a context that looks like it belongs to a request with bearer token `token`, over a connection without a client certificate.
+func tokenContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// TestTokenAuthority checks that tokens are only accepted by the authority that issued them, until they expire.
+func TestTokenAuthority(t *testing.T) {
+	t.Parallel()
+
+	_, err := service.NewHMACTokenAuthority([]byte("too short"), time.Hour)
+	require.Error(t, err)
+
+	hmacTokens, err := service.NewHMACTokenAuthority([]byte(strings.Repeat("s", 32)), time.Hour)
+	require.NoError(t, err)
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ed25519Tokens := service.NewEd25519TokenAuthority(privateKey, time.Hour)
+
+	for _, tokens := range []*service.TokenAuthority{hmacTokens, ed25519Tokens} {
+		token, expiresAt, err := tokens.Issue("client1", nil, 2*time.Hour)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 5*time.Second, "ttl should be capped to the maximum")
+
+		verified, err := tokens.Verify(token)
+		require.NoError(t, err)
+		require.Equal(t, &service.Token{UserId: "client1"}, verified)
+
+		// tampered claims
+		parts := strings.Split(token, ".")
+		_, err = tokens.Verify(parts[0] + "." + parts[1] + "x." + parts[2])
+		require.ErrorIs(t, err, service.ErrTokenInvalid)
+
+		// expired tokens
+		token, _, err = tokens.Issue("client1", nil, time.Nanosecond)
+		require.NoError(t, err)
+		time.Sleep(time.Second)
+		_, err = tokens.Verify(token)
+		require.ErrorIs(t, err, service.ErrTokenExpired)
+	}
+
+	// tokens issued by another authority, or with another algorithm, are rejected
+	token, _, err := hmacTokens.Issue("client1", nil, time.Hour)
+	require.NoError(t, err)
+	_, err = ed25519Tokens.Verify(token)
+	require.ErrorIs(t, err, service.ErrTokenInvalid)
+
+	otherTokens, err := service.NewHMACTokenAuthority([]byte(strings.Repeat("o", 32)), time.Hour)
+	require.NoError(t, err)
+	_, err = otherTokens.Verify(token)
+	require.ErrorIs(t, err, service.ErrTokenInvalid)
+}
+
+// TestTokenAuthentication checks that the interceptor maps tokens to the same users and roles as client certificates, and that tokens can not be used to issue tokens.
+func TestTokenAuthentication(t *testing.T) {
+	t.Parallel()
+
+	config := service.PolicyConfig{Users: []service.UserConfig{
+		{Id: "viewer", Roles: []service.Role{service.RoleViewer}, Clients: []service.ClientConfig{clientConfig("Client 2")}},
+	}}
+	policy, err := service.NewPolicy(config)
+	require.NoError(t, err)
+
+	auth := service.NewAuthorizer(policy, worker.NewJobStore())
+	tokens, err := service.NewHMACTokenAuthority([]byte(strings.Repeat("s", 32)), time.Hour)
+	require.NoError(t, err)
+	token, _, err := tokens.Issue("viewer", nil, time.Hour)
+	require.NoError(t, err)
+
+	call := func(ctx context.Context, method string, req interface{}) codes.Code {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.JobService_ServiceDesc.ServiceName + "/" + method}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			user, err := service.GetUserFromContext(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "viewer", user.Id)
+			return nil, nil
+		}
+
+		_, err := auth.UnaryAuth(ctx, req, info, handler)
+		return status.Code(err)
+	}
+
+	// tokens are rejected until token authentication is enabled
+	require.Equal(t, codes.Unauthenticated, call(tokenContext(token), "JobList", &pb.JobListRequest{}))
+	auth.SetTokenAuthority(tokens)
+
+	require.Equal(t, codes.OK, call(tokenContext(token), "JobList", &pb.JobListRequest{}))
+	require.Equal(t, codes.PermissionDenied, call(tokenContext(token), "JobStart", &pb.JobStartRequest{}), "tokens should carry the user's roles")
+	require.Equal(t, codes.PermissionDenied, call(tokenContext(token), "TokenIssue", &pb.TokenIssueRequest{}), "tokens should not be able to issue tokens")
+	require.Equal(t, codes.OK, call(peerContext(t, "../certs/client2/cert.pem"), "TokenIssue", &pb.TokenIssueRequest{}))
+	require.Equal(t, codes.Unauthenticated, call(tokenContext(token+"x"), "JobList", &pb.JobListRequest{}))
+	require.Equal(t, codes.Unauthenticated, call(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic dXNlcg==")), "JobList", &pb.JobListRequest{}))
+
+	// users removed from the policy lose access with their tokens too
+	config.Users[0].Id = "another"
+	policy, err = service.NewPolicy(config)
+	require.NoError(t, err)
+	auth.SetPolicy(policy)
+	require.Equal(t, codes.PermissionDenied, call(tokenContext(token), "JobList", &pb.JobListRequest{}))
+}
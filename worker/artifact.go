@@ -0,0 +1,290 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxArtifacts = 1024 // maxArtifacts is the largest number of artifacts that are collected from a job.
+)
+
+var (
+	ErrArtifactDoesNotExist = errors.New("the artifact does not exist")
+
+	errStopCollecting = errors.New("no more artifacts can be collected") // errStopCollecting stops the walk over a job's workspace once no more artifacts can be collected from it.
+)
+
+// Artifact is a file that was collected from a job's workspace when the job was done.
+type Artifact struct {
+	Name   string      // Name is the path of the file relative to the job's workspace.
+	Size   int64       // Size is the size of the file in bytes.
+	Mode   os.FileMode // Mode are the permission bits of the file.
+	Digest string      // Digest is the hex-encoded SHA-256 checksum of the file, by which it is kept in the artifact store.
+}
+
+// ArtifactStore keeps the artifacts of jobs by their content, so that they outlive the jobs' workspaces and identical files are only stored once.
+type ArtifactStore struct {
+	MaxJobSize int64         // MaxJobSize is the largest total size in bytes of the artifacts collected from one job, or zero for no limit.
+	TTL        time.Duration // TTL is how long artifacts are kept after they were last collected, or zero to keep them until the store is deleted.
+
+	directory   string      // directory is where the artifacts are kept, in sha256/<digest>.
+	maxFileSize int64       // maxFileSize is the size in bytes of the largest file that is collected as an artifact.
+	mu          *sync.Mutex // mu keeps artifacts from being pruned while they are stored again.
+}
+
+// NewArtifactStore returns an ArtifactStore that keeps artifacts of at most `maxFileSize` bytes in `directory`.
+func NewArtifactStore(directory string, maxFileSize int64) *ArtifactStore {
+	return &ArtifactStore{directory: directory, maxFileSize: maxFileSize, mu: &sync.Mutex{}}
+}
+
+// Open opens the artifact with hex-encoded SHA-256 checksum `digest` for reading. It returns ErrArtifactDoesNotExist if the store does not hold it.
+func (store *ArtifactStore) Open(digest string) (*os.File, error) {
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return nil, ErrArtifactDoesNotExist
+	}
+
+	file, err := os.Open(store.path(digest))
+	if os.IsNotExist(err) {
+		return nil, ErrArtifactDoesNotExist
+	}
+
+	return file, err
+}
+
+// Prune removes the artifacts that were not collected again within the store's TTL, and temporary files that were left behind. Jobs whose artifacts were removed can no longer download them. It does nothing if the TTL is zero.
+func (store *ArtifactStore) Prune() error {
+	if store.TTL == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(store.directory, "sha256")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// artifacts that are stored again are replaced, which resets their modification time
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Lstat(path)
+		if err != nil || time.Since(info.ModTime()) < store.TTL {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PruneEvery prunes the store every `interval` in the background, until `done` is closed.
+func (store *ArtifactStore) PruneEvery(done <-chan struct{}, interval time.Duration) {
+	logger := log.WithFields(log.Fields{"func": "ArtifactStore.PruneEvery", "directory": store.directory})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := store.Prune(); err != nil {
+					logger.WithError(err).Error("unable to prune artifacts")
+				}
+
+			case <-done:
+				logger.Debug("done signal received")
+				return
+			}
+		}
+	}()
+}
+
+// put copies at most `maxSize` bytes of the contents of `reader` into the store, and returns their digest and size. It fails if `reader` holds more.
+func (store *ArtifactStore) put(reader io.Reader, maxSize int64) (string, int64, error) {
+	dir := filepath.Join(store.directory, "sha256")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", 0, err
+	}
+
+	// the artifact is written to a temporary file first, since its digest is only known at the end
+	file, err := ioutil.TempFile(dir, ".artifact-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > maxSize {
+		return "", 0, errors.New("the file is larger than the largest artifact, or than what is left of the job's artifact budget")
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(file.Name(), store.path(digest)); err != nil {
+		return "", 0, err
+	}
+
+	return digest, size, nil
+}
+
+// path returns the path of the artifact with digest `digest`.
+func (store *ArtifactStore) path(digest string) string {
+	return filepath.Join(store.directory, "sha256", digest)
+}
+
+// WithArtifacts collects the regular files in the job's workspace whose relative path matches one of `patterns` into `store` when the job is done, before its workspace is cleaned up. They are collected whether the job succeeded, failed or was stopped, so that the outputs of failed jobs can be inspected. Patterns use the syntax of filepath.Match, so `*` does not match across directories.
+func WithArtifacts(patterns []string, store *ArtifactStore) JobOption {
+	return func(job *Job) {
+		job.ArtifactPatterns = patterns
+		job.artifactStore = store
+	}
+}
+
+// ValidateArtifactPatterns returns an error if a pattern of `patterns` is malformed, or can not match a path in the workspace.
+func ValidateArtifactPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+		if _, _, err := splitWorkspacePath(pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetArtifacts returns the artifacts that were collected from the job in a thread-safe way. It is empty until the job is done.
+func (job *Job) GetArtifacts() []Artifact {
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	return job.artifacts
+}
+
+// GetArtifact returns the collected artifact named `name`, or ErrArtifactDoesNotExist.
+func (job *Job) GetArtifact(name string) (Artifact, error) {
+	for _, artifact := range job.GetArtifacts() {
+		if artifact.Name == name {
+			return artifact, nil
+		}
+	}
+
+	return Artifact{}, ErrArtifactDoesNotExist
+}
+
+// collectArtifacts copies the files that match the job's artifact patterns into its artifact store, until maxArtifacts files or the store's MaxJobSize bytes were collected. Files that can not be collected are skipped, so that one bad file does not lose the others.
+func (job *Job) collectArtifacts() {
+	if len(job.ArtifactPatterns) == 0 || job.artifactStore == nil {
+		return
+	}
+
+	logger := log.WithFields(log.Fields{"func": "Job.collectArtifacts", "jobKey": job.Key})
+
+	// the workspace is walked without following symbolic links, and matching files are opened with OpenFile, which does not follow them either
+	workspace := job.WorkspaceDirectory()
+	artifacts := []Artifact{}
+	budget := job.artifactStore.MaxJobSize
+	if budget == 0 {
+		budget = maxArtifacts * job.artifactStore.maxFileSize
+	}
+	err := filepath.Walk(workspace, func(path string, info os.FileInfo, err error) error {
+		if len(artifacts) >= maxArtifacts || budget == 0 {
+			return errStopCollecting
+		}
+		if err != nil {
+			logger.WithError(err).WithField("path", path).Warn("unable to walk the workspace")
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(workspace, path)
+		if err != nil || !matchArtifact(job.ArtifactPatterns, name) {
+			return nil
+		}
+
+		artifact, err := job.collectArtifact(name, budget)
+		if err != nil {
+			logger.WithError(err).WithField("name", name).Warn("unable to collect artifact")
+			return nil
+		}
+		artifacts = append(artifacts, artifact)
+		budget -= artifact.Size
+
+		return nil
+	})
+	if errors.Is(err, errStopCollecting) {
+		logger.WithFields(log.Fields{"maxArtifacts": maxArtifacts, "maxJobSize": job.artifactStore.MaxJobSize}).Warn("only collected the first artifacts")
+	} else if err != nil {
+		logger.WithError(err).Error("unable to collect artifacts")
+	}
+
+	job.mu.Lock()
+	job.artifacts = artifacts
+	job.mu.Unlock()
+}
+
+// collectArtifact copies the file `name` in the job's workspace into its artifact store, if it is at most `budget` bytes large.
+func (job *Job) collectArtifact(name string, budget int64) (Artifact, error) {
+	file, err := job.OpenFile(name)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	maxSize := job.artifactStore.maxFileSize
+	if budget < maxSize {
+		maxSize = budget
+	}
+	digest, size, err := job.artifactStore.put(file, maxSize)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	return Artifact{Name: filepath.ToSlash(name), Size: size, Mode: info.Mode().Perm(), Digest: digest}, nil
+}
+
+// matchArtifact returns true if the relative path `name` matches one of `patterns`.
+func matchArtifact(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(filepath.Clean(pattern), name); ok {
+			return true
+		}
+	}
+
+	return false
+}
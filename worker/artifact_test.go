@@ -0,0 +1,78 @@
+package worker_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobArtifacts collects the artifacts of jobs, and checks that only matching regular files within the size cap are kept, and that identical files are stored once.
+func TestJobArtifacts(t *testing.T) {
+	t.Parallel()
+
+	require.Error(t, worker.ValidateArtifactPatterns([]string{"[a-"}))
+	require.ErrorIs(t, worker.ValidateArtifactPatterns([]string{"../*"}), worker.ErrInvalidPath)
+	require.ErrorIs(t, worker.ValidateArtifactPatterns([]string{"/etc/*"}), worker.ErrInvalidPath)
+
+	dir, err := ioutil.TempDir("", "artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := worker.NewArtifactStore(dir, 16)
+
+	script := `mkdir out && echo same > out/a.txt && echo same > out/b.txt && chmod 644 out/a.txt out/b.txt && echo 0123456789abcdef > out/large.txt && echo no > out/c.log && ln -s /etc/passwd out/link.txt && echo no > top.txt`
+	job, output := runJob(t, "sh", []string{"-c", script}, worker.WithArtifacts([]string{"out/*.txt"}, store))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+
+	sum := sha256.Sum256([]byte("same\n"))
+	digest := hex.EncodeToString(sum[:])
+	require.Equal(t, []worker.Artifact{
+		{Name: "out/a.txt", Size: 5, Mode: 0644, Digest: digest},
+		{Name: "out/b.txt", Size: 5, Mode: 0644, Digest: digest},
+	}, job.GetArtifacts())
+
+	// the workspace is deleted, but the artifacts are kept by their digest
+	_, err = os.Stat(job.WorkspaceDirectory())
+	require.True(t, os.IsNotExist(err))
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "sha256"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	artifact, err := job.GetArtifact("out/b.txt")
+	require.NoError(t, err)
+	file, err := store.Open(artifact.Digest)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	require.Equal(t, "same\n", string(data))
+
+	_, err = job.GetArtifact("top.txt")
+	require.ErrorIs(t, err, worker.ErrArtifactDoesNotExist)
+	_, err = store.Open("../../etc/passwd")
+	require.ErrorIs(t, err, worker.ErrArtifactDoesNotExist)
+
+	// artifacts beyond the job's budget are not collected
+	store.MaxJobSize = 6
+	job, output = runJob(t, "sh", []string{"-c", `echo one > a.txt && echo two > b.txt`}, worker.WithArtifacts([]string{"*.txt"}, store))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Len(t, job.GetArtifacts(), 1)
+
+	// artifacts that were not collected again within the TTL are pruned
+	store.TTL = time.Hour
+	require.NoError(t, store.Prune())
+	_, err = store.Open(artifact.Digest)
+	require.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "sha256", artifact.Digest), old, old))
+	require.NoError(t, store.Prune())
+	_, err = store.Open(artifact.Digest)
+	require.ErrorIs(t, err, worker.ErrArtifactDoesNotExist)
+}
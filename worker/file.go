@@ -1,14 +1,43 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 )
 
+// writeContext writes `data` to `writer`, and interrupts the write once `ctx` is done if `writer` supports write deadlines, e.g. pipes and pseudo-terminals. It returns the context's error if the write was interrupted.
+func writeContext(ctx context.Context, writer io.Writer, data []byte) error {
+	if file, ok := writer.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		written, interrupted := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(interrupted)
+			select {
+			case <-ctx.Done():
+				file.SetWriteDeadline(time.Now())
+			case <-written:
+			}
+		}()
+		defer func() {
+			close(written)
+			<-interrupted
+			file.SetWriteDeadline(time.Time{}) // the next write starts without a deadline
+		}()
+	}
+
+	_, err := writer.Write(data)
+	if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
 // watchFile watches `filename` for changes, sending a value every time a change is detected. The watcher is closed when the `done` channel receives input.
 func watchFile(done <-chan struct{}, filename string) (<-chan struct{}, error) {
 	logger := log.WithFields(log.Fields{"func": "WatchFile", "filename": filename})
@@ -2,9 +2,12 @@ package worker
 
 import (
 	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,19 +23,57 @@ type JobKey struct {
 
 // Job represents a single job with all of its related objects.
 type Job struct {
-	Key       JobKey
-	Command   string
-	Args      []string
-	CreatedAt time.Time
-	Done      chan struct{} // Done is a channel that's closed after the job process is done and the job is updated with the status.
+	Key         JobKey
+	Command     string
+	Args        []string
+	Group       string // Group is the group that the job is shared with, or empty if the job is not shared.
+	Rootfs      string // Rootfs is the name of the root filesystem that the job runs in, or empty if it runs in the server's.
+	ImageDigest string // ImageDigest is the digest of the image manifest that the job's root filesystem was unpacked from, if any.
+	CreatedAt   time.Time
+	Done        chan struct{} // Done is a channel that's closed after the job process is done, the job is updated with the status and its workspace is cleaned up.
+
+	WorkspaceRetention pb.WorkspaceRetention // WorkspaceRetention decides whether the job's workspace is kept after the job is done.
+	Env                map[string]string     // Env are the environment variables set for the job's command on top of its base environment.
+	EnvBase            pb.EnvBase            // EnvBase describes the base environment of the job's command.
+	WorkingDir         string                // WorkingDir is the working directory of the job's command, as the command sees it.
+	TTY                bool                  // TTY is true if the job's command runs in a pseudo-terminal.
+	Stdin              bool                  // Stdin is true if the job's stdin is a pipe that clients can write to.
+	ArtifactPatterns   []string              // ArtifactPatterns match the files in the job's workspace that are collected as artifacts when the job is done.
 
 	// these fields can be changed, and should only be accessed through the Get methods
 	jobStatus  pb.JobStatus
 	exitCode   int32
 	finishedAt time.Time
-
-	mu    *sync.RWMutex        // mu is a read-write mutex to synchronize job updates.
-	group *ProcessGroupCommand // group is the process group command providing access to the executing command.
+	artifacts  []Artifact
+
+	mu         *sync.RWMutex        // mu is a read-write mutex to synchronize job updates.
+	group      *ProcessGroupCommand // group is the process group command providing access to the executing command.
+	credential *syscall.Credential  // credential is the user and groups that the command runs as, or nil to run it as the current user.
+	security   *SecurityProfile     // security restricts the privileges of the command, if it is not nil.
+	rootfs     *Rootfs              // rootfs is the root filesystem that the command runs in, if it is not nil.
+	tmpfsSize  uint64               // tmpfsSize is the size cap in bytes of a tmpfs mounted as the job's workspace, or zero to use a directory on disk.
+	baseEnv    []string             // baseEnv is the base environment of the job's command in "name=value" form, or nil to use the current process's.
+	workingDir string               // workingDir is the requested working directory, which is resolved in the workspace if it is relative.
+	secrets    []Secret             // secrets are made available to the command as environment variables or files.
+
+	logDir string // logDir is the directory of the job's log file, which only the current process can access.
+	runDir string // runDir is the directory of the job's workspace and secrets directory, which is kept apart from its log file.
+
+	artifactStore *ArtifactStore // artifactStore keeps the artifacts collected from the job.
+
+	uploadLimit UploadLimit // uploadLimit caps the files that can be uploaded to the job's workspace.
+	uploaded    UploadLimit // uploaded is how much of uploadLimit was used.
+
+	terminalSize TerminalSize   // terminalSize is the initial size of the command's pseudo-terminal, if TTY is true.
+	stdin        io.WriteCloser // stdin is the write end of the command's stdin pipe while it is open, if Stdin is true.
+	stdinMu      *sync.Mutex    // stdinMu controls access to `stdin` and `stdinBusy`.
+	stdinBusy    bool           // stdinBusy is true while a client holds the job's stdin.
+
+	started         bool          // started is true once the job was claimed by Start, which happens only once.
+	hold            bool          // hold is true if the job is held in status HELD until it is released.
+	holdTimeout     time.Duration // holdTimeout is how long the job can stay held before it is stopped, or zero if it can stay held until it is released.
+	pendingApproval bool          // pendingApproval is true if the job is held in status PENDING_APPROVAL until it is approved.
+	onCancel        func()        // onCancel is called once the job was stopped before it was started, e.g. to remove it from the scheduler's queue.
 }
 
 // GetJobStatus locks the job mutex for reading and returns the job's status.
@@ -63,23 +104,66 @@ func (job *Job) LogFilepath() string {
 
 // LogDirectory returns the path to the directory containing the job's log file.
 func (job *Job) LogDirectory() string {
-	return filepath.Join("tmp", "jobs", job.Key.UserId, job.Key.JobId)
+	return job.logDir
 }
 
-/* Start runs the job without blocking.*/
+/* Start runs the job without blocking. It returns ErrJobNotQueued if the job was already started, or is not ready to be started. If the job fails to start, it is done with status FAILED.*/
 func (job *Job) Start() error {
 	logger := log.WithFields(log.Fields{"func": "Job.Start", "jobKey": job.Key})
 
+	// claim the job, so that it is only started once and is no longer stopped without running
+	job.mu.Lock()
+	if job.jobStatus != pb.JobStatus_QUEUED || job.started {
+		job.mu.Unlock()
+		return ErrJobNotQueued
+	}
+	job.started = true
+	job.mu.Unlock()
+
+	// the secrets are passed on to the command or the shim when it starts, and are not needed after that even if it fails to start
+	defer job.clearSecrets()
+
 	// open the logFile for writing, and pass it to the process group command
-	logFile, err := os.OpenFile(job.LogFilepath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	logFile, err := os.OpenFile(job.LogFilepath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		logger.WithError(err).Error("unable to open file for writing")
+		job.failStart()
 		return err
 	}
 
-	// start the process
-	if err := job.group.Start(logFile, logFile); err != nil {
+	// stdin is closed once the command exits, if it was not closed by a client before. Jobs with a terminal read their input from it instead
+	if job.Stdin && !job.TTY {
+		stdin, err := job.group.Cmd.StdinPipe()
+		if err != nil {
+			logger.WithError(err).Error("unable to open stdin")
+			logFile.Close()
+			job.failStart()
+			return err
+		}
+		job.stdinMu.Lock()
+		job.stdin = stdin
+		job.stdinMu.Unlock()
+	}
+
+	sendShimConfig, err := job.pipeShimConfig(job.shimConfig())
+	if err != nil {
+		logger.WithError(err).Error("unable to pass shim configuration")
+		logFile.Close()
+		job.failStart()
+		return err
+	}
+
+	// start the process, in a pseudo-terminal whose output is logged if requested
+	if job.TTY {
+		err = job.group.StartTerminal(logFile, job.terminalSize)
+	} else {
+		err = job.group.Start(logFile, logFile)
+	}
+	sendShimConfig()
+	if err != nil {
 		logger.WithError(err).Error("unable to start process")
+		logFile.Close()
+		job.failStart()
 		return err
 	}
 
@@ -89,17 +173,16 @@ func (job *Job) Start() error {
 	job.mu.Unlock()
 
 	go func() {
-		// close the logFile and the Done channel after the process is done
+		// close the logFile and the Done channel after the process is done and the workspace is cleaned up
 		defer close(job.Done)
 		defer logFile.Close()
 
-		// wait for the command to finish
+		// wait for the command to finish, and collect its artifacts before the job is reported as done
 		<-job.group.Done
+		job.collectArtifacts()
 
 		// update job status and exit code
 		job.mu.Lock()
-		defer job.mu.Unlock()
-
 		job.finishedAt = job.group.GetDoneAt()
 		if job.group.GetStopped() {
 			job.jobStatus = pb.JobStatus_STOPPED
@@ -110,16 +193,117 @@ func (job *Job) Start() error {
 			job.jobStatus = pb.JobStatus_SUCCEEDED
 		}
 		job.exitCode = int32(job.group.GetExitCode())
+		jobStatus := job.jobStatus
+		job.mu.Unlock()
+
+		job.cleanupWorkspace(jobStatus)
 	}()
 
 	return nil
 }
 
-// Stop sends a signal to the process group to trigger the job to stop. This method does not block.
+// Stop sends a signal to the process group to trigger the job to stop. Jobs that are queued, held or pending approval are stopped without ever running. This method does not block.
 func (job *Job) Stop() {
+	if job.cancel() {
+		return
+	}
 	go job.group.Stop()
 }
 
+// Approve marks a job that is pending approval as ready to be started. It returns ErrJobNotPendingApproval if the job was not pending approval, e.g. because it was already approved or stopped.
+func (job *Job) Approve() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if job.jobStatus != pb.JobStatus_PENDING_APPROVAL {
+		return ErrJobNotPendingApproval
+	}
+	job.jobStatus = pb.JobStatus_QUEUED
+
+	return nil
+}
+
+// Release marks a held job as ready to be started, or as pending approval if it requires approval. It returns ErrJobNotHeld if the job was not held, e.g. because it was already released or stopped.
+func (job *Job) Release() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if job.jobStatus != pb.JobStatus_HELD {
+		return ErrJobNotHeld
+	}
+	if job.pendingApproval {
+		job.jobStatus = pb.JobStatus_PENDING_APPROVAL
+	} else {
+		job.jobStatus = pb.JobStatus_QUEUED
+	}
+
+	return nil
+}
+
+// expireHold stops the job if it is still held, so that held jobs that are never released do not keep their workspace.
+func (job *Job) expireHold() {
+	if job.cancelIf(pb.JobStatus_HELD) {
+		log.WithFields(log.Fields{"func": "Job.expireHold", "jobKey": job.Key, "holdTimeout": job.holdTimeout}).Info("stopped a job that was held for too long")
+	}
+}
+
+// failStart marks a job that could not be started as failed.
+func (job *Job) failStart() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.jobStatus = pb.JobStatus_FAILED
+	job.finishedAt = time.Now()
+	job.cleanupWorkspace(job.jobStatus)
+	close(job.Done)
+}
+
+// cancel stops a job that was not started yet, and returns false if the job was started.
+func (job *Job) cancel() bool {
+	return job.cancelIf(pb.JobStatus_QUEUED, pb.JobStatus_HELD, pb.JobStatus_PENDING_APPROVAL)
+}
+
+// cancelIf stops a job that was not started yet and has one of `statuses`, and returns false otherwise.
+func (job *Job) cancelIf(statuses ...pb.JobStatus) bool {
+	job.mu.Lock()
+
+	cancellable := false
+	for _, jobStatus := range statuses {
+		cancellable = cancellable || job.jobStatus == jobStatus
+	}
+	if job.started || !cancellable {
+		job.mu.Unlock()
+		return false
+	}
+
+	job.jobStatus = pb.JobStatus_STOPPED
+	job.finishedAt = time.Now()
+	job.cleanupWorkspace(job.jobStatus)
+	close(job.Done)
+	onCancel := job.onCancel
+	job.mu.Unlock()
+
+	// the callback is run without holding `mu`, so that it can take other locks
+	if onCancel != nil {
+		onCancel()
+	}
+
+	return true
+}
+
+// whenCancelled sets `onCancel` to be called once the job is stopped before it is started. It returns false if the job is no longer queued, in which case `onCancel` is never called.
+func (job *Job) whenCancelled(onCancel func()) bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if job.started || job.jobStatus != pb.JobStatus_QUEUED {
+		return false
+	}
+	job.onCancel = onCancel
+
+	return true
+}
+
 // Log follows content of job's log file and sends to the returned channel. The returned channel is only closed after the log file is completely read and the job is not running.
 func (job *Job) Log(ctx context.Context) (<-chan []byte, error) {
 	logger := log.WithFields(log.Fields{"func": "Job.Log", "jobKey": job.Key, "logFilepath": job.LogFilepath()})
@@ -150,7 +334,7 @@ func (job *Job) Log(ctx context.Context) (<-chan []byte, error) {
 					break ForLoop
 				}
 
-			case <-job.group.Done:
+			case <-job.Done:
 				keepReading = true
 				break ForLoop
 
@@ -173,18 +357,167 @@ func (job *Job) Log(ctx context.Context) (<-chan []byte, error) {
 	return outputChan, nil
 }
 
-// NewJob generates a new Job object with status CREATED and exit code -1.
-func NewJob(userId string, command string, args []string) *Job {
+// JobOption configures optional settings of a new job.
+type JobOption func(job *Job)
+
+// WithGroup shares the job with the members of `group`.
+func WithGroup(group string) JobOption {
+	return func(job *Job) {
+		job.Group = group
+	}
+}
+
+// WithCredential runs the job's command as `uid` and `gid`, with supplementary groups `groups`. Like security profiles, it is applied by the shim, which changes to the job's working directory before it switches users. This usually requires the current process to run as root.
+func WithCredential(uid uint32, gid uint32, groups []uint32) JobOption {
+	return func(job *Job) {
+		job.credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: groups}
+	}
+}
+
+// WithRootfs runs the job's command in `rootfs`, on a writable overlay of it in a new mount namespace. Like security profiles, it is set up by the shim, and requires the current process to run as root.
+func WithRootfs(rootfs Rootfs) JobOption {
+	return func(job *Job) {
+		job.Rootfs = rootfs.Name
+		job.ImageDigest = rootfs.Digest
+		job.rootfs = &rootfs
+	}
+}
+
+// WithWorkspaceRetention decides whether the job's workspace is kept after the job is done. By default, it is deleted.
+func WithWorkspaceRetention(retention pb.WorkspaceRetention) JobOption {
+	return func(job *Job) {
+		job.WorkspaceRetention = retention
+	}
+}
+
+// WithWorkspaceTmpfs mounts a tmpfs capped at `size` bytes as the job's workspace, so that the workspace does not use the server's disk. Its contents are lost when the job is done, regardless of the workspace retention. Like root filesystems, it is mounted by the shim, and requires the current process to run as root.
+func WithWorkspaceTmpfs(size uint64) JobOption {
+	return func(job *Job) {
+		job.tmpfsSize = size
+	}
+}
+
+// WithEnv sets the environment variables `env` for the job's command, on top of its base environment.
+func WithEnv(env map[string]string) JobOption {
+	return func(job *Job) {
+		job.Env = env
+	}
+}
+
+// WithBaseEnv replaces the base environment of the job's command, which is the current process's by default, with `environ` in "name=value" form. `base` describes where `environ` came from.
+func WithBaseEnv(base pb.EnvBase, environ []string) JobOption {
+	return func(job *Job) {
+		job.EnvBase = base
+		job.baseEnv = environ
+	}
+}
+
+// WithWorkingDir runs the job's command in `dir` instead of its workspace. Relative paths are resolved in the workspace, and created along with it.
+func WithWorkingDir(dir string) JobOption {
+	return func(job *Job) {
+		job.workingDir = dir
+	}
+}
+
+// withDirectories keeps the job's log file in a directory of its own in `logDir`, and its workspace and secrets directory in a directory of its own in `workspaceDir`.
+func withDirectories(logDir string, workspaceDir string) JobOption {
+	return func(job *Job) {
+		job.logDir = filepath.Join(logDir, job.Key.UserId, job.Key.JobId)
+		job.runDir = filepath.Join(workspaceDir, job.Key.JobId)
+	}
+}
+
+// WithPendingApproval holds the job in status PENDING_APPROVAL until it is approved.
+func WithPendingApproval() JobOption {
+	return func(job *Job) {
+		job.pendingApproval = true
+	}
+}
+
+// WithHold holds the job in status HELD until it is released, so that files can be uploaded to its workspace before it runs. Jobs that also require approval are pending approval once they are released.
+func WithHold() JobOption {
+	return func(job *Job) {
+		job.hold = true
+	}
+}
+
+// WithHoldTimeout stops a held job that was not released within `timeout`.
+func WithHoldTimeout(timeout time.Duration) JobOption {
+	return func(job *Job) {
+		job.holdTimeout = timeout
+	}
+}
+
+// NewJob generates a new Job object with status QUEUED and exit code -1.
+func NewJob(userId string, command string, args []string, opts ...JobOption) *Job {
 	jobId := uuid.New().String()
-	return &Job{
+	job := &Job{
 		Key:       JobKey{UserId: userId, JobId: jobId},
 		Command:   command,
 		Args:      args,
 		CreatedAt: time.Now(),
-		jobStatus: pb.JobStatus_CREATED,
+		jobStatus: pb.JobStatus_QUEUED,
 		exitCode:  -1,
 		mu:        &sync.RWMutex{},
-		group:     NewProcessGroupCommand(command, args),
+		stdinMu:   &sync.Mutex{},
 		Done:      make(chan struct{}),
 	}
+
+	withDirectories(absPath(DefaultLogDir), absPath(DefaultWorkspaceDir))(job)
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	switch {
+	case job.hold:
+		job.jobStatus = pb.JobStatus_HELD
+		if job.holdTimeout > 0 {
+			time.AfterFunc(job.holdTimeout, job.expireHold)
+		}
+	case job.pendingApproval:
+		job.jobStatus = pb.JobStatus_PENDING_APPROVAL
+	}
+
+	job.WorkingDir = job.resolveWorkingDir()
+
+	// the shim starts in the workspace, and changes to the working directory itself. It gets the command's environment with its configuration when the job starts
+	if job.shimConfig() != nil {
+		name, shimArgs := shimCommand(command, args)
+		job.group = NewProcessGroupCommand(name, shimArgs, absPath(job.WorkspaceDirectory()), shimEnv, nil)
+	} else {
+		job.group = NewProcessGroupCommand(command, args, job.WorkingDir, job.environ(), job.credential)
+	}
+
+	return job
+}
+
+// environ returns the environment of the job's command: its base environment, followed by Env in order of name so that Env takes precedence, by its secrets, and by WorkspaceEnv, which the command finds its workspace through.
+func (job *Job) environ() []string {
+	base := job.baseEnv
+	if base == nil {
+		base = os.Environ()
+	}
+
+	names := make([]string, 0, len(job.Env))
+	for name := range job.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	environ := append([]string{}, base...)
+	for _, name := range names {
+		environ = append(environ, name+"="+job.Env[name])
+	}
+
+	for _, secret := range job.secrets {
+		if secret.Env != "" {
+			environ = append(environ, secret.Env+"="+string(secret.Value))
+		}
+	}
+	if len(job.secretFiles()) > 0 {
+		environ = append(environ, SecretsEnv+"="+job.secretsPath())
+	}
+	environ = append(environ, WorkspaceEnv+"="+job.workspacePath())
+
+	return environ
 }
@@ -3,28 +3,38 @@ package worker
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	ErrJobDoesNotExist = errors.New("the job id and user id combination does not exist")
+	ErrJobDoesNotExist       = errors.New("the job id and user id combination does not exist")
+	ErrJobNotPendingApproval = errors.New("the job is not pending approval")
+	ErrJobNotHeld            = errors.New("the job is not held")
+	ErrJobNotQueued          = errors.New("the job is not queued")
+
+	DefaultLogDir       = filepath.Join("tmp", "jobs")       // DefaultLogDir is where the log files of jobs are kept by default, relative to the current working directory.
+	DefaultWorkspaceDir = filepath.Join("tmp", "workspaces") // DefaultWorkspaceDir is where the workspaces of jobs are created by default, relative to the current working directory.
 )
 
 // JobStore stores Job objects, keyed by JobKey (jobId+userId).
 type JobStore struct {
-	Job *sync.Map // Job is a thread-safe `map[JobKey]Job`.
+	Job          *sync.Map // Job is a thread-safe `map[JobKey]Job`.
+	jobId        *sync.Map // jobId is a thread-safe `map[string]JobKey`, indexing jobs by job id alone.
+	LogDir       string    // LogDir is the absolute path of the directory that the log files of new jobs are kept in, by user id and job id. Only the current process can access them.
+	WorkspaceDir string    // WorkspaceDir is the absolute path of the directory that the workspaces and secret files of new jobs are kept in, by job id. Jobs that run as another user can only reach their workspace by its path if that user can search the directories above it.
 }
 
-// NewStore initializes a new job store.
+// NewStore initializes a new job store, which keeps jobs in DefaultLogDir and DefaultWorkspaceDir.
 func NewJobStore() *JobStore {
-	return &JobStore{Job: &sync.Map{}}
+	return &JobStore{Job: &sync.Map{}, jobId: &sync.Map{}, LogDir: absPath(DefaultLogDir), WorkspaceDir: absPath(DefaultWorkspaceDir)}
 }
 
 // AddJob initializes a new job, creates log directories for it and adds it to the store.
-func (store *JobStore) AddJob(userId string, command string, args []string) (*Job, error) {
-	job := NewJob(userId, command, args)
+func (store *JobStore) AddJob(userId string, command string, args []string, opts ...JobOption) (*Job, error) {
+	job := NewJob(userId, command, args, append([]JobOption{withDirectories(store.LogDir, store.WorkspaceDir)}, opts...)...)
 	logger := log.WithFields(log.Fields{"func": "JobStore.AddJob", "jobKey": job.Key})
 
 	// add the job to the store
@@ -34,22 +44,35 @@ func (store *JobStore) AddJob(userId string, command string, args []string) (*Jo
 		logger.WithError(err).Error("unable to add job")
 		return nil, err
 	}
+	store.jobId.Store(job.Key.JobId, job.Key)
+
+	// create the directory of workspaces first, so that any directories it shares with the log's directory can be searched by the users that jobs run as
+	if err := os.MkdirAll(store.WorkspaceDir, 0711); err != nil {
+		logger.WithError(err).Error("unable to create workspace directory")
+		return nil, err
+	}
 
-	// create the log's directory if it doesn't already exist
-	err := os.MkdirAll(job.LogDirectory(), os.ModePerm)
+	// create the log's directory if it doesn't already exist. Logs can contain secrets, so only the current process can read them
+	err := os.MkdirAll(job.LogDirectory(), 0700)
 	if err != nil {
 		logger.WithError(err).Error("unable to create log file directory")
 		return nil, err
 	}
 
 	// create the log file
-	file, err := os.Create(job.LogFilepath())
+	file, err := os.OpenFile(job.LogFilepath(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		logger.WithError(err).Error("unable to touch log file")
 		return nil, err
 	}
 	file.Close()
 
+	// create the job's workspace
+	if err := job.createWorkspace(); err != nil {
+		logger.WithError(err).Error("unable to create workspace")
+		return nil, err
+	}
+
 	return job, nil
 }
 
@@ -67,3 +90,26 @@ func (store *JobStore) LoadJob(jobKey JobKey) (*Job, error) {
 
 	return job, nil
 }
+
+// LoadJobById loads a job from the store by its job id alone, regardless of which user started it. Callers are responsible for checking that the requesting user is allowed to access the job.
+func (store *JobStore) LoadJobById(jobId string) (*Job, error) {
+	jobKey, ok := store.jobId.Load(jobId)
+	if !ok {
+		return nil, ErrJobDoesNotExist
+	}
+
+	return store.LoadJob(jobKey.(JobKey))
+}
+
+// ListJobs returns all jobs in the store, in no particular order.
+func (store *JobStore) ListJobs() []*Job {
+	jobs := []*Job{}
+	store.Job.Range(func(_, value interface{}) bool {
+		if job, ok := value.(*Job); ok {
+			jobs = append(jobs, job)
+		}
+		return true
+	})
+
+	return jobs
+}
@@ -3,7 +3,11 @@ package worker_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mlaradji/int-backend-mohamed/pb"
 	"github.com/mlaradji/int-backend-mohamed/worker"
@@ -34,6 +38,28 @@ do
   sleep 0.2
 done`
 
+// runJob runs a job to completion and returns its output.
+func runJob(t *testing.T, command string, args []string, opts ...worker.JobOption) (*worker.Job, string) {
+	return runJobInStore(t, worker.NewJobStore(), command, args, opts...)
+}
+
+// runJobInStore is runJob for a job added to `store`.
+func runJobInStore(t *testing.T, store *worker.JobStore, command string, args []string, opts ...worker.JobOption) (*worker.Job, string) {
+	job, err := store.AddJob("me", command, args, opts...)
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+
+	logChannel, err := job.Log(context.Background())
+	require.NoError(t, err)
+
+	output := []byte{}
+	for data := range logChannel {
+		output = append(output, data...)
+	}
+
+	return job, string(output)
+}
+
 // TestJobStopped executes a long running process and stops it.
 func TestJobStopped(t *testing.T) {
 	t.Parallel()
@@ -255,3 +281,158 @@ func TestJobFollowLogLong(t *testing.T) {
 }
 
 // TODO: Test a process that simultaneously outputs to both stdout and stderr. If the process attempts to write to both at the same time, they may be combined in a non-meaningful way (e.g. "HeErrorllo" instead of "Hello\nError\n").
+
+// TestJobPendingApproval holds a job for approval, and checks that it only runs once approved, and that stopping it before approval ends it without running.
+func TestJobPendingApproval(t *testing.T) {
+	t.Parallel()
+
+	store := worker.NewJobStore()
+
+	job, err := store.AddJob("me", "echo", []string{"approved"}, worker.WithPendingApproval())
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_PENDING_APPROVAL, job.GetJobStatus())
+
+	require.NoError(t, job.Approve())
+	require.ErrorIs(t, job.Approve(), worker.ErrJobNotPendingApproval)
+	require.NoError(t, job.Start())
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+
+	// stopped jobs can not be approved, and their logs end right away
+	job, err = store.AddJob("me", "echo", []string{"stopped"}, worker.WithPendingApproval())
+	require.NoError(t, err)
+
+	job.Stop()
+	<-job.Done
+	require.Equal(t, pb.JobStatus_STOPPED, job.GetJobStatus())
+	require.ErrorIs(t, job.Approve(), worker.ErrJobNotPendingApproval)
+
+	logChannel, err := job.Log(context.Background())
+	require.NoError(t, err)
+	for range logChannel {
+	}
+}
+
+// TestJobCredential runs a job as another user and groups, and checks that the process has their ids.
+func TestJobCredential(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("running jobs as another user requires root")
+	}
+
+	job, output := runJob(t, "sh", []string{"-c", "id -u; id -g; id -G"}, worker.WithCredential(65534, 65533, []uint32{65532}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+	require.Equal(t, "65534\n65533\n65533 65532\n", output)
+}
+
+// TestJobEnv checks that jobs run with their environment variables on top of their base environment, and in their working directory, with and without the shim.
+func TestJobEnv(t *testing.T) {
+	t.Parallel()
+
+	// the shim looks the command up in the server's PATH, even if the job's environment does not have one
+	for _, shim := range []bool{false, true} {
+		opts := []worker.JobOption{
+			worker.WithBaseEnv(pb.EnvBase_ENV_EMPTY, []string{"A=base", "B=base"}),
+			worker.WithEnv(map[string]string{"B": "job"}),
+			worker.WithWorkingDir("sub/dir"),
+		}
+		if shim {
+			opts = append(opts, worker.WithSecurityProfile(worker.SecurityProfile{BlockedSyscalls: []string{}}))
+		}
+
+		job, output := runJob(t, "sh", []string{"-c", `echo "$A $B ${HOME-unset}" && pwd`}, opts...)
+		require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+		require.Equal(t, "base job unset\n"+job.WorkingDir+"\n", output, "shim: %v", shim)
+		workspace, err := filepath.Abs(job.WorkspaceDirectory())
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(workspace, "sub", "dir"), job.WorkingDir)
+	}
+}
+
+// TestJobTerminal runs a job in a pseudo-terminal, and checks that it can write to it and resize it while it runs, and that its output is logged.
+func TestJobTerminal(t *testing.T) {
+	t.Parallel()
+
+	job, err := worker.NewJobStore().AddJob("me", "sh", []string{"-c", `[ -t 0 ] && stty size && read line && stty size && echo "got $line"`}, worker.WithTerminal(worker.TerminalSize{Rows: 30, Cols: 100}))
+	require.NoError(t, err)
+	require.True(t, job.TTY)
+	require.Equal(t, worker.ErrTerminalClosed, job.WriteTerminal(context.Background(), []byte("early\n")), "jobs that are not running have no terminal")
+	require.NoError(t, job.Start())
+
+	require.NoError(t, job.ResizeTerminal(worker.TerminalSize{Rows: 40, Cols: 120}))
+	require.NoError(t, job.WriteTerminal(context.Background(), []byte("hello\n")))
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+
+	output, err := ioutil.ReadFile(job.LogFilepath())
+	require.NoError(t, err)
+	require.Contains(t, string(output), "got hello\r\n")
+	require.Contains(t, string(output), "40 120\r\n")
+	require.Equal(t, worker.ErrTerminalClosed, job.WriteTerminal(context.Background(), []byte("late\n")))
+
+	job, _ = runJob(t, "true", nil)
+	require.Equal(t, worker.ErrNoTerminal, job.WriteTerminal(context.Background(), []byte("input")))
+}
+
+// TestJobTerminalTimeout checks that a write to the terminal of a job that never reads it is interrupted once its context is done.
+func TestJobTerminalTimeout(t *testing.T) {
+	t.Parallel()
+
+	job, err := worker.NewJobStore().AddJob("me", "sleep", []string{"10"}, worker.WithTerminal(worker.DefaultTerminalSize))
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+	defer job.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, job.WriteTerminal(ctx, make([]byte, 1<<20)))
+}
+
+// TestJobStdin writes to the stdin of a job, and checks that it reads EOF once stdin is closed, and that only one client can hold stdin at a time.
+func TestJobStdin(t *testing.T) {
+	t.Parallel()
+
+	job, err := worker.NewJobStore().AddJob("me", "sh", []string{"-c", `cat && echo done`}, worker.WithStdin())
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+
+	release, err := job.AcquireStdin()
+	require.NoError(t, err)
+	_, err = job.AcquireStdin()
+	require.Equal(t, worker.ErrStdinBusy, err)
+	require.NoError(t, job.WriteStdin(context.Background(), []byte("hello ")))
+	release()
+
+	// another client continues where the first one stopped
+	release, err = job.AcquireStdin()
+	require.NoError(t, err)
+	defer release()
+	require.NoError(t, job.WriteStdin(context.Background(), []byte("world\n")))
+	require.NoError(t, job.CloseStdin())
+	require.Equal(t, worker.ErrStdinClosed, job.WriteStdin(context.Background(), []byte("late")))
+
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+	output, err := ioutil.ReadFile(job.LogFilepath())
+	require.NoError(t, err)
+	require.Equal(t, "hello world\ndone\n", string(output))
+
+	// jobs without stdin read from the null device
+	job, nullOutput := runJob(t, "cat", nil)
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+	require.Empty(t, nullOutput)
+	_, err = job.AcquireStdin()
+	require.Equal(t, worker.ErrNoStdin, err)
+
+	// a write to a command that does not read gives up once its context is done
+	job, err = worker.NewJobStore().AddJob("me", "sh", []string{"-c", `sleep 1 && head -c 3`}, worker.WithStdin())
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, job.WriteStdin(ctx, make([]byte, 1<<20)))
+	require.NoError(t, job.CloseStdin())
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+}
@@ -0,0 +1,118 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sysLandlockCreateRuleset = 444 // sysLandlockCreateRuleset is the number of landlock_create_ruleset, which is the same on amd64 and arm64.
+	sysLandlockAddRule       = 445 // sysLandlockAddRule is the number of landlock_add_rule.
+	sysLandlockRestrictSelf  = 446 // sysLandlockRestrictSelf is the number of landlock_restrict_self.
+
+	landlockCreateRulesetVersion = 1 << 0 // landlockCreateRulesetVersion makes landlock_create_ruleset return the supported ABI version.
+	landlockRulePathBeneath      = 1      // landlockRulePathBeneath is the type of rules that grant access beneath a file or directory.
+
+	landlockAccessExecute    = 1 << 0
+	landlockAccessWriteFile  = 1 << 1
+	landlockAccessReadFile   = 1 << 2
+	landlockAccessReadDir    = 1 << 3
+	landlockAccessRemoveDir  = 1 << 4
+	landlockAccessRemoveFile = 1 << 5
+	landlockAccessMakeChar   = 1 << 6
+	landlockAccessMakeDir    = 1 << 7
+	landlockAccessMakeReg    = 1 << 8
+	landlockAccessMakeSock   = 1 << 9
+	landlockAccessMakeFifo   = 1 << 10
+	landlockAccessMakeBlock  = 1 << 11
+	landlockAccessMakeSym    = 1 << 12
+	landlockAccessTruncate   = 1 << 14 // landlockAccessTruncate is supported from ABI version 3.
+
+	// landlockAccessV1 are the access rights supported from ABI version 1.
+	landlockAccessV1 = landlockAccessExecute | landlockAccessWriteFile | landlockAccessReadFile | landlockAccessReadDir | landlockAccessRemoveDir | landlockAccessRemoveFile |
+		landlockAccessMakeChar | landlockAccessMakeDir | landlockAccessMakeReg | landlockAccessMakeSock | landlockAccessMakeFifo | landlockAccessMakeBlock | landlockAccessMakeSym
+
+	landlockAccessReadOnly = landlockAccessExecute | landlockAccessReadFile | landlockAccessReadDir                            // landlockAccessReadOnly are the access rights of read-only paths.
+	landlockAccessFile     = landlockAccessExecute | landlockAccessWriteFile | landlockAccessReadFile | landlockAccessTruncate // landlockAccessFile are the access rights that apply to files, as opposed to directories.
+)
+
+// landlockRulesetAttr is struct landlock_ruleset_attr.
+type landlockRulesetAttr struct {
+	handledAccessFs uint64
+}
+
+// landlockPathBeneathAttr is struct landlock_path_beneath_attr. The kernel's struct is packed, which only drops the trailing padding of this one.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// restrictFilesystem restricts the current thread, and the processes it executes, to `access` with a Landlock ruleset. It requires no_new_privs to be set.
+func restrictFilesystem(access FilesystemAccess) error {
+	abi, _, errno := unix.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not supported by the kernel: %w", errno)
+	}
+
+	handled := uint64(landlockAccessV1)
+	if abi >= 3 {
+		handled |= landlockAccessTruncate
+	}
+
+	attr := landlockRulesetAttr{handledAccessFs: handled}
+	rulesetFd, _, errno := unix.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return errno
+	}
+	defer unix.Close(int(rulesetFd))
+
+	for _, path := range access.ReadOnly {
+		if err := addLandlockPathRule(int(rulesetFd), path, handled&landlockAccessReadOnly); err != nil {
+			return err
+		}
+	}
+	for _, path := range access.ReadWrite {
+		if err := addLandlockPathRule(int(rulesetFd), path, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(sysLandlockRestrictSelf, rulesetFd, 0, 0); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// addLandlockPathRule grants `allowed` access beneath `path`, or only the access rights that apply to files if it is not a directory. Paths that do not exist are skipped.
+func addLandlockPathRule(rulesetFd int, path string, allowed uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err == unix.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	stat := unix.Stat_t{}
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		allowed &= landlockAccessFile
+	}
+
+	attr := landlockPathBeneathAttr{allowedAccess: allowed, parentFd: int32(fd)}
+	if _, _, errno := unix.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("%s: %w", path, errno)
+	}
+
+	return nil
+}
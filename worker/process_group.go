@@ -2,7 +2,9 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
 	"syscall"
@@ -19,13 +21,15 @@ type ProcessGroupCommand struct {
 	Done chan struct{} // Done is a channel that is closed if and only if the process finished running or was stopped.
 
 	isDone      bool          // isDone is true if and only if the job has finished. It is also true if and only if the stop channel is closed.
-	mu          *sync.RWMutex // mu controls access to `stopped` and `doneAt`.
+	mu          *sync.RWMutex // mu controls access to `stopped`, `doneAt` and `pty`.
 	stop        chan struct{} // stop is a channel that can receive stop requests. It is initialized at process definition, and is closed after the process ends.
 	stopSenders *sync.WaitGroup
 	stopMutex   *sync.Mutex         // stopMutex controls access to the stop channel and to `isDone`. It is initialized at process definition, and the stop channel is not closed until after locking this.
 	group       *singleflight.Group // group ensures that only one stop command is running at a time.
 	stopped     bool                // Stopped is true if and only if the process was killed because of a stop request.
 	doneAt      time.Time           // doneAt is the time that the process stopped executing.
+	pty         *os.File            // pty is the master side of the pseudo-terminal that the process runs in, if any. It is closed once the process is done.
+	ptyDrained  chan struct{}       // ptyDrained is closed once all output of the pseudo-terminal was copied to the log.
 }
 
 // GetExitCode returns the exit code of the process. It is equal to -1 if the job is still running or was stopped.
@@ -49,12 +53,107 @@ func (group *ProcessGroupCommand) GetDoneAt() time.Time {
 
 // Start starts the command and logs its output to the attached files.
 func (group *ProcessGroupCommand) Start(stdoutLogWriter io.Writer, stderrLogWriter io.Writer) error {
-	logger := log.WithFields(log.Fields{"func": "ProcessGroupCommand.Start"})
-
 	// attach logs
 	group.Cmd.Stdout = stdoutLogWriter
 	group.Cmd.Stderr = stderrLogWriter
 
+	return group.start()
+}
+
+// StartTerminal starts the command in a new session, with a pseudo-terminal of `size` as its controlling terminal, and logs the terminal's output to `logWriter`. Input can be written to the terminal with WriteTerminal until the command is done.
+func (group *ProcessGroupCommand) StartTerminal(logWriter io.Writer, size TerminalSize) error {
+	logger := log.WithFields(log.Fields{"func": "ProcessGroupCommand.StartTerminal"})
+
+	pty, tty, err := openPTY()
+	if err != nil {
+		logger.WithError(err).Error("unable to open pseudo-terminal")
+		return err
+	}
+	defer tty.Close() // the command holds its own copy
+
+	if err := resizePTY(pty, size); err != nil {
+		pty.Close()
+		return err
+	}
+	if credential := group.Cmd.SysProcAttr.Credential; credential != nil {
+		if err := tty.Chown(int(credential.Uid), int(credential.Gid)); err != nil {
+			pty.Close()
+			return err
+		}
+	}
+
+	// the new session is also a new process group, which is killed on stop as usual
+	group.Cmd.Stdin, group.Cmd.Stdout, group.Cmd.Stderr = tty, tty, tty
+	group.Cmd.SysProcAttr.Setpgid = false
+	group.Cmd.SysProcAttr.Setsid = true
+	group.Cmd.SysProcAttr.Setctty = true
+	group.Cmd.SysProcAttr.Ctty = 0
+
+	group.mu.Lock()
+	group.pty = pty
+	group.mu.Unlock()
+
+	// reads fail with EIO once no process holds the terminal anymore, or with ErrClosed once the terminal is closed
+	go func() {
+		defer close(group.ptyDrained)
+		io.Copy(logWriter, pty)
+	}()
+
+	if err := group.start(); err != nil {
+		pty.Close()
+		<-group.ptyDrained
+		return err
+	}
+
+	return nil
+}
+
+// WriteTerminal writes `data` to the input of the command's pseudo-terminal. It blocks until the terminal accepts it or `ctx` is done. It returns ErrNoTerminal if the command does not run in one, and ErrTerminalClosed if the command is done.
+func (group *ProcessGroupCommand) WriteTerminal(ctx context.Context, data []byte) error {
+	pty, err := group.getPTY()
+	if err != nil {
+		return err
+	}
+
+	err = writeContext(ctx, pty, data)
+	if errors.Is(err, os.ErrClosed) {
+		return ErrTerminalClosed
+	}
+
+	return err
+}
+
+// ResizeTerminal changes the size of the command's pseudo-terminal to `size`, which signals the command with SIGWINCH.
+func (group *ProcessGroupCommand) ResizeTerminal(size TerminalSize) error {
+	pty, err := group.getPTY()
+	if err != nil {
+		return err
+	}
+
+	err = resizePTY(pty, size)
+	if errors.Is(err, os.ErrClosed) {
+		return ErrTerminalClosed
+	}
+
+	return err
+}
+
+// getPTY returns the master side of the command's pseudo-terminal in a thread-safe way, or ErrNoTerminal if it has none.
+func (group *ProcessGroupCommand) getPTY() (*os.File, error) {
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+
+	if group.pty == nil {
+		return nil, ErrNoTerminal
+	}
+
+	return group.pty, nil
+}
+
+// start starts the command, and monitors it until it is done or stopped.
+func (group *ProcessGroupCommand) start() error {
+	logger := log.WithFields(log.Fields{"func": "ProcessGroupCommand.Start"})
+
 	err := group.Cmd.Start()
 	if err != nil {
 		logger.WithError(err).Error("unable to start command")
@@ -68,6 +167,16 @@ func (group *ProcessGroupCommand) Start(stdoutLogWriter io.Writer, stderrLogWrit
 			logger.WithError(err).Debug("the process has failed")
 		}
 
+		// the command is only done once its terminal output was logged. Background processes that still hold the terminal are cut off after a grace period
+		if pty, err := group.getPTY(); err == nil {
+			select {
+			case <-group.ptyDrained:
+			case <-time.After(ptyDrainTimeout):
+			}
+			pty.Close()
+			<-group.ptyDrained
+		}
+
 		// update doneAt and close done channel at end
 		close(group.Done)
 		doneAt := time.Now()
@@ -144,8 +253,8 @@ func (group *ProcessGroupCommand) Stop() bool {
 	return shared
 }
 
-// NewProcessGroupCommand returns a new ProcessGroupCommand that can execute `name` with `args`. The STDOUT and STDERR output of the process will be written to `stdoutLogWriter` and `stderrLogWriter`, respectively.
-func NewProcessGroupCommand(name string, args []string) *ProcessGroupCommand {
+// NewProcessGroupCommand returns a new ProcessGroupCommand that can execute `name` with `args` in the working directory `dir` and with the environment `env`. An empty `dir` or a nil `env` are inherited from the current process. The STDOUT and STDERR output of the process will be written to `stdoutLogWriter` and `stderrLogWriter`, respectively.
+func NewProcessGroupCommand(name string, args []string, dir string, env []string, credential *syscall.Credential) *ProcessGroupCommand {
 	// ?: Command might buffer output, which means the client would receive log data in large chunks. Is this ideal?
 	// ?: Loggers should only be attached at job start?
 
@@ -154,7 +263,9 @@ func NewProcessGroupCommand(name string, args []string) *ProcessGroupCommand {
 		name,
 		args...,
 	)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // make sure descendants are put in the same process group
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: credential} // make sure descendants are put in the same process group, and run as `credential` if it is set
+	cmd.Dir = dir
+	cmd.Env = env
 
 	return &ProcessGroupCommand{
 		Cmd:         cmd,
@@ -165,5 +276,6 @@ func NewProcessGroupCommand(name string, args []string) *ProcessGroupCommand {
 		stopMutex:   &sync.Mutex{},
 		stopped:     false,
 		group:       &singleflight.Group{},
+		ptyDrained:  make(chan struct{}),
 	}
 }
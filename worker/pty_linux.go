@@ -0,0 +1,57 @@
+//go:build linux
+// +build linux
+
+package worker
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY allocates a pseudo-terminal, and returns its master and slave sides. The master side is non-blocking, so that closing it interrupts reads.
+func openPTY() (*os.File, *os.File, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	pty := os.NewFile(uintptr(fd), "/dev/ptmx")
+
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		pty.Close()
+		return nil, nil, err
+	}
+	number, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		pty.Close()
+		return nil, nil, err
+	}
+
+	name := fmt.Sprintf("/dev/pts/%d", number)
+	tty, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close()
+		return nil, nil, err
+	}
+
+	return pty, tty, nil
+}
+
+// resizePTY sets the size of the pseudo-terminal whose master side is `pty`.
+func resizePTY(pty *os.File, size TerminalSize) error {
+	conn, err := pty.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{Row: size.Rows, Col: size.Cols})
+	})
+	if err != nil {
+		return err
+	}
+
+	return ioctlErr
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package worker
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	errPTYUnsupported = errors.New("terminals are only supported on Linux")
+)
+
+// openPTY is not supported on this platform.
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errPTYUnsupported
+}
+
+// resizePTY is not supported on this platform.
+func resizePTY(pty *os.File, size TerminalSize) error {
+	return errPTYUnsupported
+}
@@ -0,0 +1,544 @@
+package worker
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ociLayoutFile  = "oci-layout"   // ociLayoutFile marks a directory as an OCI image layout.
+	ociIndexFile   = "index.json"   // ociIndexFile is the entry point of an OCI image layout.
+	whiteoutPrefix = ".wh."         // whiteoutPrefix marks a layer entry that removes the file of the same name from the layers below.
+	whiteoutOpaque = ".wh..wh..opq" // whiteoutOpaque marks a layer directory that hides the contents of the same directory in the layers below.
+	maxSymlinks    = 255            // maxSymlinks is the number of symlinks that are followed when resolving a path in a root filesystem.
+)
+
+var (
+	ErrRootfsDoesNotExist = errors.New("the root filesystem does not exist")
+	ErrRootfsInvalidName  = errors.New("root filesystem names can not contain path separators or start with a dot")
+
+	sha256Digest = regexp.MustCompile(`^sha256:([a-f0-9]{64})$`) // sha256Digest matches the only digest algorithm supported in images.
+
+	// imageIndexMediaTypes are the media types of descriptors that point to another index rather than to an image manifest.
+	imageIndexMediaTypes = map[string]bool{"application/vnd.oci.image.index.v1+json": true, "application/vnd.docker.distribution.manifest.list.v2+json": true}
+)
+
+// Rootfs is a root filesystem that jobs can run in.
+type Rootfs struct {
+	Name   string // Name is the name that the root filesystem was requested by.
+	Path   string // Path is the absolute path of the directory that jobs see as their root. It is the lower layer of an overlay, so jobs can not modify it.
+	Digest string // Digest is the digest of the image manifest that the root filesystem was unpacked from, or empty if it is a plain directory.
+}
+
+// RootfsStore resolves root filesystems by name from a directory that contains plain directories, OCI image layouts and OCI image tarballs. Images are unpacked into a cache directory, keyed by the digest of their manifest.
+type RootfsStore struct {
+	dir      string
+	cacheDir string
+	mu       *sync.Mutex              // mu controls access to `locks` and `digests`. It is not held while images are hashed or unpacked.
+	locks    map[string]*sync.Mutex   // locks maps the archives and images that are being hashed or unpacked to their own lock, so that each is only unpacked once without holding up the others.
+	digests  map[string]archiveDigest // digests maps the paths of image archives to their digest, so that they are only hashed again once they change.
+}
+
+// archiveDigest is the digest of an image archive when it had a given size and modification time.
+type archiveDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// NewRootfsStore returns a new RootfsStore that serves root filesystems from `dir`, and unpacks images into `cacheDir`.
+func NewRootfsStore(dir string, cacheDir string) *RootfsStore {
+	return &RootfsStore{dir: dir, cacheDir: cacheDir, mu: &sync.Mutex{}, locks: map[string]*sync.Mutex{}, digests: map[string]archiveDigest{}}
+}
+
+// lock locks the lock of `key` for the caller, and returns the function that unlocks it.
+func (store *RootfsStore) lock(key string) func() {
+	store.mu.Lock()
+	keyMu, ok := store.locks[key]
+	if !ok {
+		keyMu = &sync.Mutex{}
+		store.locks[key] = keyMu
+	}
+	store.mu.Unlock()
+
+	keyMu.Lock()
+	return keyMu.Unlock
+}
+
+// Resolve returns the root filesystem named `name`, unpacking it first if it is an image that has not been unpacked yet.
+func (store *RootfsStore) Resolve(name string) (Rootfs, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return Rootfs{}, ErrRootfsInvalidName
+	}
+
+	rootfsPath := filepath.Join(store.dir, name)
+	info, err := os.Stat(rootfsPath)
+	if os.IsNotExist(err) {
+		return Rootfs{}, ErrRootfsDoesNotExist
+	}
+	if err != nil {
+		return Rootfs{}, err
+	}
+
+	if !info.IsDir() {
+		layoutDir, err := store.unpackArchive(rootfsPath)
+		if err != nil {
+			return Rootfs{}, fmt.Errorf("unable to unpack image archive: %w", err)
+		}
+		return store.unpackLayout(name, layoutDir)
+	}
+
+	if _, err := os.Stat(filepath.Join(rootfsPath, ociLayoutFile)); err == nil {
+		return store.unpackLayout(name, rootfsPath)
+	}
+
+	absPath, err := filepath.Abs(rootfsPath)
+	if err != nil {
+		return Rootfs{}, err
+	}
+
+	return Rootfs{Name: name, Path: absPath}, nil
+}
+
+// unpackArchive extracts the OCI image layout in the tarball at `archivePath`, optionally gzipped, and returns the directory it was extracted to. Archives are keyed by their digest, so they are only extracted once, and only hashed again once their size or modification time changes.
+func (store *RootfsStore) unpackArchive(archivePath string) (string, error) {
+	unlock := store.lock("archive:" + archivePath)
+	defer unlock()
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	store.mu.Lock()
+	cached, ok := store.digests[archivePath]
+	store.mu.Unlock()
+
+	digest := cached.digest
+	if !ok || cached.size != info.Size() || !cached.modTime.Equal(info.ModTime()) {
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, file); err != nil {
+			return "", err
+		}
+		digest = hex.EncodeToString(hasher.Sum(nil))
+
+		store.mu.Lock()
+		store.digests[archivePath] = archiveDigest{size: info.Size(), modTime: info.ModTime(), digest: digest}
+		store.mu.Unlock()
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	layoutDir := filepath.Join(store.cacheDir, "archives", digest)
+	if _, err := os.Stat(layoutDir); err == nil {
+		return layoutDir, nil
+	}
+
+	reader, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return "", err
+	}
+
+	return layoutDir, extractInto(layoutDir, store.cacheDir, func(dir string) error {
+		return extractTar(reader, dir, false)
+	})
+}
+
+// unpackLayout unpacks the image in the OCI image layout at `layoutDir` for the current platform, unless it was already unpacked, and returns its root filesystem.
+func (store *RootfsStore) unpackLayout(name string, layoutDir string) (Rootfs, error) {
+	logger := log.WithFields(log.Fields{"func": "RootfsStore.unpackLayout", "name": name})
+
+	index := ociIndex{}
+	if err := readJSON(filepath.Join(layoutDir, ociIndexFile), &index); err != nil {
+		return Rootfs{}, fmt.Errorf("unable to read image index: %w", err)
+	}
+
+	descriptor, err := selectManifest(layoutDir, index)
+	if err != nil {
+		return Rootfs{}, err
+	}
+
+	rootfsPath, err := filepath.Abs(filepath.Join(store.cacheDir, "images", sha256Digest.FindStringSubmatch(descriptor.Digest)[1]))
+	if err != nil {
+		return Rootfs{}, err
+	}
+	rootfs := Rootfs{Name: name, Path: rootfsPath, Digest: descriptor.Digest}
+
+	unlock := store.lock("image:" + descriptor.Digest)
+	defer unlock()
+
+	if _, err := os.Stat(rootfsPath); err == nil {
+		return rootfs, nil
+	}
+
+	manifest := ociManifest{}
+	if err := readBlobJSON(layoutDir, descriptor.Digest, &manifest); err != nil {
+		return Rootfs{}, fmt.Errorf("unable to read image manifest: %w", err)
+	}
+
+	logger.WithFields(log.Fields{"digest": descriptor.Digest, "layers": len(manifest.Layers)}).Info("unpacking image")
+	err = extractInto(rootfsPath, store.cacheDir, func(dir string) error {
+		for i, layer := range manifest.Layers {
+			if err := extractLayer(layoutDir, layer, dir); err != nil {
+				return fmt.Errorf("layers[%d]: %w", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Rootfs{}, err
+	}
+
+	return rootfs, nil
+}
+
+// ociDescriptor is a reference to a blob in an OCI image layout.
+type ociDescriptor struct {
+	MediaType string       `json:"mediaType"`
+	Digest    string       `json:"digest"`
+	Platform  *ociPlatform `json:"platform"`
+}
+
+// ociPlatform is the platform that an image manifest is built for.
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// ociIndex is an OCI image index, which lists image manifests.
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest is an OCI image manifest, which lists the layers of an image from the bottom up.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// selectManifest returns the descriptor of the first manifest in `index` for the current platform, or without a platform, following nested indexes.
+func selectManifest(layoutDir string, index ociIndex) (ociDescriptor, error) {
+	for _, descriptor := range index.Manifests {
+		if descriptor.Platform != nil && (descriptor.Platform.OS != "linux" || descriptor.Platform.Architecture != runtime.GOARCH) {
+			continue
+		}
+
+		if !imageIndexMediaTypes[descriptor.MediaType] {
+			if !sha256Digest.MatchString(descriptor.Digest) {
+				return ociDescriptor{}, fmt.Errorf("unsupported manifest digest %q", descriptor.Digest)
+			}
+			return descriptor, nil
+		}
+
+		nested := ociIndex{}
+		if err := readBlobJSON(layoutDir, descriptor.Digest, &nested); err != nil {
+			return ociDescriptor{}, fmt.Errorf("unable to read image index: %w", err)
+		}
+		if manifest, err := selectManifest(layoutDir, nested); err == nil {
+			return manifest, nil
+		}
+	}
+
+	return ociDescriptor{}, fmt.Errorf("the image has no manifest for linux/%s", runtime.GOARCH)
+}
+
+// blobPath returns the path of the blob with `digest` in the OCI image layout at `layoutDir`.
+func blobPath(layoutDir string, digest string) (string, error) {
+	match := sha256Digest.FindStringSubmatch(digest)
+	if match == nil {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+
+	return filepath.Join(layoutDir, "blobs", "sha256", match[1]), nil
+}
+
+// readJSON decodes the JSON file at `path` into `v`.
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// readBlobJSON decodes the JSON blob with `digest` into `v`, after checking that its content matches the digest.
+func readBlobJSON(layoutDir string, digest string, v interface{}) error {
+	blob, err := blobPath(layoutDir, digest)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(blob)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(data); "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return fmt.Errorf("blob %s does not match its digest", digest)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// extractLayer applies the layer described by `layer` to the root filesystem at `dir`, after checking that its content matches its digest.
+func extractLayer(layoutDir string, layer ociDescriptor, dir string) error {
+	blob, err := blobPath(layoutDir, layer.Digest)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	reader := bufio.NewReader(io.TeeReader(file, hasher))
+
+	var tarReader io.Reader
+	switch {
+	case strings.HasSuffix(layer.MediaType, "+gzip") || strings.HasSuffix(layer.MediaType, ".gzip"):
+		if tarReader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	case strings.HasSuffix(layer.MediaType, "tar"):
+		tarReader = reader
+	default:
+		return fmt.Errorf("unsupported layer media type %q", layer.MediaType)
+	}
+
+	if err := extractTar(tarReader, dir, true); err != nil {
+		return err
+	}
+
+	return checkDigest(reader, hasher, layer.Digest)
+}
+
+// checkDigest reads the rest of `reader`, and returns an error if the content hashed by `hasher` does not match `digest`.
+func checkDigest(reader io.Reader, hasher hash.Hash, digest string) error {
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return err
+	}
+	if "sha256:"+hex.EncodeToString(hasher.Sum(nil)) != digest {
+		return fmt.Errorf("blob %s does not match its digest", digest)
+	}
+
+	return nil
+}
+
+// decompress returns a reader of the gzip stream in `reader` if it starts with the gzip magic number, and `reader` itself otherwise.
+func decompress(reader *bufio.Reader) (io.Reader, error) {
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(reader)
+	}
+
+	return reader, nil
+}
+
+// extractInto runs `extract` on a new temporary directory in `tmpDir`, and moves the directory to `dir` if it succeeds, so that `dir` only exists once it is complete.
+func extractInto(dir string, tmpDir string, extract func(dir string) error) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir(tmpDir, "unpack-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// the temporary directory is created with mode 0700, but becomes the root directory of jobs
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+
+	if err := extract(tmp); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, dir)
+}
+
+// extractTar extracts the tar stream in `reader` into `root`. Entries can not be written outside of `root`, even through symlinks, since paths are resolved as if `root` was the root directory. If `whiteouts` is true, whiteout entries remove files from previous layers instead of being extracted. Device files are skipped.
+func extractTar(reader io.Reader, root string, whiteouts bool) error {
+	tarReader := tar.NewReader(reader)
+	extracted := map[string]bool{} // extracted contains the entries of this layer, which opaque whiteouts do not hide
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + header.Name)
+		if name == "/" {
+			continue
+		}
+		dir, base := path.Split(name)
+
+		parent, err := ResolveInRoot(root, dir)
+		if err != nil {
+			return err
+		}
+
+		if whiteouts && base == whiteoutOpaque {
+			entries, err := ioutil.ReadDir(parent)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			for _, entry := range entries {
+				if !extracted[path.Join(dir, entry.Name())] {
+					if err := os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if whiteouts && strings.HasPrefix(base, whiteoutPrefix) {
+			if err := os.RemoveAll(filepath.Join(parent, strings.TrimPrefix(base, whiteoutPrefix))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+		target := filepath.Join(parent, base)
+		extracted[name] = true
+
+		// entries replace whatever a previous layer had at the same path, except that directories are merged
+		if info, err := os.Lstat(target); err == nil && !(info.IsDir() && header.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tarReader); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := ResolveInRoot(root, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+
+		// ownership is only kept when running as root. It is set before the mode, since changing it clears setuid and setgid bits
+		if err := os.Lchown(target, header.Uid, header.Gid); err != nil && os.Geteuid() == 0 {
+			return err
+		}
+		if header.Typeflag != tar.TypeSymlink {
+			mode := header.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeFile writes the content of `reader` to a new file at `target`.
+func writeFile(target string, reader io.Reader) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// ResolveInRoot returns the path of `name` in the root filesystem at `root`, following symlinks as if `root` was the root directory, so that the result is always within `root`. Components that do not exist are kept as they are.
+func ResolveInRoot(root string, name string) (string, error) {
+	resolved := "/"
+	remaining := name
+
+	for links := 0; remaining != ""; {
+		component := remaining
+		remaining = ""
+		if i := strings.IndexByte(component, '/'); i >= 0 {
+			component, remaining = component[:i], component[i+1:]
+		}
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, component)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %q", name)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(root, resolved), nil
+}
@@ -0,0 +1,111 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	rootfsDevices = []string{"null", "zero", "full", "random", "urandom", "tty"} // rootfsDevices are the devices of the host that are bind mounted into root filesystems.
+)
+
+// enterRootfs mounts an overlay of the root filesystem with a writable layer in `mount.Dir`, along with /proc, a minimal /dev and the directories of the host in `binds` (by their path in the root filesystem), and makes it the root directory. The current thread must be in its own mount namespace.
+func enterRootfs(mount rootfsMount, binds map[string]string) error {
+	upper, work, merged := filepath.Join(mount.Dir, "upper"), filepath.Join(mount.Dir, "work"), filepath.Join(mount.Dir, "merged")
+	for _, dir := range []string{mount.Lower, upper, work} {
+		if strings.ContainsAny(dir, ",:") {
+			return fmt.Errorf("overlay directory %q can not contain commas or colons", dir)
+		}
+	}
+	for _, dir := range []string{upper, work, merged} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", mount.Lower, upper, work)
+	if err := unix.Mount("overlay", merged, "overlay", 0, options); err != nil {
+		return fmt.Errorf("unable to mount overlay: %w", err)
+	}
+
+	proc := filepath.Join(merged, "proc")
+	if err := os.MkdirAll(proc, 0555); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("unable to mount /proc: %w", err)
+	}
+
+	if err := mountDevices(filepath.Join(merged, "dev")); err != nil {
+		return fmt.Errorf("unable to mount /dev: %w", err)
+	}
+
+	for target, source := range binds {
+		mergedTarget := filepath.Join(merged, target)
+		if err := os.MkdirAll(mergedTarget, 0755); err != nil {
+			return err
+		}
+		if err := unix.Mount(source, mergedTarget, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("unable to mount %s: %w", target, err)
+		}
+	}
+
+	oldRoot := filepath.Join(merged, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(merged, oldRoot); err != nil {
+		return fmt.Errorf("unable to pivot root: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return err
+	}
+
+	return os.Remove("/.oldroot")
+}
+
+// mountDevices mounts a tmpfs at `dev`, and bind mounts rootfsDevices of the host into it.
+func mountDevices(dev string) error {
+	if err := os.MkdirAll(dev, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", dev, "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=755,size=64k"); err != nil {
+		return err
+	}
+
+	for _, device := range rootfsDevices {
+		source, target := filepath.Join("/dev", device), filepath.Join(dev, device)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			return err
+		}
+		file.Close()
+
+		if err := unix.Mount(source, target, "", unix.MS_BIND, ""); err != nil {
+			return err
+		}
+	}
+
+	for name, target := range map[string]string{"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0", "stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2"} {
+		if err := os.Symlink(target, filepath.Join(dev, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package worker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// tarEntry is an entry of a test layer. Entries with a link name are symlinks, and entries whose name ends with a slash are directories.
+type tarEntry struct {
+	name, content, linkname string
+}
+
+// writeBlob writes `data` to the blobs of the OCI image layout at `layoutDir`, and returns its digest.
+func writeBlob(t *testing.T, layoutDir string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hexSum := hex.EncodeToString(sum[:])
+	require.NoError(t, os.MkdirAll(filepath.Join(layoutDir, "blobs", "sha256"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(layoutDir, "blobs", "sha256", hexSum), data, 0644))
+
+	return "sha256:" + hexSum
+}
+
+// tarball returns a tar stream of `entries`.
+func tarball(t *testing.T, entries []tarEntry) []byte {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		switch {
+		case entry.linkname != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.linkname, 0
+		case entry.name[len(entry.name)-1] == '/':
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		}
+		require.NoError(t, tarWriter.WriteHeader(header))
+		_, err := tarWriter.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+
+	return buffer.Bytes()
+}
+
+// writeImage writes an OCI image layout with `layers` to `layoutDir`, with the first layer gzipped, and returns the digest of its manifest.
+func writeImage(t *testing.T, layoutDir string, layers ...[]tarEntry) string {
+	descriptors := []map[string]string{}
+	for i, layer := range layers {
+		data, mediaType := tarball(t, layer), "application/vnd.oci.image.layer.v1.tar"
+		if i == 0 {
+			buffer := &bytes.Buffer{}
+			gzipWriter := gzip.NewWriter(buffer)
+			_, err := gzipWriter.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, gzipWriter.Close())
+			data, mediaType = buffer.Bytes(), mediaType+"+gzip"
+		}
+		descriptors = append(descriptors, map[string]string{"mediaType": mediaType, "digest": writeBlob(t, layoutDir, data)})
+	}
+
+	manifest, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "layers": descriptors})
+	require.NoError(t, err)
+	manifestDigest := writeBlob(t, layoutDir, manifest)
+
+	index, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "manifests": []map[string]string{{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": manifestDigest}}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(layoutDir, "index.json"), index, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(layoutDir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+
+	return manifestDigest
+}
+
+// TestRootfsStore unpacks images from OCI image layouts and tarballs, and checks that whiteouts are applied, that layers can not write outside of the root filesystem, and that corrupted blobs are rejected.
+func TestRootfsStore(t *testing.T) {
+	t.Parallel()
+
+	imagesDir := t.TempDir()
+	store := worker.NewRootfsStore(imagesDir, t.TempDir())
+
+	digest := writeImage(t, filepath.Join(imagesDir, "image"),
+		[]tarEntry{{name: "etc/"}, {name: "etc/removed", content: "a"}, {name: "etc/kept", content: "b"}, {name: "opt/app/old", content: "c"}, {name: "escape", linkname: "/"}},
+		[]tarEntry{{name: "etc/.wh.removed"}, {name: "opt/app/.wh..wh..opq"}, {name: "opt/app/new", content: "d"}, {name: "escape/etc/escaped", content: "e"}, {name: "../../outside", content: "f"}},
+	)
+
+	rootfs, err := store.Resolve("image")
+	require.NoError(t, err)
+	require.Equal(t, digest, rootfs.Digest)
+	require.Equal(t, "image", rootfs.Name)
+
+	for name, content := range map[string]string{"etc/kept": "b", "opt/app/new": "d", "etc/escaped": "e", "outside": "f"} {
+		data, err := ioutil.ReadFile(filepath.Join(rootfs.Path, name))
+		require.NoError(t, err, name)
+		require.Equal(t, content, string(data), name)
+	}
+	for _, name := range []string{"etc/removed", "opt/app/old"} {
+		_, err := os.Stat(filepath.Join(rootfs.Path, name))
+		require.True(t, os.IsNotExist(err), name)
+	}
+
+	// unpacked images are reused
+	cached, err := store.Resolve("image")
+	require.NoError(t, err)
+	require.Equal(t, rootfs, cached)
+
+	// tarballs of image layouts are unpacked to the same image
+	archive := []tarEntry{}
+	require.NoError(t, filepath.Walk(filepath.Join(imagesDir, "image"), func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		name, err := filepath.Rel(filepath.Join(imagesDir, "image"), path)
+		require.NoError(t, err)
+		if info.IsDir() {
+			archive = append(archive, tarEntry{name: name + "/"})
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		archive = append(archive, tarEntry{name: name, content: string(data)})
+		return nil
+	}))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(imagesDir, "image.tar"), tarball(t, archive[1:]), 0644))
+	fromArchive, err := store.Resolve("image.tar")
+	require.NoError(t, err)
+	require.Equal(t, digest, fromArchive.Digest)
+
+	// archives are resolved concurrently, and only hashed again once they change
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := store.Resolve("image.tar")
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(imagesDir, "image.tar"), []byte("not an archive"), 0644))
+	_, err = store.Resolve("image.tar")
+	require.Error(t, err)
+
+	// plain directories are used as they are
+	require.NoError(t, os.Mkdir(filepath.Join(imagesDir, "plain"), 0755))
+	plain, err := store.Resolve("plain")
+	require.NoError(t, err)
+	require.Equal(t, "", plain.Digest)
+	require.True(t, filepath.IsAbs(plain.Path))
+
+	_, err = store.Resolve("missing")
+	require.ErrorIs(t, err, worker.ErrRootfsDoesNotExist)
+	_, err = store.Resolve("../image")
+	require.ErrorIs(t, err, worker.ErrRootfsInvalidName)
+
+	// layers that do not match their digest are rejected
+	corruptedDir := filepath.Join(imagesDir, "corrupted")
+	writeImage(t, corruptedDir, []tarEntry{{name: "file", content: "original"}})
+	blobs, err := filepath.Glob(filepath.Join(corruptedDir, "blobs", "sha256", "*"))
+	require.NoError(t, err)
+	for _, blob := range blobs {
+		data, err := ioutil.ReadFile(blob)
+		require.NoError(t, err)
+		if !bytes.HasPrefix(data, []byte("{")) {
+			require.NoError(t, ioutil.WriteFile(blob, []byte("corrupted"), 0644))
+		}
+	}
+	_, err = store.Resolve("corrupted")
+	require.Error(t, err)
+}
+
+// TestJobRootfs runs jobs in an overlay of the host's root filesystem, and checks that their changes do not reach the host.
+func TestJobRootfs(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("running jobs in a root filesystem requires root")
+	}
+
+	marker := filepath.Join("/", "worker-rootfs-test-"+filepath.Base(t.TempDir()))
+	rootfs := worker.Rootfs{Name: "host", Path: "/"}
+
+	job, output := runJob(t, "sh", []string{"-c", `echo changed > "$1" && cat "$1" && ls /dev`, "sh", marker}, worker.WithRootfs(rootfs))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Contains(t, output, "changed")
+	require.Contains(t, output, "null")
+	require.Equal(t, "host", job.Rootfs)
+
+	_, err := os.Stat(marker)
+	require.True(t, os.IsNotExist(err), "changes stay in the job's overlay")
+
+	// the shim switches to the job's credential after entering the root filesystem
+	job, output = runJob(t, "id", []string{"-u"}, worker.WithRootfs(rootfs), worker.WithCredential(65534, 65534, nil))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Equal(t, "65534\n", output)
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	log "github.com/sirupsen/logrus"
+)
+
+// Scheduler runs jobs in the order they were submitted, with at most a fixed number of them running at the same time.
+type Scheduler struct {
+	mu             *sync.Mutex  // mu controls access to `running`, `queue` and `positions`.
+	maxConcurrency int          // maxConcurrency is the largest number of jobs that run at the same time, or zero for no limit.
+	running        int          // running is the number of slots taken by jobs that were started and are not done.
+	queue          []*Job       // queue are the submitted jobs that wait for a free slot, first in line first. Jobs that are stopped while they wait are removed from it.
+	positions      map[*Job]int // positions are the positions of the jobs in `queue`, starting at 1, so that they can be looked up without walking the queue.
+}
+
+// NewScheduler returns a Scheduler that runs at most `maxConcurrency` jobs at the same time, or any number of them if it is zero.
+func NewScheduler(maxConcurrency int) *Scheduler {
+	return &Scheduler{mu: &sync.Mutex{}, maxConcurrency: maxConcurrency, positions: map[*Job]int{}}
+}
+
+// Submit queues a job with status QUEUED, which is started once the jobs submitted before it were started and a slot is free. If a slot is free right away, the job is started before Submit returns, and true and the error of Job.Start are returned. Queued jobs can be stopped with Job.Stop, which removes them from the queue.
+func (scheduler *Scheduler) Submit(job *Job) (bool, error) {
+	if job.GetJobStatus() != pb.JobStatus_QUEUED {
+		return false, ErrJobNotQueued
+	}
+
+	scheduler.mu.Lock()
+	if len(scheduler.queue) == 0 && scheduler.hasFreeSlot() {
+		scheduler.running++
+		scheduler.mu.Unlock()
+		return true, scheduler.run(job)
+	}
+	scheduler.queue = append(scheduler.queue, job)
+	scheduler.positions[job] = len(scheduler.queue)
+	scheduler.mu.Unlock()
+
+	// the job is queued before the callback is set, so that a job that is stopped in between is still removed
+	if !job.whenCancelled(func() { scheduler.remove(job) }) {
+		scheduler.remove(job)
+	}
+
+	log.WithFields(log.Fields{"func": "Scheduler.Submit", "jobKey": job.Key, "position": scheduler.Position(job)}).Debug("queued a job")
+
+	return false, nil
+}
+
+// Position returns the position of `job` in the queue, starting at 1 for the next job to run, or zero if the job is not waiting in it.
+func (scheduler *Scheduler) Position(job *Job) int {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	return scheduler.positions[job]
+}
+
+// hasFreeSlot returns true if another job can be started. The caller must hold `mu`.
+func (scheduler *Scheduler) hasFreeSlot() bool {
+	return scheduler.maxConcurrency == 0 || scheduler.running < scheduler.maxConcurrency
+}
+
+// remove removes `job` from the queue, if it waits in it.
+func (scheduler *Scheduler) remove(job *Job) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	position, ok := scheduler.positions[job]
+	if !ok {
+		return
+	}
+
+	copy(scheduler.queue[position-1:], scheduler.queue[position:])
+	scheduler.queue[len(scheduler.queue)-1] = nil
+	scheduler.queue = scheduler.queue[:len(scheduler.queue)-1]
+	delete(scheduler.positions, job)
+	scheduler.updatePositions(position - 1)
+}
+
+// updatePositions updates the positions of the jobs in the queue from index `from` on, after jobs in front of them left it. The caller must hold `mu`.
+func (scheduler *Scheduler) updatePositions(from int) {
+	for i, job := range scheduler.queue[from:] {
+		scheduler.positions[job] = from + i + 1
+	}
+}
+
+// run starts `job` in a slot that was taken for it, and frees the slot once the job is done, or right away if it could not be started.
+func (scheduler *Scheduler) run(job *Job) error {
+	if err := job.Start(); err != nil {
+		scheduler.mu.Lock()
+		scheduler.running--
+		scheduler.mu.Unlock()
+		return err
+	}
+
+	go func() {
+		<-job.Done
+
+		scheduler.mu.Lock()
+		scheduler.running--
+		scheduler.mu.Unlock()
+
+		scheduler.dispatch()
+	}()
+
+	return nil
+}
+
+// dispatch starts the jobs at the front of the queue while slots are free.
+func (scheduler *Scheduler) dispatch() {
+	logger := log.WithFields(log.Fields{"func": "Scheduler.dispatch"})
+
+	for {
+		scheduler.mu.Lock()
+		if len(scheduler.queue) == 0 || !scheduler.hasFreeSlot() {
+			scheduler.mu.Unlock()
+			return
+		}
+		job := scheduler.queue[0]
+		scheduler.queue[0] = nil
+		scheduler.queue = scheduler.queue[1:]
+		delete(scheduler.positions, job)
+		scheduler.updatePositions(0)
+		scheduler.running++
+		scheduler.mu.Unlock()
+
+		// jobs that were stopped right before they reached the front are not started, and free their slot again
+		err := scheduler.run(job)
+		if err != nil && !errors.Is(err, ErrJobNotQueued) {
+			logger.WithError(err).WithField("jobKey", job.Key).Error("unable to start queued job")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package worker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScheduler submits more jobs than the scheduler runs at the same time, and checks that they are started in order as slots are freed, and that queued jobs can be stopped.
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	store := worker.NewJobStore()
+	scheduler := worker.NewScheduler(1)
+
+	jobs := []*worker.Job{}
+	for i := 0; i < 4; i++ {
+		job, err := store.AddJob("me", "sleep", []string{"0.2"})
+		require.NoError(t, err)
+		started, err := scheduler.Submit(job)
+		require.NoError(t, err)
+		require.Equal(t, i == 0, started)
+		jobs = append(jobs, job)
+	}
+
+	// only the first job runs, and the others wait in order
+	require.Equal(t, pb.JobStatus_RUNNING, jobs[0].GetJobStatus())
+	for i, job := range jobs[1:] {
+		require.Equal(t, pb.JobStatus_QUEUED, job.GetJobStatus())
+		require.Equal(t, i+1, scheduler.Position(job))
+	}
+	require.Equal(t, 0, scheduler.Position(jobs[0]))
+	_, err := scheduler.Submit(jobs[0])
+	require.ErrorIs(t, err, worker.ErrJobNotQueued)
+
+	// a stopped job leaves the queue, and the jobs behind it move up
+	jobs[2].Stop()
+	<-jobs[2].Done
+	require.Equal(t, pb.JobStatus_STOPPED, jobs[2].GetJobStatus())
+	require.Equal(t, 0, scheduler.Position(jobs[2]))
+	require.Equal(t, 2, scheduler.Position(jobs[3]))
+	require.ErrorIs(t, jobs[2].Start(), worker.ErrJobNotQueued)
+
+	// the remaining jobs run one after the other, in the order they were submitted
+	<-jobs[0].Done
+	require.Eventually(t, func() bool { return scheduler.Position(jobs[3]) == 1 }, 5*time.Second, 10*time.Millisecond)
+	<-jobs[1].Done
+	select {
+	case <-jobs[3].Done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the last job was not run")
+	}
+
+	for _, job := range []*worker.Job{jobs[0], jobs[1], jobs[3]} {
+		require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+	}
+	require.False(t, jobs[1].GetFinishedAt().After(jobs[3].GetFinishedAt()))
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"path/filepath"
+)
+
+const (
+	SecretsEnv    = "WORKER_SECRETS" // SecretsEnv is the environment variable that holds the path of a job's secrets directory, if the job has secret files.
+	rootfsSecrets = "/run/secrets"   // rootfsSecrets is where the secrets directory of a job that runs in a root filesystem is mounted in it.
+)
+
+// Secret is a value from the server's secret store that a job's command can read, either from an environment variable or from a file in the job's secrets directory.
+type Secret struct {
+	Name  string // Name is the name of the secret in the secret store.
+	Env   string // Env is the environment variable that is set to the secret, if it is not empty.
+	File  string // File is the name of the file in the job's secrets directory that holds the secret, if it is not empty.
+	Value []byte
+}
+
+// WithSecrets makes `secrets` available to the job's command. Secret files are written to a tmpfs that is only mounted in the job's own mount namespace, which requires the shim and the current process to run as root. The job only keeps the values until it is started.
+func WithSecrets(secrets []Secret) JobOption {
+	return func(job *Job) {
+		job.secrets = append([]Secret{}, secrets...) // the values are cleared on start, which must not change the caller's secrets
+	}
+}
+
+// SecretRefs returns the job's secrets without their values.
+func (job *Job) SecretRefs() []Secret {
+	refs := make([]Secret, len(job.secrets))
+	for i, secret := range job.secrets {
+		refs[i] = Secret{Name: secret.Name, Env: secret.Env, File: secret.File}
+	}
+
+	return refs
+}
+
+// clearSecrets drops the values of the job's secrets, and the command's environment that holds them, once Start passed them on, so that they are not kept for as long as the job is.
+func (job *Job) clearSecrets() {
+	for i := range job.secrets {
+		job.secrets[i].Value = nil
+	}
+	job.group.Cmd.Env = nil
+}
+
+// secretsDirectory returns the path to the directory that the job's secret files are mounted at. It stays empty outside of the job's mount namespace.
+func (job *Job) secretsDirectory() string {
+	return filepath.Join(job.runDir, "secrets")
+}
+
+// secretsPath returns the path of the job's secrets directory as the job's command sees it.
+func (job *Job) secretsPath() string {
+	if job.rootfs != nil {
+		return rootfsSecrets
+	}
+
+	return absPath(job.secretsDirectory())
+}
+
+// secretFiles returns the contents of the job's secret files by file name.
+func (job *Job) secretFiles() map[string][]byte {
+	files := map[string][]byte{}
+	for _, secret := range job.secrets {
+		if secret.File != "" {
+			files[secret.File] = secret.Value
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,46 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountSecrets mounts a tmpfs at `dir` with the secret `files`, which only `credential`, or the current user if it is nil, can read. The tmpfs is read-only once the files are written.
+func mountSecrets(dir string, files map[string][]byte, credential *syscall.Credential) error {
+	uid, gid := os.Getuid(), os.Getgid()
+	if credential != nil {
+		uid, gid = int(credential.Uid), int(credential.Gid)
+	}
+
+	size := 0
+	for _, value := range files {
+		size += len(value) + os.Getpagesize()
+	}
+
+	flags := uintptr(unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC)
+	options := fmt.Sprintf("size=%d,mode=500,uid=%d,gid=%d", size, uid, gid)
+	if err := unix.Mount("tmpfs", dir, "tmpfs", flags, options); err != nil {
+		return err
+	}
+
+	for name, value := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, value, 0400); err != nil {
+			return err
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return unix.Mount("", dir, "", flags|unix.MS_REMOUNT|unix.MS_RDONLY, options)
+}
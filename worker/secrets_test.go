@@ -0,0 +1,61 @@
+package worker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobSecrets checks that jobs see their secrets in their environment and in their secrets directory, and that secret files never reach the host's disk.
+func TestJobSecrets(t *testing.T) {
+	t.Parallel()
+
+	secrets := []worker.Secret{{Name: "api-token", Env: "API_TOKEN", Value: []byte("s3cret")}}
+	job, output := runJob(t, "sh", []string{"-c", `echo "$API_TOKEN ${` + worker.SecretsEnv + `-unset}"`}, worker.WithSecrets(secrets))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Equal(t, "s3cret unset\n", output)
+	require.Equal(t, []worker.Secret{{Name: "api-token", Env: "API_TOKEN"}}, job.SecretRefs(), "secret refs do not hold values")
+
+	if os.Geteuid() != 0 {
+		t.Skip("mounting secret files requires root")
+	}
+
+	// jobs that run as another user need to traverse the store's directories, which the checkout's may not allow
+	dir, err := ioutil.TempDir("", "worker-secrets-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Chmod(dir, 0711))
+	store := worker.NewJobStore()
+	store.LogDir, store.WorkspaceDir = filepath.Join(dir, "jobs"), filepath.Join(dir, "workspaces")
+
+	secrets = []worker.Secret{{Name: "deploy-key", File: "id_ed25519", Value: []byte("private key")}}
+	script := `cat "$` + worker.SecretsEnv + `/id_ed25519" && stat -c %a "$` + worker.SecretsEnv + `/id_ed25519" && ! touch "$` + worker.SecretsEnv + `/other" 2>/dev/null`
+	for _, opts := range [][]worker.JobOption{
+		{worker.WithSecrets(secrets), worker.WithCredential(65534, 65534, nil)},
+		{worker.WithSecrets(secrets), worker.WithRootfs(worker.Rootfs{Name: "host", Path: "/"})},
+	} {
+		job, output := runJobInStore(t, store, "sh", []string{"-c", script}, opts...)
+		require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+		require.Equal(t, "private key400\n", output)
+
+		_, err := os.Stat(filepath.Join(filepath.Dir(job.WorkspaceDirectory()), "secrets"))
+		require.True(t, os.IsNotExist(err), "the secrets directory is removed after the job")
+	}
+
+	// the secrets directory stays empty on the host while the job runs
+	job, err = store.AddJob("me", "sleep", []string{"10"}, worker.WithSecrets(secrets))
+	require.NoError(t, err)
+	require.NoError(t, job.Start())
+	defer func() {
+		job.Stop()
+		<-job.Done
+	}()
+	files, err := ioutil.ReadDir(filepath.Join(filepath.Dir(job.WorkspaceDirectory()), "secrets"))
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
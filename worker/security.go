@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+var (
+	// DefaultBlockedSyscalls are the system calls that jobs can not use if their security profile does not list its own. They administer the host, escape or inspect other processes, or load code into the kernel.
+	DefaultBlockedSyscalls = []string{
+		"acct", "add_key", "bpf", "clock_adjtime", "clock_settime", "delete_module", "finit_module", "fsconfig", "fsmount", "fsopen", "fspick",
+		"init_module", "kexec_file_load", "kexec_load", "keyctl", "lookup_dcookie", "mount", "move_mount", "open_by_handle_at", "open_tree",
+		"perf_event_open", "pivot_root", "process_vm_readv", "process_vm_writev", "ptrace", "quotactl", "reboot", "request_key", "setdomainname",
+		"sethostname", "setns", "settimeofday", "swapoff", "swapon", "umount2", "unshare", "userfaultfd",
+	}
+
+	// DefaultReadOnlyPaths are the paths that jobs can read and execute if their filesystem access does not list its own: system binaries, libraries and configuration, and process information.
+	DefaultReadOnlyPaths = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc", "/proc", "/dev/random", "/dev/urandom", "/dev/zero"}
+
+	// DefaultReadWritePaths are the paths that jobs can write to if their filesystem access does not list its own, in addition to their own workspace.
+	DefaultReadWritePaths = []string{"/dev/null"}
+)
+
+// SecurityProfile restricts the privileges of a job's process. It is applied by a shim that re-executes the current binary before running the job's command, so binaries that start jobs with a profile must call RunShimIfRequested first thing in main.
+type SecurityProfile struct {
+	Capabilities    []string          `yaml:"capabilities" json:"capabilities"`         // Capabilities are the only capabilities that the job keeps, e.g. CAP_NET_BIND_SERVICE. Only jobs that run as root hold capabilities.
+	BlockedSyscalls []string          `yaml:"blocked_syscalls" json:"blocked_syscalls"` // BlockedSyscalls are the system calls that fail with EPERM. Blocking unshare also blocks creating namespaces with clone and clone3. If it is not set, DefaultBlockedSyscalls are blocked, and if it is empty, no system calls are filtered.
+	Filesystem      *FilesystemAccess `yaml:"filesystem" json:"filesystem"`             // Filesystem restricts the files that the job can access with Landlock, if it is set.
+}
+
+// FilesystemAccess lists the files and directories that a job can access. Access to a directory extends to everything beneath it, and paths that do not exist are ignored. The job can always write to its own workspace, and read its secret files, but not write to its log.
+type FilesystemAccess struct {
+	ReadOnly  []string `yaml:"read_only" json:"read_only"`   // ReadOnly are the paths that the job can read and execute. It defaults to DefaultReadOnlyPaths if it is not set.
+	ReadWrite []string `yaml:"read_write" json:"read_write"` // ReadWrite are the paths that the job can read, execute, write, create and remove. It defaults to DefaultReadWritePaths if it is not set.
+}
+
+// validate returns an error if a path is not absolute.
+func (access FilesystemAccess) validate() error {
+	for _, path := range append(append([]string{}, access.ReadOnly...), access.ReadWrite...) {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("filesystem path %q must be absolute", path)
+		}
+	}
+
+	return nil
+}
+
+// withDirectories returns the access with defaults filled in, and with read-write access to `dirs` added.
+func (access FilesystemAccess) withDirectories(dirs ...string) *FilesystemAccess {
+	readOnly, readWrite := access.ReadOnly, access.ReadWrite
+	if readOnly == nil {
+		readOnly = DefaultReadOnlyPaths
+	}
+	if readWrite == nil {
+		readWrite = DefaultReadWritePaths
+	}
+
+	return &FilesystemAccess{ReadOnly: readOnly, ReadWrite: append(append([]string{}, readWrite...), dirs...)}
+}
+
+// blockedSyscalls returns the system calls blocked by the profile.
+func (profile SecurityProfile) blockedSyscalls() []string {
+	if profile.BlockedSyscalls == nil {
+		return DefaultBlockedSyscalls
+	}
+	return profile.BlockedSyscalls
+}
+
+// WithSecurityProfile makes the job's process set no_new_privs, drop the capabilities that `profile` does not keep, restrict its filesystem access with Landlock, and install a seccomp filter for the system calls it blocks, before the command is executed.
+func WithSecurityProfile(profile SecurityProfile) JobOption {
+	return func(job *Job) {
+		job.security = &profile
+	}
+}
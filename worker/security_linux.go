@@ -0,0 +1,204 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	seccompRetKillProcess = 0x80000000 // seccompRetKillProcess kills the process, and is used for system calls of another architecture.
+	seccompRetErrno       = 0x00050000 // seccompRetErrno fails the system call with the errno in the lower 16 bits.
+	seccompRetAllow       = 0x7fff0000 // seccompRetAllow lets the system call through.
+	seccompDataNr         = 0          // seccompDataNr is the offset of the system call number in struct seccomp_data.
+	seccompDataArch       = 4          // seccompDataArch is the offset of the audit architecture in struct seccomp_data.
+	seccompDataArg0       = 16         // seccompDataArg0 is the offset of the lower half of the first system call argument in struct seccomp_data, on little-endian architectures.
+	x32SyscallBit         = 0x40000000 // x32SyscallBit marks system calls of the x32 ABI on amd64, which would otherwise bypass the filter.
+
+	// cloneNamespaceFlags are the flags of clone that create namespaces, like unshare does. CLONE_NEWTIME is left out, since clone reads that bit as part of the exit signal.
+	cloneNamespaceFlags = unix.CLONE_NEWNS | unix.CLONE_NEWCGROUP | unix.CLONE_NEWUTS | unix.CLONE_NEWIPC | unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWNET
+)
+
+var (
+	// auditArch is the audit architecture of system calls made by this binary.
+	auditArch = map[string]uint32{"amd64": 0xc000003e, "arm64": 0xc00000b7}[runtime.GOARCH]
+
+	// capabilities maps capability names to their numbers.
+	capabilities = map[string]int{
+		"CAP_CHOWN": unix.CAP_CHOWN, "CAP_DAC_OVERRIDE": unix.CAP_DAC_OVERRIDE, "CAP_DAC_READ_SEARCH": unix.CAP_DAC_READ_SEARCH, "CAP_FOWNER": unix.CAP_FOWNER,
+		"CAP_FSETID": unix.CAP_FSETID, "CAP_KILL": unix.CAP_KILL, "CAP_SETGID": unix.CAP_SETGID, "CAP_SETUID": unix.CAP_SETUID, "CAP_SETPCAP": unix.CAP_SETPCAP,
+		"CAP_LINUX_IMMUTABLE": unix.CAP_LINUX_IMMUTABLE, "CAP_NET_BIND_SERVICE": unix.CAP_NET_BIND_SERVICE, "CAP_NET_BROADCAST": unix.CAP_NET_BROADCAST,
+		"CAP_NET_ADMIN": unix.CAP_NET_ADMIN, "CAP_NET_RAW": unix.CAP_NET_RAW, "CAP_IPC_LOCK": unix.CAP_IPC_LOCK, "CAP_IPC_OWNER": unix.CAP_IPC_OWNER,
+		"CAP_SYS_MODULE": unix.CAP_SYS_MODULE, "CAP_SYS_RAWIO": unix.CAP_SYS_RAWIO, "CAP_SYS_CHROOT": unix.CAP_SYS_CHROOT, "CAP_SYS_PTRACE": unix.CAP_SYS_PTRACE,
+		"CAP_SYS_PACCT": unix.CAP_SYS_PACCT, "CAP_SYS_ADMIN": unix.CAP_SYS_ADMIN, "CAP_SYS_BOOT": unix.CAP_SYS_BOOT, "CAP_SYS_NICE": unix.CAP_SYS_NICE,
+		"CAP_SYS_RESOURCE": unix.CAP_SYS_RESOURCE, "CAP_SYS_TIME": unix.CAP_SYS_TIME, "CAP_SYS_TTY_CONFIG": unix.CAP_SYS_TTY_CONFIG, "CAP_MKNOD": unix.CAP_MKNOD,
+		"CAP_LEASE": unix.CAP_LEASE, "CAP_AUDIT_WRITE": unix.CAP_AUDIT_WRITE, "CAP_AUDIT_CONTROL": unix.CAP_AUDIT_CONTROL, "CAP_SETFCAP": unix.CAP_SETFCAP,
+		"CAP_MAC_OVERRIDE": unix.CAP_MAC_OVERRIDE, "CAP_MAC_ADMIN": unix.CAP_MAC_ADMIN, "CAP_SYSLOG": unix.CAP_SYSLOG, "CAP_WAKE_ALARM": unix.CAP_WAKE_ALARM,
+		"CAP_BLOCK_SUSPEND": unix.CAP_BLOCK_SUSPEND, "CAP_AUDIT_READ": unix.CAP_AUDIT_READ, "CAP_PERFMON": unix.CAP_PERFMON, "CAP_BPF": unix.CAP_BPF,
+		"CAP_CHECKPOINT_RESTORE": unix.CAP_CHECKPOINT_RESTORE,
+	}
+
+	// syscalls maps the names of system calls that can be blocked to their numbers.
+	syscalls = map[string]uint32{
+		"acct": unix.SYS_ACCT, "add_key": unix.SYS_ADD_KEY, "bpf": unix.SYS_BPF, "clock_adjtime": unix.SYS_CLOCK_ADJTIME, "clock_settime": unix.SYS_CLOCK_SETTIME,
+		"delete_module": unix.SYS_DELETE_MODULE, "finit_module": unix.SYS_FINIT_MODULE, "fsconfig": unix.SYS_FSCONFIG, "fsmount": unix.SYS_FSMOUNT,
+		"fsopen": unix.SYS_FSOPEN, "fspick": unix.SYS_FSPICK, "init_module": unix.SYS_INIT_MODULE, "kexec_file_load": unix.SYS_KEXEC_FILE_LOAD,
+		"kexec_load": unix.SYS_KEXEC_LOAD, "keyctl": unix.SYS_KEYCTL, "lookup_dcookie": unix.SYS_LOOKUP_DCOOKIE, "mount": unix.SYS_MOUNT,
+		"move_mount": unix.SYS_MOVE_MOUNT, "open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT, "open_tree": unix.SYS_OPEN_TREE,
+		"perf_event_open": unix.SYS_PERF_EVENT_OPEN, "pivot_root": unix.SYS_PIVOT_ROOT, "process_vm_readv": unix.SYS_PROCESS_VM_READV,
+		"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV, "ptrace": unix.SYS_PTRACE, "quotactl": unix.SYS_QUOTACTL, "reboot": unix.SYS_REBOOT,
+		"request_key": unix.SYS_REQUEST_KEY, "setdomainname": unix.SYS_SETDOMAINNAME, "sethostname": unix.SYS_SETHOSTNAME, "setns": unix.SYS_SETNS,
+		"settimeofday": unix.SYS_SETTIMEOFDAY, "swapoff": unix.SYS_SWAPOFF, "swapon": unix.SYS_SWAPON, "umount2": unix.SYS_UMOUNT2,
+		"unshare": unix.SYS_UNSHARE, "userfaultfd": unix.SYS_USERFAULTFD,
+	}
+)
+
+// Validate returns an error if the profile names an unknown capability or system call.
+func (profile SecurityProfile) Validate() error {
+	for _, name := range profile.Capabilities {
+		if _, ok := capabilities[strings.ToUpper(name)]; !ok {
+			return fmt.Errorf("unknown capability %q", name)
+		}
+	}
+
+	for _, name := range profile.BlockedSyscalls {
+		if _, ok := syscalls[name]; !ok {
+			return fmt.Errorf("unknown or unsupported system call %q", name)
+		}
+	}
+
+	if profile.Filesystem != nil {
+		return profile.Filesystem.validate()
+	}
+
+	return nil
+}
+
+// applySecurityProfile applies `profile` to the current thread: it drops capabilities, sets no_new_privs, restricts filesystem access and installs a seccomp filter, in that order.
+func applySecurityProfile(profile SecurityProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	if err := dropCapabilities(profile.Capabilities); err != nil {
+		return fmt.Errorf("unable to drop capabilities: %w", err)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("unable to set no_new_privs: %w", err)
+	}
+
+	if profile.Filesystem != nil {
+		if err := restrictFilesystem(*profile.Filesystem); err != nil {
+			return fmt.Errorf("unable to restrict filesystem access: %w", err)
+		}
+	}
+
+	if blocked := profile.blockedSyscalls(); len(blocked) > 0 {
+		if err := installSeccompFilter(blocked); err != nil {
+			return fmt.Errorf("unable to install seccomp filter: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dropCapabilities clears the ambient capabilities, and removes every capability except `keep` from the bounding, effective, permitted and inheritable sets. The bounding set is only changed if the process holds CAP_SETPCAP, which it otherwise can not gain back anyway.
+func dropCapabilities(keep []string) error {
+	var keepMask uint64
+	for _, name := range keep {
+		keepMask |= 1 << uint(capabilities[strings.ToUpper(name)])
+	}
+
+	// kernels before 4.3 do not support ambient capabilities
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && err != unix.EINVAL {
+		return err
+	}
+
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return err
+	}
+
+	if data[0].Effective&(1<<unix.CAP_SETPCAP) != 0 {
+		for capability := 0; capability <= lastCapability(); capability++ {
+			if keepMask&(1<<uint(capability)) != 0 {
+				continue
+			}
+			if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range data {
+		mask := uint32(keepMask >> (32 * uint(i)))
+		data[i].Effective &= mask
+		data[i].Permitted &= mask
+		data[i].Inheritable &= mask
+	}
+
+	return unix.Capset(&header, &data[0])
+}
+
+// lastCapability returns the highest capability number that the kernel supports.
+func lastCapability() int {
+	data, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+
+	return last
+}
+
+// installSeccompFilter installs a seccomp-BPF filter that fails the `blocked` system calls with EPERM, and kills the process on system calls of another architecture. If unshare is blocked, creating namespaces with clone fails with EPERM as well, and clone3 fails with ENOSYS, since its flags can not be inspected by the filter. C libraries then fall back to clone.
+func installSeccompFilter(blocked []string) error {
+	deny := unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetErrno | uint32(unix.EPERM)}
+
+	filter := []unix.SockFilter{
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataArch},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: auditArch, Jt: 1},
+		{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetKillProcess},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataNr},
+	}
+	if runtime.GOARCH == "amd64" {
+		filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, K: x32SyscallBit, Jf: 1}, deny)
+	}
+	blocksNamespaces := false
+	for _, name := range blocked {
+		filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: syscalls[name], Jf: 1}, deny)
+		blocksNamespaces = blocksNamespaces || name == "unshare"
+	}
+	if blocksNamespaces {
+		filter = append(filter,
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: unix.SYS_CLONE3, Jf: 1},
+			unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetErrno | uint32(unix.ENOSYS)},
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: unix.SYS_CLONE, Jf: 3},
+			unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataArg0},
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, K: cloneNamespaceFlags, Jf: 1},
+			deny,
+		)
+	}
+	filter = append(filter, unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetAllow})
+
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0)
+	runtime.KeepAlive(filter)
+
+	return err
+}
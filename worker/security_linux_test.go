@@ -0,0 +1,64 @@
+package worker_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+const cloneNewUserArg = "clone-newuser" // cloneNewUserArg makes the test binary try to create a user namespace with clone instead of running the tests.
+
+// init lets jobs run the test binary to create a user namespace with clone, which unshare does not cover.
+func init() {
+	if len(os.Args) != 2 || os.Args[1] != cloneNewUserArg {
+		return
+	}
+
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWUSER}
+	if err := cmd.Run(); err != nil {
+		fmt.Println("blocked:", err)
+		os.Exit(0)
+	}
+
+	fmt.Println("created a user namespace")
+	os.Exit(0)
+}
+
+// TestJobSecurityProfileNamespaces checks that blocking unshare also keeps jobs from creating namespaces with clone.
+func TestJobSecurityProfileNamespaces(t *testing.T) {
+	t.Parallel()
+
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+
+	job, output := runJob(t, testBinary, []string{cloneNewUserArg}, worker.WithSecurityProfile(worker.SecurityProfile{}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Contains(t, output, "blocked: fork/exec")
+
+	// other processes can still be started
+	job, output = runJob(t, "sh", []string{"-c", "sh -c 'echo child'"}, worker.WithSecurityProfile(worker.SecurityProfile{}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Equal(t, "child\n", output)
+}
+
+// TestJobShimEnvironment checks that the job's environment only applies to its command, and not to the shim that sets it up as root.
+func TestJobShimEnvironment(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"LD_PRELOAD": "/nonexistent/preload.so", "GODEBUG": "inittrace=1"}
+	job, output := runJob(t, "sh", []string{"-c", `echo "$GODEBUG"`}, worker.WithEnv(env), worker.WithSecurityProfile(worker.SecurityProfile{}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+
+	// the dynamic loader of the command reports the missing library once, and the runtime of the shim does not trace its initialization
+	require.LessOrEqual(t, strings.Count(output, "/nonexistent/preload.so"), 1, output)
+	require.NotRegexp(t, `(?m)^init `, output)
+	require.True(t, strings.HasSuffix(output, "inittrace=1\n"), output)
+}
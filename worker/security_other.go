@@ -0,0 +1,13 @@
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package worker
+
+import (
+	"errors"
+)
+
+// Validate always returns an error, since security profiles are not supported on this platform.
+func (profile SecurityProfile) Validate() error {
+	return errors.New("security profiles are only supported on linux/amd64 and linux/arm64")
+}
@@ -0,0 +1,84 @@
+package worker_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the shim of jobs with a security profile.
+func TestMain(m *testing.M) {
+	worker.RunShimIfRequested()
+	os.Exit(m.Run())
+}
+
+// TestJobSecurityProfile runs a job with a security profile, and checks that it can not gain privileges, only holds the kept capabilities, and can not use blocked system calls.
+func TestJobSecurityProfile(t *testing.T) {
+	t.Parallel()
+
+	profile := worker.SecurityProfile{Capabilities: []string{"CAP_NET_BIND_SERVICE"}}
+	job, output := runJob(t, "sh", []string{"-c", `grep -E "^(CapEff|CapBnd|CapAmb|NoNewPrivs|Seccomp):" /proc/self/status; unshare --user true 2>/dev/null || echo blocked`}, worker.WithSecurityProfile(profile))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+
+	status := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			status[strings.TrimSuffix(fields[0], ":")] = fields[1]
+		}
+	}
+	require.Equal(t, "1", status["NoNewPrivs"])
+	require.Equal(t, "2", status["Seccomp"], "a seccomp filter is installed")
+	require.Equal(t, "0000000000000000", status["CapAmb"])
+	require.Contains(t, output, "blocked")
+	if os.Geteuid() == 0 {
+		require.Equal(t, "0000000000000400", status["CapBnd"], "only CAP_NET_BIND_SERVICE is kept")
+		require.Equal(t, "0000000000000400", status["CapEff"], "only CAP_NET_BIND_SERVICE is kept")
+	}
+
+	// an empty list of blocked system calls installs no filter
+	job, output = runJob(t, "grep", []string{"Seccomp:", "/proc/self/status"}, worker.WithSecurityProfile(worker.SecurityProfile{BlockedSyscalls: []string{}}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Equal(t, "0", strings.TrimSpace(strings.TrimPrefix(output, "Seccomp:")))
+
+	// the job fails without running its command if the profile can not be applied
+	job, output = runJob(t, "echo", []string{"unreachable"}, worker.WithSecurityProfile(worker.SecurityProfile{BlockedSyscalls: []string{"no_such_syscall"}}))
+	require.Equal(t, pb.JobStatus_FAILED, job.GetJobStatus())
+	require.Equal(t, int32(126), job.GetExitCode())
+	require.NotContains(t, output, "unreachable")
+	require.Error(t, worker.SecurityProfile{Capabilities: []string{"CAP_NO_SUCH_CAPABILITY"}}.Validate())
+}
+
+// TestJobFilesystemAccess runs a job whose filesystem access is restricted, and checks that it can run system binaries and write to declared paths, but can not read the output of another job.
+func TestJobFilesystemAccess(t *testing.T) {
+	t.Parallel()
+
+	other, _ := runJob(t, "echo", []string{"secret"})
+	writable, readable := t.TempDir(), t.TempDir()
+
+	access := &worker.FilesystemAccess{ReadOnly: append([]string{readable}, worker.DefaultReadOnlyPaths...), ReadWrite: []string{writable, "/dev/null"}}
+	script := `cat "$1" || echo denied; echo written > "$2/file" && cat "$2/file"; echo created > "$3/file" || echo read-only`
+	job, output := runJob(t, "sh", []string{"-c", script, "sh", other.LogFilepath(), writable, readable}, worker.WithSecurityProfile(worker.SecurityProfile{Filesystem: access}))
+	if strings.Contains(output, "landlock is not supported") {
+		t.Skip("landlock is not supported by the kernel")
+	}
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.NotContains(t, output, "secret")
+	require.Contains(t, output, "denied")
+	require.Contains(t, output, "written")
+	require.Contains(t, output, "read-only")
+
+	// the job can write to its workspace, but can not open its own log, which it writes to through the stderr it inherited
+	script = `log=$(readlink /proc/self/fd/2); echo tampered >> "$log" || echo denied; echo written > file && cat file`
+	job, output = runJob(t, "sh", []string{"-c", script}, worker.WithSecurityProfile(worker.SecurityProfile{Filesystem: &worker.FilesystemAccess{}}))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Contains(t, output, "denied")
+	require.Contains(t, output, "written")
+	require.NotContains(t, output, "tampered")
+
+	// relative paths are rejected
+	require.Error(t, worker.SecurityProfile{Filesystem: &worker.FilesystemAccess{ReadOnly: []string{"usr"}}}.Validate())
+}
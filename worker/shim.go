@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"encoding/json"
+	"os"
+	"syscall"
+)
+
+const (
+	shimArg      = "worker-shim"    // shimArg is the first argument of the re-executed server binary, which marks it as the shim.
+	shimPath     = "/proc/self/exe" // shimPath is the path that the shim is re-executed from, which always refers to the current binary.
+	shimConfigFd = 3                // shimConfigFd is the file descriptor that the shim reads its configuration from.
+)
+
+// shimEnv is the environment that the shim itself runs in. It is fixed, since the shim runs as root until it executes the job's command, and variables such as LD_PRELOAD or GODEBUG would change how it runs.
+var shimEnv = []string{}
+
+// shimConfig is what the shim sets up before it executes a job's command.
+type shimConfig struct {
+	Rootfs     *rootfsMount        `json:"rootfs,omitempty"`     // Rootfs is the root filesystem that the command runs in, if it is set.
+	Credential *syscall.Credential `json:"credential,omitempty"` // Credential is the user and groups that the command runs as, if it is set. The shim switches to them itself, since it needs root to enter the root filesystem and the workspace.
+	Security   *SecurityProfile    `json:"security,omitempty"`   // Security restricts the privileges of the command, if it is set.
+	Workspace  string              `json:"workspace"`            // Workspace is the directory of the job's workspace, which the command runs in.
+	TmpfsSize  uint64              `json:"tmpfs_size,omitempty"` // TmpfsSize is the size cap in bytes of a tmpfs mounted at Workspace, or zero to not mount one.
+	WorkingDir string              `json:"working_dir"`          // WorkingDir is the working directory of the command, as the command sees it.
+	Path       string              `json:"path"`                 // Path is the PATH that the command is looked up in, which is the server's rather than the job's.
+	Secrets    string              `json:"secrets,omitempty"`    // Secrets is the directory that a tmpfs with SecretFiles is mounted at, if it is set.
+
+	Env         []string          `json:"env"`                    // Env is the environment of the command, which the shim only sets when it executes the command.
+	SecretFiles map[string][]byte `json:"secret_files,omitempty"` // SecretFiles are the contents of the job's secret files by file name.
+}
+
+// rootfsMount is a root filesystem to mount for a single job.
+type rootfsMount struct {
+	Lower string `json:"lower"` // Lower is the directory of the root filesystem, which is the read-only lower layer of an overlay.
+	Dir   string `json:"dir"`   // Dir is the directory that the overlay's upper layer and mount point are created in.
+}
+
+// shimConfig returns the configuration of the shim that runs the job's command, or nil if the command does not need a shim.
+func (job *Job) shimConfig() *shimConfig {
+	hasSecretFiles := len(job.secretFiles()) > 0
+	if job.security == nil && job.rootfs == nil && job.tmpfsSize == 0 && !hasSecretFiles && (job.credential == nil || !shimSupported) {
+		return nil
+	}
+
+	// jobs can only write to their workspace, and read their secret files. They write their output through descriptors that were opened before the ruleset was applied, so that they can not rewrite their log
+	dirs := []string{absPath(job.WorkspaceDirectory())}
+	config := &shimConfig{
+		Credential: job.credential,
+		Workspace:  absPath(job.WorkspaceDirectory()),
+		TmpfsSize:  job.tmpfsSize,
+		WorkingDir: job.WorkingDir,
+		Path:       os.Getenv("PATH"),
+		Env:        job.environ(),
+	}
+	if hasSecretFiles {
+		config.Secrets = absPath(job.secretsDirectory())
+		config.SecretFiles = job.secretFiles()
+		dirs = append(dirs, config.Secrets)
+	}
+	if job.rootfs != nil {
+		config.Rootfs = &rootfsMount{Lower: job.rootfs.Path, Dir: absPath(job.rootfsDirectory())}
+		dirs = []string{rootfsWorkspace}
+		if hasSecretFiles {
+			dirs = append(dirs, rootfsSecrets)
+		}
+	}
+	if job.security != nil {
+		profile := *job.security
+		if profile.Filesystem != nil {
+			profile.Filesystem = profile.Filesystem.withDirectories(dirs...)
+		}
+		config.Security = &profile
+	}
+
+	return config
+}
+
+// shimCommand returns the name and arguments that run `name` with `args` through the shim. The shim reads its configuration from shimConfigFd, so that the command's environment and secret files never appear in its arguments.
+func shimCommand(name string, args []string) (string, []string) {
+	return shimPath, append([]string{shimArg, name}, args...)
+}
+
+// pipeShimConfig passes `config` to the job's shim on shimConfigFd. The returned function must be called once the shim was started or failed to start, and it writes the configuration in the background. It does nothing if `config` is nil.
+func (job *Job) pipeShimConfig(config *shimConfig) (func(), error) {
+	if config == nil {
+		return func() {}, nil
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	job.group.Cmd.ExtraFiles = []*os.File{reader} // the first extra file is shimConfigFd in the child
+
+	return func() {
+		reader.Close()
+		go func() {
+			defer writer.Close()
+			json.NewEncoder(writer).Encode(config) // fails if the shim exited without reading its configuration, which it reports itself
+		}()
+	}, nil
+}
+
+// readShimConfig reads the configuration that the server passed to the shim on shimConfigFd, and closes it so that the command does not inherit it.
+func readShimConfig() (shimConfig, error) {
+	file := os.NewFile(shimConfigFd, "config")
+	defer file.Close()
+
+	config := shimConfig{}
+	err := json.NewDecoder(file).Decode(&config)
+
+	return config, err
+}
+
+// RunShimIfRequested sets up the job's process and executes the job's command if the current process was started as a job's shim, and does nothing otherwise. It does not return in the shim, and exits with code 126 if the process can not be set up.
+func RunShimIfRequested() {
+	if len(os.Args) < 3 || os.Args[1] != shimArg {
+		return
+	}
+
+	config, err := readShimConfig()
+	if err != nil {
+		shimFail(err)
+	}
+
+	shimFail(runShim(config, os.Args[2], os.Args[3:]))
+}
+
+// shimFail reports that the shim could not run the job's command in the job's output, and exits.
+func shimFail(err error) {
+	os.Stderr.WriteString("worker-shim: " + err.Error() + "\n")
+	os.Exit(126)
+}
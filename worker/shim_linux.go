@@ -0,0 +1,109 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// shimSupported is true, since the shim can run jobs on this platform. Jobs that run as another user are started through it.
+const shimSupported = true
+
+// runShim sets up the current process as described by `config`, and replaces it with `name` run with `args`. It only returns if either fails.
+func runShim(config shimConfig, name string, args []string) error {
+	// mount namespaces, credentials, capabilities, no_new_privs and seccomp filters are set per thread, so they must be set on the thread that executes the command
+	runtime.LockOSThread()
+
+	if config.Rootfs != nil || config.TmpfsSize > 0 || config.Secrets != "" {
+		if err := unshareMounts(); err != nil {
+			return fmt.Errorf("unable to create mount namespace: %w", err)
+		}
+	}
+
+	if config.TmpfsSize > 0 {
+		if err := mountWorkspaceTmpfs(config.Workspace, config.TmpfsSize, config.Credential); err != nil {
+			return fmt.Errorf("unable to mount workspace: %w", err)
+		}
+	}
+
+	binds := map[string]string{rootfsWorkspace: config.Workspace}
+	if config.Secrets != "" {
+		if err := mountSecrets(config.Secrets, config.SecretFiles, config.Credential); err != nil {
+			return fmt.Errorf("unable to mount secrets: %w", err)
+		}
+		binds[rootfsSecrets] = config.Secrets
+	}
+
+	if config.Rootfs != nil {
+		if err := enterRootfs(*config.Rootfs, binds); err != nil {
+			return fmt.Errorf("unable to enter root filesystem: %w", err)
+		}
+	}
+
+	// commands are looked up like for jobs without a shim, in the server's PATH rather than in the job's environment, and after entering the root filesystem so that they are found in it
+	if err := os.Setenv("PATH", config.Path); err != nil {
+		return err
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+
+	// the workspace is entered as root, and a working directory in it is changed to relative to it, so that the user that the command runs as does not need to be able to search the directories above the workspace
+	workspace := config.Workspace
+	if config.Rootfs != nil {
+		workspace = rootfsWorkspace
+	}
+	if err := unix.Chdir(workspace); err != nil {
+		return fmt.Errorf("unable to change to workspace: %w", err)
+	}
+	workingDir := config.WorkingDir
+	if rel, err := filepath.Rel(workspace, workingDir); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		workingDir = rel
+	}
+
+	if credential := config.Credential; credential != nil {
+		groups := make([]int, len(credential.Groups))
+		for i, group := range credential.Groups {
+			groups[i] = int(group)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			return fmt.Errorf("unable to set groups: %w", err)
+		}
+		if err := syscall.Setgid(int(credential.Gid)); err != nil {
+			return fmt.Errorf("unable to set gid: %w", err)
+		}
+		if err := syscall.Setuid(int(credential.Uid)); err != nil {
+			return fmt.Errorf("unable to set uid: %w", err)
+		}
+	}
+
+	// a tmpfs workspace hides the working directory that was created in the workspace on disk
+	if config.TmpfsSize > 0 {
+		if err := os.MkdirAll(workingDir, 0700); err != nil {
+			return err
+		}
+	}
+	if err := unix.Chdir(workingDir); err != nil {
+		return fmt.Errorf("unable to change to working directory: %w", err)
+	}
+
+	if config.Security != nil {
+		if err := applySecurityProfile(*config.Security); err != nil {
+			return err
+		}
+	}
+
+	// the job's environment only applies to the command, since the shim runs as root until here
+	return unix.Exec(path, append([]string{name}, args...), config.Env)
+}
@@ -0,0 +1,16 @@
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package worker
+
+import (
+	"errors"
+)
+
+// shimSupported is false, since the shim can not run jobs on this platform. Jobs that run as another user are started without it.
+const shimSupported = false
+
+// runShim always returns an error, since security profiles, root filesystems and tmpfs workspaces are not supported on this platform.
+func runShim(config shimConfig, name string, args []string) error {
+	return errors.New("security profiles, root filesystems and tmpfs workspaces are only supported on linux/amd64 and linux/arm64")
+}
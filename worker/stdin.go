@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+)
+
+var (
+	ErrNoStdin     = errors.New("the job was not started with stdin")
+	ErrStdinClosed = errors.New("the job's stdin is not open")
+	ErrStdinBusy   = errors.New("another client is writing to the job's stdin")
+)
+
+// WithStdin connects the job's stdin to a pipe that can be written to with WriteStdin until it is closed with CloseStdin. Otherwise, the job's stdin is the null device. It has no effect on jobs with a terminal.
+func WithStdin() JobOption {
+	return func(job *Job) {
+		job.Stdin = true
+	}
+}
+
+// AcquireStdin reserves the job's stdin for a single writer, so that the input of different clients is not interleaved. The returned function releases it.
+func (job *Job) AcquireStdin() (func(), error) {
+	if !job.Stdin {
+		return nil, ErrNoStdin
+	}
+
+	job.stdinMu.Lock()
+	defer job.stdinMu.Unlock()
+
+	if job.stdinBusy {
+		return nil, ErrStdinBusy
+	}
+	job.stdinBusy = true
+
+	return func() {
+		job.stdinMu.Lock()
+		defer job.stdinMu.Unlock()
+		job.stdinBusy = false
+	}, nil
+}
+
+// WriteStdin writes `data` to the job's stdin. It blocks until the command reads it or `ctx` is done, and returns ErrStdinClosed if stdin was closed or the job is not running. Data that was only partly written when `ctx` is done stays written.
+func (job *Job) WriteStdin(ctx context.Context, data []byte) error {
+	stdin, err := job.getStdin()
+	if err != nil {
+		return err
+	}
+
+	// a write that blocks because the command does not read is interrupted once the context is done, so that the writer can release the job's stdin
+	err = writeContext(ctx, stdin, data)
+	if errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EPIPE) {
+		return ErrStdinClosed
+	}
+
+	return err
+}
+
+// CloseStdin closes the job's stdin, so that the command reads EOF once it read all data written before.
+func (job *Job) CloseStdin() error {
+	stdin, err := job.getStdin()
+	if err != nil {
+		return err
+	}
+
+	job.stdinMu.Lock()
+	job.stdin = nil
+	job.stdinMu.Unlock()
+
+	err = stdin.Close()
+	if errors.Is(err, os.ErrClosed) {
+		return ErrStdinClosed
+	}
+
+	return err
+}
+
+// getStdin returns the write end of the job's stdin pipe in a thread-safe way, if it is open.
+func (job *Job) getStdin() (io.WriteCloser, error) {
+	if !job.Stdin {
+		return nil, ErrNoStdin
+	}
+	if job.GetJobStatus() != pb.JobStatus_RUNNING {
+		return nil, ErrStdinClosed
+	}
+
+	job.stdinMu.Lock()
+	defer job.stdinMu.Unlock()
+
+	if job.stdin == nil {
+		return nil, ErrStdinClosed
+	}
+
+	return job.stdin, nil
+}
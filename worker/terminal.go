@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+)
+
+const (
+	ptyDrainTimeout = 100 * time.Millisecond // ptyDrainTimeout is how long the output of a pseudo-terminal is still logged after its command is done, while background processes hold it open.
+)
+
+var (
+	ErrNoTerminal     = errors.New("the job does not run in a terminal")
+	ErrTerminalClosed = errors.New("the job's terminal is closed")
+
+	DefaultTerminalSize = TerminalSize{Rows: 24, Cols: 80} // DefaultTerminalSize is the size of terminals whose size is not set.
+)
+
+// TerminalSize is the size of a terminal, in characters.
+type TerminalSize struct {
+	Rows uint16
+	Cols uint16
+}
+
+// WithTerminal runs the job's command in a pseudo-terminal of `size`, or DefaultTerminalSize if it is zero. Clients can write to it with WriteTerminal, and its output is logged with stdout and stderr combined.
+func WithTerminal(size TerminalSize) JobOption {
+	return func(job *Job) {
+		if size.Rows == 0 || size.Cols == 0 {
+			size = DefaultTerminalSize
+		}
+		job.TTY = true
+		job.terminalSize = size
+	}
+}
+
+// WriteTerminal writes `data` to the input of the job's terminal. It blocks until the terminal accepts it or `ctx` is done, so that a job that does not read its terminal can not hold up the writer forever. It returns ErrNoTerminal if the job does not run in one, and ErrTerminalClosed if the job is not running.
+func (job *Job) WriteTerminal(ctx context.Context, data []byte) error {
+	if !job.TTY {
+		return ErrNoTerminal
+	}
+	if job.GetJobStatus() != pb.JobStatus_RUNNING {
+		return ErrTerminalClosed
+	}
+
+	return job.group.WriteTerminal(ctx, data)
+}
+
+// ResizeTerminal changes the size of the job's terminal, and signals its command with SIGWINCH.
+func (job *Job) ResizeTerminal(size TerminalSize) error {
+	if !job.TTY {
+		return ErrNoTerminal
+	}
+	if job.GetJobStatus() != pb.JobStatus_RUNNING {
+		return ErrTerminalClosed
+	}
+
+	return job.group.ResizeTerminal(size)
+}
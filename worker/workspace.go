@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	WorkspaceEnv    = "WORKER_WORKSPACE" // WorkspaceEnv is the environment variable that holds the path of a job's workspace.
+	rootfsWorkspace = "/workspace"       // rootfsWorkspace is where the workspace of a job that runs in a root filesystem is mounted in it.
+)
+
+// WorkspaceDirectory returns the path to the job's workspace, which is the working directory of its command. It is kept apart from the job's log file, which the job's command can not access.
+func (job *Job) WorkspaceDirectory() string {
+	return filepath.Join(job.runDir, "workspace")
+}
+
+// rootfsDirectory returns the path to the directory that holds the writable layer of the job's root filesystem.
+func (job *Job) rootfsDirectory() string {
+	return filepath.Join(job.LogDirectory(), "rootfs")
+}
+
+// workspacePath returns the path of the job's workspace as the job's command sees it.
+func (job *Job) workspacePath() string {
+	if job.rootfs != nil {
+		return rootfsWorkspace
+	}
+
+	return absPath(job.WorkspaceDirectory())
+}
+
+// resolveWorkingDir returns the working directory of the job's command as the command sees it.
+func (job *Job) resolveWorkingDir() string {
+	if filepath.IsAbs(job.workingDir) {
+		return filepath.Clean(job.workingDir)
+	}
+
+	return filepath.Join(job.workspacePath(), job.workingDir)
+}
+
+// createWorkspace creates the job's workspace and its relative working directory, which only the user that the job runs as can access, and the mount point of its secret files. The directories above them can be searched but not listed by other users, so that jobs that run as them can reach their own workspace.
+func (job *Job) createWorkspace() error {
+	if err := os.MkdirAll(job.runDir, 0711); err != nil {
+		return err
+	}
+
+	dir := job.WorkspaceDirectory()
+	dirs := []string{dir}
+	if job.workingDir != "" && !filepath.IsAbs(job.workingDir) {
+		for _, name := range strings.Split(filepath.Clean(job.workingDir), string(filepath.Separator)) {
+			dir = filepath.Join(dir, name)
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// secret files are mounted over an empty directory, which only root can access
+	if len(job.secretFiles()) > 0 {
+		if err := os.MkdirAll(job.secretsDirectory(), 0700); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		if job.credential != nil {
+			if err := os.Chown(dir, int(job.credential.Uid), int(job.credential.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// cleanupWorkspace removes the job's workspace and the writable layer of its root filesystem after the job finished with `jobStatus`, unless the job's workspace retention keeps them.
+func (job *Job) cleanupWorkspace(jobStatus pb.JobStatus) {
+	logger := log.WithFields(log.Fields{"func": "Job.cleanupWorkspace", "jobKey": job.Key, "retention": job.WorkspaceRetention})
+
+	// the secrets directory is only a mount point, and is always removed
+	if err := os.RemoveAll(job.secretsDirectory()); err != nil {
+		logger.WithError(err).Error("unable to remove secrets directory")
+	}
+
+	switch job.WorkspaceRetention {
+	case pb.WorkspaceRetention_WORKSPACE_RETAIN:
+		return
+	case pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE:
+		if jobStatus != pb.JobStatus_SUCCEEDED {
+			logger.Debug("keeping the workspace of a job that did not succeed")
+			return
+		}
+	}
+
+	for _, dir := range []string{job.WorkspaceDirectory(), job.rootfsDirectory()} {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.WithError(err).WithField("dir", dir).Error("unable to remove job directory")
+		}
+	}
+	if err := os.Remove(job.runDir); err != nil {
+		logger.WithError(err).Error("unable to remove job directory")
+	}
+}
+
+// absPath returns the absolute representation of `path`, or `path` itself if the working directory can not be determined.
+func absPath(path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+
+	return path
+}
@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+)
+
+var (
+	ErrInvalidPath    = errors.New("the path is not a relative path in the job's workspace")
+	ErrNotRegularFile = errors.New("the path is not a regular file")
+	ErrWorkspaceTmpfs = errors.New("the job's workspace is a tmpfs, which only the job can access")
+	ErrUploadLimit    = errors.New("the job's upload limit was reached")
+)
+
+// UploadLimit caps the files that can be uploaded to a job's workspace. Zero fields do not cap anything.
+type UploadLimit struct {
+	Bytes uint64 // Bytes is the largest number of bytes that can be uploaded, counting files that were replaced or whose upload failed.
+	Files int    // Files is the largest number of uploads that can be started.
+}
+
+// WithUploadLimit caps the files that can be uploaded to the job's workspace while it is held at `limit`, so that a held job can not fill the server's disk.
+func WithUploadLimit(limit UploadLimit) JobOption {
+	return func(job *Job) {
+		job.uploadLimit = limit
+	}
+}
+
+// reserveUpload counts `bytes` more bytes and `files` more files towards the job's upload limit, and returns ErrUploadLimit if that would exceed it.
+func (job *Job) reserveUpload(bytes uint64, files int) error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if limit := job.uploadLimit.Bytes; limit > 0 && job.uploaded.Bytes+bytes > limit {
+		return ErrUploadLimit
+	}
+	if limit := job.uploadLimit.Files; limit > 0 && job.uploaded.Files+files > limit {
+		return ErrUploadLimit
+	}
+	job.uploaded.Bytes += bytes
+	job.uploaded.Files += files
+
+	return nil
+}
+
+// WorkspaceFile is a file that is being uploaded to a job's workspace. It is written to a hidden temporary file next to it, which only replaces the file once the upload is committed.
+type WorkspaceFile struct {
+	job     *Job
+	dir     *os.File // dir is the directory that the file is created in.
+	file    *os.File // file is the temporary file that is written to.
+	name    string   // name is the name of the file in `dir`.
+	tmpName string   // tmpName is the name of the temporary file in `dir`.
+}
+
+// CreateFile starts an upload of the file at `path` in the workspace of a held job, with permission bits `mode`. Missing parent directories are created, and the file and the directories are owned by the user that the job runs as. The caller must call Commit or Abort.
+func (job *Job) CreateFile(path string, mode os.FileMode) (*WorkspaceFile, error) {
+	dirs, name, err := splitWorkspacePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if job.tmpfsSize > 0 {
+		return nil, ErrWorkspaceTmpfs
+	}
+	if job.GetJobStatus() != pb.JobStatus_HELD {
+		return nil, ErrJobNotHeld
+	}
+	if err := job.reserveUpload(0, 1); err != nil {
+		return nil, err
+	}
+
+	dir, err := openWorkspaceDir(job.WorkspaceDirectory(), dirs, true, job.credential)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		dir.Close()
+		return nil, err
+	}
+	tmpName := "." + name + ".upload-" + hex.EncodeToString(suffix)
+
+	file, err := createFileAt(dir, name, tmpName, mode.Perm(), job.credential)
+	if err != nil {
+		dir.Close()
+		return nil, err
+	}
+
+	return &WorkspaceFile{job: job, dir: dir, file: file, name: name, tmpName: tmpName}, nil
+}
+
+// Write writes `data` to the end of the uploaded file. It returns ErrUploadLimit if the job's upload limit does not allow it.
+func (upload *WorkspaceFile) Write(data []byte) (int, error) {
+	if err := upload.job.reserveUpload(uint64(len(data)), 0); err != nil {
+		return 0, err
+	}
+
+	return upload.file.Write(data)
+}
+
+// Commit moves the uploaded file into place, replacing any previous file at its path. It returns ErrJobNotHeld if the job was released or stopped during the upload.
+func (upload *WorkspaceFile) Commit() error {
+	defer upload.dir.Close()
+
+	if err := upload.file.Close(); err != nil {
+		removeAt(upload.dir, upload.tmpName)
+		return err
+	}
+
+	// the job can not be released while the file is moved into place
+	upload.job.mu.RLock()
+	defer upload.job.mu.RUnlock()
+
+	if upload.job.jobStatus != pb.JobStatus_HELD {
+		removeAt(upload.dir, upload.tmpName)
+		return ErrJobNotHeld
+	}
+
+	if err := renameAt(upload.dir, upload.tmpName, upload.name); err != nil {
+		removeAt(upload.dir, upload.tmpName)
+		return err
+	}
+
+	return nil
+}
+
+// Abort discards the uploaded file.
+func (upload *WorkspaceFile) Abort() {
+	defer upload.dir.Close()
+
+	upload.file.Close()
+	removeAt(upload.dir, upload.tmpName)
+}
+
+// OpenFile opens the regular file at `path` in the job's workspace for reading. Symbolic links are not followed, so that the job can not point it outside of its workspace.
+func (job *Job) OpenFile(path string) (*os.File, error) {
+	dirs, name, err := splitWorkspacePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if job.tmpfsSize > 0 {
+		return nil, ErrWorkspaceTmpfs
+	}
+
+	dir, err := openWorkspaceDir(job.WorkspaceDirectory(), dirs, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	return openFileAt(dir, name)
+}
+
+// splitWorkspacePath splits the relative path `path` into its parent directories and its base name. It returns ErrInvalidPath if the path is absolute or leaves the workspace.
+func splitWorkspacePath(path string) ([]string, string, error) {
+	if path == "" || filepath.IsAbs(path) {
+		return nil, "", ErrInvalidPath
+	}
+
+	elements := strings.Split(filepath.Clean(path), string(filepath.Separator))
+	for _, element := range elements {
+		if element == "." || element == ".." {
+			return nil, "", ErrInvalidPath
+		}
+	}
+
+	return elements[:len(elements)-1], elements[len(elements)-1], nil
+}
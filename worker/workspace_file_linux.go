@@ -0,0 +1,140 @@
+//go:build linux
+// +build linux
+
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openWorkspaceDir opens the directory `dirs` below `root` without following symbolic links, creating missing directories owned by `credential` if `create` is true.
+func openWorkspaceDir(root string, dirs []string, create bool, credential *syscall.Credential) (*os.File, error) {
+	fd, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+
+	path := root
+	for _, name := range dirs {
+		path = filepath.Join(path, name)
+
+		next, err := unix.Openat(fd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err == unix.ENOENT && create {
+			next, err = mkdirAt(fd, name, credential)
+		}
+		unix.Close(fd)
+		if err == unix.ENOTDIR || err == unix.ELOOP {
+			return nil, ErrInvalidPath
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		fd = next
+	}
+
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// mkdirAt creates and opens the directory `name` in the directory `fd`, owned by `credential` if it is not nil.
+func mkdirAt(fd int, name string, credential *syscall.Credential) (int, error) {
+	if err := unix.Mkdirat(fd, name, 0700); err != nil && err != unix.EEXIST {
+		return -1, err
+	}
+
+	dir, err := unix.Openat(fd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	if credential != nil {
+		if err := unix.Fchown(dir, int(credential.Uid), int(credential.Gid)); err != nil {
+			unix.Close(dir)
+			return -1, err
+		}
+	}
+
+	return dir, nil
+}
+
+// createFileAt creates the new file `tmpName` in `dir` with permission bits `mode`, owned by `credential` if it is not nil, to replace the regular file `name` later.
+func createFileAt(dir *os.File, name string, tmpName string, mode os.FileMode, credential *syscall.Credential) (*os.File, error) {
+	var stat unix.Stat_t
+	err := unix.Fstatat(int(dir.Fd()), name, &stat, unix.AT_SYMLINK_NOFOLLOW)
+	if err == nil && stat.Mode&unix.S_IFMT != unix.S_IFREG {
+		return nil, ErrNotRegularFile
+	}
+	if err != nil && err != unix.ENOENT {
+		return nil, &os.PathError{Op: "stat", Path: filepath.Join(dir.Name(), name), Err: err}
+	}
+
+	path := filepath.Join(dir.Name(), tmpName)
+	fd, err := unix.Openat(int(dir.Fd()), tmpName, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(mode))
+	if err != nil {
+		return nil, &os.PathError{Op: "create", Path: path, Err: err}
+	}
+	file := os.NewFile(uintptr(fd), path)
+
+	// the mode is set explicitly, since the umask applies to the mode at creation
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		removeAt(dir, tmpName)
+		return nil, err
+	}
+	if credential != nil {
+		if err := file.Chown(int(credential.Uid), int(credential.Gid)); err != nil {
+			file.Close()
+			removeAt(dir, tmpName)
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// openFileAt opens the regular file `name` in `dir` for reading, without following symbolic links.
+func openFileAt(dir *os.File, name string) (*os.File, error) {
+	path := filepath.Join(dir.Name(), name)
+
+	// the file is opened without blocking, so that opening a named pipe does not wait for a writer
+	fd, err := unix.Openat(int(dir.Fd()), name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err == unix.ELOOP {
+		return nil, ErrNotRegularFile
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	file := os.NewFile(uintptr(fd), path)
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, ErrNotRegularFile
+	}
+
+	return file, nil
+}
+
+// renameAt renames `oldName` to `newName` in `dir`.
+func renameAt(dir *os.File, oldName string, newName string) error {
+	if err := unix.Renameat(int(dir.Fd()), oldName, int(dir.Fd()), newName); err != nil {
+		return &os.LinkError{Op: "rename", Old: filepath.Join(dir.Name(), oldName), New: filepath.Join(dir.Name(), newName), Err: err}
+	}
+
+	return nil
+}
+
+// removeAt removes the file `name` from `dir`.
+func removeAt(dir *os.File, name string) error {
+	if err := unix.Unlinkat(int(dir.Fd()), name, 0); err != nil {
+		return &os.PathError{Op: "remove", Path: filepath.Join(dir.Name(), name), Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+// +build !linux
+
+package worker
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var (
+	errFileTransferUnsupported = errors.New("file transfers are only supported on Linux")
+)
+
+// openWorkspaceDir is not supported on this platform.
+func openWorkspaceDir(root string, dirs []string, create bool, credential *syscall.Credential) (*os.File, error) {
+	return nil, errFileTransferUnsupported
+}
+
+// createFileAt is not supported on this platform.
+func createFileAt(dir *os.File, name string, tmpName string, mode os.FileMode, credential *syscall.Credential) (*os.File, error) {
+	return nil, errFileTransferUnsupported
+}
+
+// openFileAt is not supported on this platform.
+func openFileAt(dir *os.File, name string) (*os.File, error) {
+	return nil, errFileTransferUnsupported
+}
+
+// renameAt is not supported on this platform.
+func renameAt(dir *os.File, oldName string, newName string) error {
+	return errFileTransferUnsupported
+}
+
+// removeAt is not supported on this platform.
+func removeAt(dir *os.File, name string) error {
+	return errFileTransferUnsupported
+}
@@ -0,0 +1,33 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package worker
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// unshareMounts moves the current thread into a new mount namespace, whose mounts do not propagate back to the host.
+func unshareMounts() error {
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		return err
+	}
+
+	return unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, "")
+}
+
+// mountWorkspaceTmpfs mounts a tmpfs capped at `size` bytes at `workspace`, which is owned by `credential`, or by the current user if it is nil.
+func mountWorkspaceTmpfs(workspace string, size uint64, credential *syscall.Credential) error {
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	if credential != nil {
+		uid, gid = credential.Uid, credential.Gid
+	}
+
+	options := fmt.Sprintf("size=%d,mode=700,uid=%d,gid=%d", size, uid, gid)
+	return unix.Mount("tmpfs", workspace, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, options)
+}
@@ -0,0 +1,179 @@
+package worker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlaradji/int-backend-mohamed/pb"
+	"github.com/mlaradji/int-backend-mohamed/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobWorkspace checks that jobs run in their own workspace, and that it is kept or deleted after the job is done according to the job's workspace retention.
+func TestJobWorkspace(t *testing.T) {
+	t.Parallel()
+
+	script := `pwd && echo "$` + worker.WorkspaceEnv + `" && touch file && exit "$1"`
+
+	job, output := runJob(t, "sh", []string{"-c", script, "sh", "0"})
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	workspace, err := filepath.Abs(job.WorkspaceDirectory())
+	require.NoError(t, err)
+	require.Equal(t, workspace+"\n"+workspace+"\n", output)
+	_, err = os.Stat(workspace)
+	require.True(t, os.IsNotExist(err), "workspaces are deleted by default")
+
+	testCases := []struct {
+		name      string
+		retention pb.WorkspaceRetention
+		exitCode  string
+		kept      bool
+	}{
+		{"retained", pb.WorkspaceRetention_WORKSPACE_RETAIN, "0", true},
+		{"retained on failure", pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE, "1", true},
+		{"deleted on success", pb.WorkspaceRetention_WORKSPACE_RETAIN_ON_FAILURE, "0", false},
+	}
+	for _, testCase := range testCases {
+		job, output := runJob(t, "sh", []string{"-c", script, "sh", testCase.exitCode}, worker.WithWorkspaceRetention(testCase.retention))
+		require.Equal(t, testCase.retention, job.WorkspaceRetention, testCase.name)
+		_, err := os.Stat(filepath.Join(job.WorkspaceDirectory(), "file"))
+		require.Equal(t, testCase.kept, err == nil, testCase.name, output)
+	}
+
+	// jobs that are stopped before they run never use their workspace
+	job, err = worker.NewJobStore().AddJob("me", "true", nil, worker.WithPendingApproval())
+	require.NoError(t, err)
+	_, err = os.Stat(job.WorkspaceDirectory())
+	require.NoError(t, err)
+	job.Stop()
+	<-job.Done
+	_, err = os.Stat(job.WorkspaceDirectory())
+	require.True(t, os.IsNotExist(err))
+}
+
+// TestJobWorkspaceMounts runs jobs in a tmpfs workspace and in a root filesystem, and checks that both see their workspace.
+func TestJobWorkspaceMounts(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("mounting workspaces requires root")
+	}
+
+	// tmpfs workspaces are owned by the job's user, and their contents never reach the disk
+	script := `grep " $` + worker.WorkspaceEnv + ` " /proc/self/mountinfo && touch file && id -u`
+	job, output := runJob(t, "sh", []string{"-c", script}, worker.WithWorkspaceTmpfs(1<<20), worker.WithCredential(65534, 65534, nil), worker.WithWorkspaceRetention(pb.WorkspaceRetention_WORKSPACE_RETAIN))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Contains(t, output, "tmpfs")
+	require.Contains(t, output, "size=1024k")
+	require.True(t, strings.HasSuffix(output, "65534\n"), output)
+	_, err := os.Stat(filepath.Join(job.WorkspaceDirectory(), "file"))
+	require.True(t, os.IsNotExist(err))
+
+	// the workspace of a job in a root filesystem is mounted in it
+	rootfs := worker.Rootfs{Name: "host", Path: "/"}
+	job, output = runJob(t, "sh", []string{"-c", `pwd && echo "$` + worker.WorkspaceEnv + `" && echo kept > file`}, worker.WithRootfs(rootfs), worker.WithWorkspaceRetention(pb.WorkspaceRetention_WORKSPACE_RETAIN))
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus(), output)
+	require.Equal(t, "/workspace\n/workspace\n", output)
+	data, err := ioutil.ReadFile(filepath.Join(job.WorkspaceDirectory(), "file"))
+	require.NoError(t, err)
+	require.Equal(t, "kept\n", string(data))
+}
+
+// TestJobFiles uploads a script to a held job, runs it, and downloads its output. Uploads are only visible once committed, and paths can not leave the workspace, not even through symbolic links that the job created.
+func TestJobFiles(t *testing.T) {
+	t.Parallel()
+
+	job, err := worker.NewJobStore().AddJob("me", "sh", []string{"-c", "./bin/script.sh > out && ln -s /etc etc && ln -s /etc/passwd passwd"}, worker.WithHold(), worker.WithWorkspaceRetention(pb.WorkspaceRetention_WORKSPACE_RETAIN))
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_HELD, job.GetJobStatus())
+
+	for _, path := range []string{"", "/etc/passwd", "../escape", "bin/../../escape", "."} {
+		_, err := job.CreateFile(path, 0644)
+		require.ErrorIs(t, err, worker.ErrInvalidPath, path)
+	}
+
+	upload, err := job.CreateFile("bin/script.sh", 0755)
+	require.NoError(t, err)
+	_, err = upload.Write([]byte("#!/bin/sh\necho uploaded\n"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(job.WorkspaceDirectory(), "bin", "script.sh"))
+	require.True(t, os.IsNotExist(err), "uploads are not visible before they are committed")
+	require.NoError(t, upload.Commit())
+
+	aborted, err := job.CreateFile("aborted", 0644)
+	require.NoError(t, err)
+	aborted.Abort()
+
+	entries, err := ioutil.ReadDir(job.WorkspaceDirectory())
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"bin"}, names)
+
+	require.NoError(t, job.Release())
+	require.ErrorIs(t, job.Release(), worker.ErrJobNotHeld)
+	_, err = job.CreateFile("late", 0644)
+	require.ErrorIs(t, err, worker.ErrJobNotHeld)
+
+	require.NoError(t, job.Start())
+	<-job.Done
+	require.Equal(t, pb.JobStatus_SUCCEEDED, job.GetJobStatus())
+
+	file, err := job.OpenFile("out")
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	require.Equal(t, "uploaded\n", string(output))
+
+	for path, expected := range map[string]error{"passwd": worker.ErrNotRegularFile, "bin": worker.ErrNotRegularFile, "etc/passwd": worker.ErrInvalidPath} {
+		_, err := job.OpenFile(path)
+		require.ErrorIs(t, err, expected, path)
+	}
+	_, err = job.OpenFile("missing")
+	require.True(t, os.IsNotExist(err), err)
+
+	// held jobs that require approval are pending approval once they are released
+	job, err = worker.NewJobStore().AddJob("me", "true", nil, worker.WithHold(), worker.WithPendingApproval())
+	require.NoError(t, err)
+	require.Equal(t, pb.JobStatus_HELD, job.GetJobStatus())
+	require.NoError(t, job.Release())
+	require.Equal(t, pb.JobStatus_PENDING_APPROVAL, job.GetJobStatus())
+
+	// held jobs can be stopped without ever running
+	job, err = worker.NewJobStore().AddJob("me", "true", nil, worker.WithHold())
+	require.NoError(t, err)
+	job.Stop()
+	<-job.Done
+	require.Equal(t, pb.JobStatus_STOPPED, job.GetJobStatus())
+	require.ErrorIs(t, job.Release(), worker.ErrJobNotHeld)
+
+	// held jobs are stopped if they are not released in time
+	job, err = worker.NewJobStore().AddJob("me", "true", nil, worker.WithHold(), worker.WithHoldTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	<-job.Done
+	require.Equal(t, pb.JobStatus_STOPPED, job.GetJobStatus())
+
+	// uploads count towards the job's upload limit, even if they are aborted
+	job, err = worker.NewJobStore().AddJob("me", "true", nil, worker.WithHold(), worker.WithUploadLimit(worker.UploadLimit{Bytes: 8, Files: 2}))
+	require.NoError(t, err)
+	defer job.Stop()
+	upload, err = job.CreateFile("first", 0644)
+	require.NoError(t, err)
+	_, err = upload.Write([]byte("12345"))
+	require.NoError(t, err)
+	upload.Abort()
+	upload, err = job.CreateFile("second", 0644)
+	require.NoError(t, err)
+	_, err = upload.Write([]byte("6789"))
+	require.ErrorIs(t, err, worker.ErrUploadLimit)
+	upload.Abort()
+	_, err = job.CreateFile("third", 0644)
+	require.ErrorIs(t, err, worker.ErrUploadLimit)
+}